@@ -11,72 +11,210 @@ import (
 	"github.com/semanticallynull/bookingengine-backend/booking"
 	"github.com/semanticallynull/bookingengine-backend/customer"
 	"github.com/semanticallynull/bookingengine-backend/internal/auth0"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+	"github.com/semanticallynull/bookingengine-backend/internal/billing"
+	"github.com/semanticallynull/bookingengine-backend/internal/blob"
+	"github.com/semanticallynull/bookingengine-backend/internal/gbfs"
+	"github.com/semanticallynull/bookingengine-backend/internal/gdpr"
+	"github.com/semanticallynull/bookingengine-backend/internal/label"
+	"github.com/semanticallynull/bookingengine-backend/internal/lockgw"
+	"github.com/semanticallynull/bookingengine-backend/internal/mds"
 	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/internal/notify"
 	"github.com/semanticallynull/bookingengine-backend/internal/o11y"
+	"github.com/semanticallynull/bookingengine-backend/internal/rebalance"
+	"github.com/semanticallynull/bookingengine-backend/issue"
+	"github.com/semanticallynull/bookingengine-backend/rating"
+	"github.com/semanticallynull/bookingengine-backend/report"
 	"github.com/semanticallynull/bookingengine-backend/ride"
 	"github.com/semanticallynull/bookingengine-backend/station"
+	"github.com/semanticallynull/bookingengine-backend/telemetry"
+	"github.com/semanticallynull/bookingengine-backend/track"
 )
 
 type API struct {
-	r   *gin.Engine
-	br  *bike.Repository
-	sr  *station.Repository
-	cr  *customer.Repository
-	rr  *ride.Repository
-	bkr *booking.Repository
-
-	jwtValidator *middleware.JWTValidator
-	auth0Client  auth0.Client
-	stripePK     string
-	stripeSK     string
+	r    *gin.Engine
+	br   *bike.Repository
+	sr   *station.Repository
+	cr   *customer.Repository
+	rr   *ride.Repository
+	bkr  *booking.Repository
+	tr   *track.Repository
+	telr *telemetry.Repository
+	ir   *issue.Repository
+	ratr *rating.Repository
+	rptr *report.Repository
+
+	avail      *availability.Service
+	rebalancer *rebalance.Planner
+	// feeds publishes the GBFS feeds. It is nil if they aren't published
+	feeds *gbfs.Publisher
+	// mds reports to the city's regulator. It is nil if reporting is off
+	mds *mds.Provider
+	// collector gathers customers' personal data for exports
+	collector *gdpr.Collector
+
+	jwtValidator  *middleware.JWTValidator
+	auth0Client   auth0.Client
+	locks         lockgw.Client
+	biller        *billing.Biller
+	notifier      notify.Notifier
+	blobs         blob.Store
+	batteryCurves bike.BatteryCurves
+	labelFormat   label.Format
+	labelLinkBase string
+	stripePK      string
+	stripeSK      string
+	// exportKey signs links to download customer exports
+	exportKey []byte
+}
+
+// Deps are the repositories and services the API is built on.
+type Deps struct {
+	Bikes     *bike.Repository
+	Stations  *station.Repository
+	Customers *customer.Repository
+	Rides     *ride.Repository
+	Bookings  *booking.Repository
+	Tracks    *track.Repository
+	Telemetry *telemetry.Repository
+	Issues    *issue.Repository
+	Ratings   *rating.Repository
+	Reports   *report.Repository
+
+	Availability *availability.Service
+	Rebalancer   *rebalance.Planner
+	// Feeds publishes the GBFS feeds. If it is nil they aren't published
+	Feeds *gbfs.Publisher
+	// MDS reports to the city's regulator. If it is nil reporting is off
+	MDS       *mds.Provider
+	Collector *gdpr.Collector
+
+	Auth0         auth0.Client
+	Locks         lockgw.Client
+	Biller        *billing.Biller
+	Notifier      notify.Notifier
+	Blobs         blob.Store
+	Observability *o11y.Observability
+	BatteryCurves bike.BatteryCurves
 }
 
-func New(br *bike.Repository, sr *station.Repository, cr *customer.Repository, rr *ride.Repository, bkr *booking.Repository,
-	auth0Client auth0.Client, o *o11y.Observability, auth0Domain, audience, metricsUsername, metricsPassword, stripePK, stripeSK string) *API {
+// Config is the API's settings.
+type Config struct {
+	Auth0Domain string
+	Audience    string
+
+	// The metrics endpoint is only served if both are set
+	MetricsUsername string
+	MetricsPassword string
+
+	StripePK string
+	StripeSK string
+
+	// DeviceKey is the key device tokens are derived from. The device endpoints are only served if it is set
+	DeviceKey string
+	// RegulatorToken is the token the regulator calls the MDS provider API with
+	RegulatorToken string
+	// ExportKey signs links to download customer exports
+	ExportKey []byte
+
+	LabelFormat   label.Format
+	LabelLinkBase string
+}
 
+func New(d Deps, cfg Config) *API {
 	a := &API{
-		r:           gin.New(),
-		br:          br,
-		sr:          sr,
-		cr:          cr,
-		rr:          rr,
-		bkr:         bkr,
-		auth0Client: auth0Client,
-		stripePK:    stripePK,
-		stripeSK:    stripeSK,
+		r:             gin.New(),
+		br:            d.Bikes,
+		sr:            d.Stations,
+		cr:            d.Customers,
+		rr:            d.Rides,
+		bkr:           d.Bookings,
+		tr:            d.Tracks,
+		telr:          d.Telemetry,
+		ir:            d.Issues,
+		ratr:          d.Ratings,
+		rptr:          d.Reports,
+		avail:         d.Availability,
+		rebalancer:    d.Rebalancer,
+		feeds:         d.Feeds,
+		mds:           d.MDS,
+		collector:     d.Collector,
+		auth0Client:   d.Auth0,
+		locks:         d.Locks,
+		biller:        d.Biller,
+		notifier:      d.Notifier,
+		blobs:         d.Blobs,
+		batteryCurves: d.BatteryCurves,
+		labelFormat:   cfg.LabelFormat,
+		labelLinkBase: cfg.LabelLinkBase,
+		stripePK:      cfg.StripePK,
+		stripeSK:      cfg.StripeSK,
+		exportKey:     cfg.ExportKey,
 	}
 
-	stripe.Key = stripeSK
+	stripe.Key = cfg.StripeSK
 
 	// Global middleware (apply to all routes)
 	a.r.Use(gin.Recovery())
 	a.r.Use(middleware.Tracing())
-	a.r.Use(middleware.Logging(o.Logger))
-	a.r.Use(middleware.Metrics(o.Registry))
+	a.r.Use(middleware.Logging(d.Observability.Logger))
+	a.r.Use(middleware.Metrics(d.Observability.Registry))
 
 	// Metrics endpoint with basic auth (if credentials provided)
-	if metricsUsername != "" && metricsPassword != "" {
+	if cfg.MetricsUsername != "" && cfg.MetricsPassword != "" {
 		authorized := a.r.Group("/", gin.BasicAuth(gin.Accounts{
-			metricsUsername: metricsPassword,
+			cfg.MetricsUsername: cfg.MetricsPassword,
 		}))
-		authorized.GET("/metrics", gin.WrapH(promhttp.HandlerFor(o.Registry, promhttp.HandlerOpts{})))
+		authorized.GET("/metrics", gin.WrapH(promhttp.HandlerFor(d.Observability.Registry, promhttp.HandlerOpts{})))
+	}
+
+	// Device endpoints used by the locks (require the device's own token)
+	if cfg.DeviceKey != "" {
+		devices := a.r.Group("/devices/:imei", middleware.DeviceAuth(cfg.DeviceKey))
+		devices.POST("/positions", a.positionsHandler)
+		devices.POST("/telemetry", a.telemetryHandler)
+	}
+
+	// Public GeoJSON of stations for maps
+	a.r.GET("/geojson/stations", a.publicStationsGeoJSONHandler)
+
+	// Customer exports are downloaded through a signed link, so they can be opened in a browser
+	a.r.GET("/exports/:exportId/download", a.downloadCustomerExportHandler)
+
+	// Public GBFS feeds for journey planners
+	if d.Feeds != nil {
+		a.r.GET("/gbfs/:version/:feed", a.gbfsHandler)
+	}
+
+	// MDS provider API for the city's regulator (requires the regulator's token)
+	if d.MDS != nil && cfg.RegulatorToken != "" {
+		regulator := a.r.Group("/mds", middleware.StaticToken(cfg.RegulatorToken))
+		regulator.GET("/trips", a.mdsTripsHandler)
+		regulator.GET("/status_changes", a.mdsStatusChangesHandler)
+		regulator.GET("/vehicles", a.mdsVehiclesHandler)
 	}
 
 	// Protected API routes (require JWT)
-	a.jwtValidator = middleware.NewJWTValidator(auth0Domain, audience)
+	a.jwtValidator = middleware.NewJWTValidator(cfg.Auth0Domain, cfg.Audience)
 	protected := a.r.Group("/")
 	protected.Use(a.jwtValidator.EnsureValidToken())
 	protected.Use(func(c *gin.Context) {
-		c.Set("auth0_domain", auth0Domain)
+		c.Set("auth0_domain", cfg.Auth0Domain)
 	})
 	{
 		protected.GET("/availability", a.availabilityHandler)
+		protected.GET("/bikes", a.nearbyBikesHandler)
 		protected.GET("/bikes/:label", a.bikeHandler)
 		protected.GET("/bikes/:label/upcoming-booking-check", a.upcomingBookingCheckHandler)
+		protected.POST("/bikes/:label/issues", a.createIssueHandler)
+		protected.POST("/issues/:issueId/photos", a.addIssuePhotosHandler)
+		protected.GET("/models", a.modelsHandler)
 		protected.GET("/stations", a.stationsHandler)
 		protected.GET("/stations/:id", a.stationHandler)
+		protected.POST("/stations/join", a.joinStationHandler)
 		protected.GET("/stripe/pubkey", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"publishableKey": stripePK})
+			c.JSON(http.StatusOK, gin.H{"publishableKey": cfg.StripePK})
 		})
 		protected.POST("/customer/session", a.createCustomerSession)
 		protected.POST("/customer/setupintent", a.createSetupIntent)
@@ -84,15 +222,60 @@ func New(br *bike.Repository, sr *station.Repository, cr *customer.Repository, r
 		protected.GET("/customer/profile", a.getProfile)
 		protected.PATCH("/customer/profile", a.updateProfile)
 		protected.GET("/customer/preride", a.preRide)
+		protected.GET("/customer/export", a.customerExportHandler)
+		protected.GET("/customer/exports/:exportId", a.getCustomerExportHandler)
 		protected.POST("/ride/start", a.startRideHandler)
 		protected.POST("/ride/end", a.endRideHandler)
 		protected.GET("/ride/current", a.currentRideHandler)
+		protected.POST("/ride/alarm", a.alarmHandler)
+		protected.GET("/rides", a.rideHistoryHandler)
+		protected.POST("/rides/:rideId/rating", a.rateRideHandler)
 
 		// Booking endpoints
 		protected.GET("/bookings", a.getBookingsHandler)
 		protected.POST("/bookings", a.createBookingHandler)
 		protected.GET("/bookings/current", a.getCurrentBookingHandler)
 		protected.POST("/bookings/:bookingId/cancel", a.cancelBookingHandler)
+
+		// Admin endpoints (require the admin scope)
+		admin := protected.Group("/admin", middleware.RequireScope("admin"))
+		admin.GET("/ratings/bikes", a.bikeRatingsHandler)
+		admin.GET("/ratings/stations", a.stationRatingsHandler)
+		admin.GET("/models", a.modelsHandler)
+		admin.POST("/models", a.createModelHandler)
+		admin.GET("/models/:modelId", a.getModelHandler)
+		admin.PUT("/models/:modelId", a.updateModelHandler)
+		admin.DELETE("/models/:modelId", a.deleteModelHandler)
+		admin.GET("/bikes", a.listFleetHandler)
+		admin.POST("/bikes", a.createFleetBikeHandler)
+		admin.GET("/bikes/:bikeId", a.getFleetBikeHandler)
+		admin.PATCH("/bikes/:bikeId", a.updateFleetBikeHandler)
+		admin.DELETE("/bikes/:bikeId", a.retireFleetBikeHandler)
+		admin.POST("/bikes/:bikeId/state", a.setFleetBikeStateHandler)
+		admin.GET("/bikes/:bikeId/state-history", a.fleetBikeStateHistoryHandler)
+		admin.GET("/bikes/:bikeId/battery-history", a.batteryHistoryHandler)
+		admin.GET("/bikes/:bikeId/label", a.bikeLabelHandler)
+		admin.GET("/labels", a.labelSheetHandler)
+		admin.GET("/stations", a.listAdminStationsHandler)
+		admin.POST("/stations", a.createStationHandler)
+		admin.GET("/stations/:stationId", a.getAdminStationHandler)
+		admin.PATCH("/stations/:stationId", a.updateStationHandler)
+		admin.DELETE("/stations/:stationId", a.deactivateStationHandler)
+		admin.POST("/stations/:stationId/reactivate", a.reactivateStationHandler)
+		admin.GET("/stations/:stationId/invites", a.listInvitesHandler)
+		admin.POST("/stations/:stationId/invites", a.createInviteHandler)
+		admin.DELETE("/stations/:stationId/invites/:code", a.deleteInviteHandler)
+		admin.GET("/stations/:stationId/members", a.listMembersHandler)
+		admin.DELETE("/stations/:stationId/members/:customerId", a.removeMemberHandler)
+		admin.GET("/stations/:stationId/domains", a.listEmailDomainsHandler)
+		admin.POST("/stations/:stationId/domains", a.addEmailDomainHandler)
+		admin.DELETE("/stations/:stationId/domains/:domain", a.removeEmailDomainHandler)
+		admin.GET("/reports/bikes", a.bikeUtilizationHandler)
+		admin.GET("/reports/stations", a.stationUtilizationHandler)
+		admin.GET("/rebalancing", a.rebalancingHandler)
+		admin.GET("/export/stations", a.exportStationsHandler)
+		admin.GET("/export/bikes", a.exportBikesHandler)
+		admin.GET("/geojson/bikes", a.bikesGeoJSONHandler)
 	}
 
 	return a
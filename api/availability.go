@@ -8,6 +8,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
 	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
 )
 
@@ -18,7 +20,12 @@ type bikeAvailabilityResponse struct {
 	BikeImage   *string                   `json:"imageUrl,omitempty"`
 	StationID   *uuid.UUID                `json:"stationId,omitempty"`
 	StationName string                    `json:"stationName,omitempty"`
+	Model       *bikeModelResponse        `json:"model,omitempty"`
 	Bookings    []bookingTimeSlotResponse `json:"bookings"`
+	// Available, AvailableUntil and UnavailableReason describe whether the bike can be taken now
+	Available         bool                `json:"available"`
+	AvailableUntil    *time.Time          `json:"availableUntil,omitempty"`
+	UnavailableReason availability.Reason `json:"unavailableReason,omitempty"`
 }
 
 type bookingTimeSlotResponse struct {
@@ -30,12 +37,19 @@ type bookingTimeSlotResponse struct {
 func (a *API) availabilityHandler(c *gin.Context) {
 	logger := middleware.GetLogger(c)
 
-	userID, ok := middleware.GetAuth0ID(c)
-	if !ok {
+	if _, ok := middleware.GetAuth0ID(c); !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "Authentication required"})
 		return
 	}
 
+	// Bookings are held against the customer ID rather than the Auth0 ID
+	customerID, err := a.currentCustomerID(c)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
 	// Parse optional query params
 	stationID := c.Query("stationId")
 	startDateStr := c.Query("startDate")
@@ -52,16 +66,46 @@ func (a *API) availabilityHandler(c *gin.Context) {
 		return
 	}
 
+	filter, ok := bikeModelFilter(c)
+	if !ok {
+		return
+	}
+
 	// Fetch bikes with station info
-	bikes, err := a.br.GetBikesWithStations(c, stationIDPtr)
+	bikes, err := a.br.GetBikesWithStations(c, stationIDPtr, filter)
 	if err != nil {
 		logger.ErrorContext(c, "failed to get bikes with stations", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
+	hidden, err := a.sr.GetHiddenStations(c, customerID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get hidden stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	visible := bikes[:0]
+	for _, b := range bikes {
+		if !atHiddenStation(b.Bike, hidden) {
+			visible = append(visible, b)
+		}
+	}
+	bikes = visible
+
+	plain := make([]bike.Bike, 0, len(bikes))
+	for _, b := range bikes {
+		plain = append(plain, b.Bike)
+	}
+	avail, err := a.avail.CheckAll(c, plain, customerID, time.Now())
+	if err != nil {
+		logger.ErrorContext(c, "failed to check availability", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
 	// Build availability response for each bike
-	availability := make([]bikeAvailabilityResponse, 0, len(bikes))
+	resp := make([]bikeAvailabilityResponse, 0, len(bikes))
 	for _, bike := range bikes {
 		// Get bookings for this bike
 		slots, err := a.bkr.GetBookingsForBike(c, bike.ID, startDate, endDate)
@@ -76,22 +120,27 @@ func (a *API) availabilityHandler(c *gin.Context) {
 			bookings = append(bookings, bookingTimeSlotResponse{
 				StartTime:    slot.StartTime,
 				EndTime:      slot.EndTime,
-				IsOwnBooking: slot.UserID == userID,
+				IsOwnBooking: slot.UserID == customerID.String(),
 			})
 		}
 
-		availability = append(availability, bikeAvailabilityResponse{
-			BikeID:      bike.ID,
-			BikeName:    bike.Label,
-			DisplayName: bike.DisplayName,
-			BikeImage:   bike.ImageURL,
-			StationID:   bike.StationID,
-			StationName: bike.StationName,
-			Bookings:    bookings,
+		result := avail[bike.ID]
+		resp = append(resp, bikeAvailabilityResponse{
+			BikeID:            bike.ID,
+			BikeName:          bike.Label,
+			DisplayName:       bike.ModelName,
+			BikeImage:         bike.ImageURL,
+			Model:             toBikeModelResponse(bike.Bike),
+			StationID:         bike.StationID,
+			StationName:       bike.StationName,
+			Bookings:          bookings,
+			Available:         result.Available,
+			AvailableUntil:    result.Until,
+			UnavailableReason: result.Reason,
 		})
 	}
 
-	c.JSON(http.StatusOK, availability)
+	c.JSON(http.StatusOK, resp)
 }
 
 func parseDate(startDateStr string, endDateStr string) (*time.Time, *time.Time, error) {
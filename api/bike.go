@@ -9,22 +9,23 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
 	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
 )
 
-func (a *API) bikesHandler(c *gin.Context) {
-	bikes, err := a.br.GetBikes(c)
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(200, bikes)
-}
-
 func (a *API) bikeHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
 	label := c.Param("label")
 	b, err := a.br.GetBike(c, label)
+	if errors.Is(err, bike.ErrNotFound) && !a.validateLabel(c, label) {
+		// The label was probably mistyped
+		return
+	}
+	if err == nil && b.State == bike.StateRetired {
+		err = bike.ErrNotFound
+	}
 	if err != nil {
 		if errors.Is(err, bike.ErrNotFound) {
 			c.JSON(404, gin.H{"error": err.Error()})
@@ -34,55 +35,157 @@ func (a *API) bikeHandler(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, toBikeResponse(b))
+	customerID, err := a.currentCustomerID(c)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	// Bikes at a private station are hidden from non-members
+	hidden, err := a.hiddenBike(c, b, customerID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get hidden stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if hidden {
+		c.JSON(404, gin.H{"error": bike.ErrNotFound.Error()})
+		return
+	}
+
+	avail, err := a.avail.Check(c, b, customerID, time.Now())
+	if err != nil {
+		logger.ErrorContext(c, "failed to check availability", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(200, toBikeResponse(b, avail, a.batteryCurves))
 }
 
-func voltageToPercentage(voltage int) int {
-	return (voltage - 340) * 100 / 72
+// currentCustomerID returns the ID of the customer making the request, or uuid.Nil if they haven't
+// signed up as a customer yet.
+func (a *API) currentCustomerID(c *gin.Context) (uuid.UUID, error) {
+	userID, _ := middleware.GetAuth0ID(c)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
+	if errors.Is(err, customer.ErrNotFound) {
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return cust.ID, nil
 }
 
 type bikeResponse struct {
-	ID             uuid.UUID `json:"id"`
-	Label          string    `json:"label"`
-	DisplayName    string    `json:"displayName"`
-	IMEI           string    `json:"bleId"`
-	Lat            float64   `json:"latitude"`
-	Lng            float64   `json:"longitude"`
-	BatteryVoltage int       `json:"batteryVoltage"`
-	Available      bool      `json:"available"`
-	StationName    string    `json:"stationName"`
+	ID          uuid.UUID `json:"id"`
+	Label       string    `json:"label"`
+	DisplayName string    `json:"displayName"`
+	IMEI        string    `json:"bleId"`
+	Lat         float64   `json:"latitude"`
+	Lng         float64   `json:"longitude"`
+	// BatteryVoltage is the battery's state of charge as a percentage
+	BatteryVoltage   int        `json:"batteryVoltage"`
+	BatteryUpdatedAt *time.Time `json:"batteryUpdatedAt,omitempty"`
+	Available        bool       `json:"available"`
+	// AvailableUntil is when the bike has to be back because someone else has booked it
+	AvailableUntil *time.Time `json:"availableUntil,omitempty"`
+	// AvailableFrom is when an unavailable bike is expected to be free again
+	AvailableFrom     *time.Time          `json:"availableFrom,omitempty"`
+	UnavailableReason availability.Reason `json:"unavailableReason,omitempty"`
+	StationName       string              `json:"stationName"`
+	Model             *bikeModelResponse  `json:"model,omitempty"`
+}
+
+// bikeModelResponse is the part of a bike's model shown with the bike.
+type bikeModelResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	Name        string          `json:"name"`
+	ImageURL    *string         `json:"imageUrl,omitempty"`
+	Capacity    *int32          `json:"capacity,omitempty"`
+	CargoType   *bike.CargoType `json:"cargoType,omitempty"`
+	Description string          `json:"description,omitempty"`
 }
 
-func toBikeResponse(bike bike.Bike) bikeResponse {
+// toBikeModelResponse returns the bike's model, or nil if it doesn't have one.
+func toBikeModelResponse(b bike.Bike) *bikeModelResponse {
+	if !b.ModelID.Valid {
+		return nil
+	}
+	m := &bikeModelResponse{
+		ID:        b.ModelID.UUID,
+		ImageURL:  b.ImageURL,
+		CargoType: b.CargoType,
+	}
+	if b.ModelName != nil {
+		m.Name = *b.ModelName
+	}
+	if b.Capacity.Valid {
+		m.Capacity = &b.Capacity.Int32
+	}
+	if b.ModelDescription != nil {
+		m.Description = *b.ModelDescription
+	}
+	return m
+}
+
+func toBikeResponse(bike bike.Bike, avail availability.Result, curves bike.BatteryCurves) bikeResponse {
 	br := bikeResponse{
-		ID:             bike.ID,
-		Label:          bike.Label,
-		IMEI:           bike.IMEI,
-		Lat:            bike.Location.P.X,
-		Lng:            bike.Location.P.Y,
-		BatteryVoltage: voltageToPercentage(340),
-		Available:      bike.Available,
+		ID:                bike.ID,
+		Label:             bike.Label,
+		IMEI:              bike.IMEI,
+		Lat:               bike.Location.Lat,
+		Lng:               bike.Location.Lng,
+		Available:         avail.Available,
+		AvailableUntil:    avail.Until,
+		AvailableFrom:     avail.From,
+		UnavailableReason: avail.Reason,
+		Model:             toBikeModelResponse(bike),
+	}
+	if bike.BatteryVoltage.Valid {
+		br.BatteryVoltage = curves.For(bike.BatteryModel).Percentage(int(bike.BatteryVoltage.Int32))
+		br.BatteryUpdatedAt = &bike.TelemetryUpdatedAt.Time
 	}
 	if bike.StationName != nil {
 		br.StationName = *bike.StationName
 	}
-	if bike.DisplayName != nil {
-		br.DisplayName = *bike.DisplayName
+	if bike.ModelName != nil {
+		br.DisplayName = *bike.ModelName
 	}
 	return br
 }
 
+// bikeInService checks that a bike is active so it can be booked or ridden. If it isn't a response
+// has been written and false is returned.
+func bikeInService(c *gin.Context, b bike.Bike) bool {
+	switch b.State {
+	case bike.StateActive:
+		return true
+	case bike.StateRetired:
+		c.JSON(http.StatusNotFound, gin.H{"code": "BIKE_NOT_FOUND", "message": "Bike not found"})
+	default:
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    "BIKE_OUT_OF_SERVICE",
+			"message": "This bike is out of service",
+			"state":   b.State,
+		})
+	}
+	return false
+}
+
 type upcomingBookingCheckResponse struct {
 	HasUpcomingBooking      bool       `json:"hasUpcomingBooking"`
 	NextBookingStart        *time.Time `json:"nextBookingStart,omitempty"`
 	MinutesUntilNextBooking *int       `json:"minutesUntilNextBooking,omitempty"`
+	Available               bool       `json:"available"`
+	AvailableUntil          *time.Time `json:"availableUntil,omitempty"`
 }
 
 func (a *API) upcomingBookingCheckHandler(c *gin.Context) {
 	logger := middleware.GetLogger(c)
 
-	userID, ok := middleware.GetAuth0ID(c)
-	if !ok {
+	if _, ok := middleware.GetAuth0ID(c); !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "Authentication required"})
 		return
 	}
@@ -90,7 +193,7 @@ func (a *API) upcomingBookingCheckHandler(c *gin.Context) {
 	label := c.Param("label")
 
 	// Verify bike exists
-	_, err := a.br.GetBike(c, label)
+	b, err := a.br.GetBike(c, label)
 	if err != nil {
 		if errors.Is(err, bike.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"code": "BIKE_NOT_FOUND", "message": "Bike not found"})
@@ -101,23 +204,44 @@ func (a *API) upcomingBookingCheckHandler(c *gin.Context) {
 		return
 	}
 
-	// Check for upcoming booking by another user
+	customerID, err := a.currentCustomerID(c)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	hidden, err := a.hiddenBike(c, b, customerID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get hidden stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if hidden {
+		c.JSON(http.StatusNotFound, gin.H{"code": "BIKE_NOT_FOUND", "message": "Bike not found"})
+		return
+	}
+
 	now := time.Now()
-	nextBooking, err := a.bkr.GetNextBookingByOtherUser(c, label, userID, now)
+	avail, err := a.avail.Check(c, b, customerID, now)
 	if err != nil {
-		logger.ErrorContext(c, "failed to check upcoming bookings", "error", err)
+		logger.ErrorContext(c, "failed to check availability", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
 	resp := upcomingBookingCheckResponse{
 		HasUpcomingBooking: false,
+		Available:          avail.Available,
+		AvailableUntil:     avail.Until,
 	}
 
-	if nextBooking != nil && nextBooking.StartTime.Before(now.Add(time.Hour)) {
+	// Check for upcoming booking by another user
+	next := avail.NextBooking
+	if next != nil && next.StartTime.After(now) && next.StartTime.Before(now.Add(availability.BookingBuffer)) {
 		resp.HasUpcomingBooking = true
-		resp.NextBookingStart = &nextBooking.StartTime
-		minutes := int(nextBooking.StartTime.Sub(now).Minutes())
+		resp.NextBookingStart = &next.StartTime
+		minutes := int(next.StartTime.Sub(now).Minutes())
 		resp.MinutesUntilNextBooking = &minutes
 	}
 
@@ -12,6 +12,7 @@ import (
 
 	"github.com/semanticallynull/bookingengine-backend/bike"
 	"github.com/semanticallynull/bookingengine-backend/booking"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
 	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
 )
 
@@ -142,15 +143,33 @@ func (a *API) createBookingHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
+	if !bikeInService(c, bk) {
+		return
+	}
+
+	// Only members can book the bikes at a private station
+	hidden, err := a.sr.GetHiddenStations(c, user.ID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get hidden stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if atHiddenStation(bk, hidden) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    "PRIVATE_STATION",
+			"message": "This bike belongs to a private station you aren't a member of",
+		})
+		return
+	}
 
 	// Check for buffer conflict: another user's booking within 1 hour of our end time
-	nextBooking, err := a.bkr.GetNextBookingByOtherUser(c, bikeID, userID, endTime)
+	nextBooking, err := a.bkr.GetNextBookingByOtherUser(c, bikeID, user.ID.String(), endTime)
 	if err != nil {
 		logger.ErrorContext(c, "failed to check for buffer conflict", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
-	if nextBooking != nil && nextBooking.StartTime.Before(endTime.Add(time.Hour)) {
+	if nextBooking != nil && nextBooking.StartTime.Before(endTime.Add(availability.BookingBuffer)) {
 		c.JSON(http.StatusConflict, gin.H{
 			"code":    "BUFFER_CONFLICT",
 			"message": "Another booking starts within 1 hour of your booking's end time",
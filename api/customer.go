@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v84"
@@ -20,7 +21,8 @@ func (a *API) createCustomerSession(c *gin.Context) {
 	userID, _ := middleware.GetAuth0ID(c)
 
 	// Fetch user profile from Auth0 (best effort)
-	var email, name string
+	var email, name, verifiedEmail string
+	var fetched bool
 	if accessToken, ok := c.Get("access_token"); ok {
 		if token, ok := accessToken.(string); ok && token != "" {
 			userInfo, err := a.auth0Client.GetUserInfo(c, token)
@@ -29,6 +31,10 @@ func (a *API) createCustomerSession(c *gin.Context) {
 			} else {
 				email = userInfo.Email
 				name = userInfo.Name
+				if userInfo.EmailVerified {
+					verifiedEmail = userInfo.Email
+				}
+				fetched = true
 			}
 		}
 	}
@@ -67,6 +73,14 @@ func (a *API) createCustomerSession(c *gin.Context) {
 		}
 	}
 
+	// Private stations admit customers by the domain of their verified email only, as the profile's
+	// email can be set to anything
+	if fetched && !strings.EqualFold(verifiedEmail, cust.VerifiedEmail.String) {
+		if err := a.cr.SetVerifiedEmail(c, userID, verifiedEmail); err != nil {
+			logger.WarnContext(c, "failed to update customer's verified email", "error", err)
+		}
+	}
+
 	if !cust.StripeID.Valid {
 
 		stripeCustomer, err := stripecustomer.New(&stripe.CustomerParams{
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/gdpr"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+)
+
+type customerExportResponse struct {
+	ID        uuid.UUID             `json:"id"`
+	Status    customer.ExportStatus `json:"status"`
+	CreatedAt time.Time             `json:"createdAt"`
+	// DownloadURL and ExpiresAt are set once the export is ready
+	DownloadURL string     `json:"downloadUrl,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (a *API) toCustomerExportResponse(e customer.Export) customerExportResponse {
+	resp := customerExportResponse{
+		ID:        e.ID,
+		Status:    e.Status,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.Status == customer.ExportReady && e.ExpiresAt.Valid {
+		resp.DownloadURL = fmt.Sprintf("/exports/%s/download?expires=%d&signature=%s", e.ID,
+			e.ExpiresAt.Time.Unix(), gdpr.Sign(a.exportKey, e.ID, e.ExpiresAt.Time))
+		resp.ExpiresAt = &e.ExpiresAt.Time
+	}
+	return resp
+}
+
+// customerExportHandler exports everything we hold about the customer. Small archives are returned
+// straight away. Larger ones are generated in the background, and 202 Accepted is returned with the
+// export to poll.
+func (a *API) customerExportHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	userID, _ := middleware.GetAuth0ID(c)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
+	if err != nil {
+		if errors.Is(err, customer.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+			return
+		}
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	records, err := a.cr.CountActivity(c, cust.ID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to count customer activity", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if records > gdpr.MaxSyncRecords {
+		e, err := a.cr.CreateExport(c, cust.ID)
+		if err != nil {
+			logger.ErrorContext(c, "failed to create customer export", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		c.JSON(http.StatusAccepted, a.toCustomerExportResponse(e))
+		return
+	}
+
+	now := time.Now()
+	archive, err := a.collector.Collect(c, cust.ID, now)
+	if err != nil {
+		logger.ErrorContext(c, "failed to collect customer data", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	var buf bytes.Buffer
+	if err := archive.WriteZip(&buf); err != nil {
+		logger.ErrorContext(c, "failed to write customer export", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Content-Disposition", exportDisposition(now))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, gdpr.ContentType, buf.Bytes())
+}
+
+// getCustomerExportHandler returns one of the customer's background exports, with a signed link to
+// download it once it is ready.
+func (a *API) getCustomerExportHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	exportID, err := uuid.Parse(c.Param("exportId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid exportId"})
+		return
+	}
+
+	customerID, err := a.currentCustomerID(c)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	e, err := a.cr.GetExport(c, customerID, exportID)
+	if errors.Is(err, customer.ErrExportNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": "EXPORT_NOT_FOUND", "message": "Export not found"})
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer export", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, a.toCustomerExportResponse(e))
+}
+
+// downloadCustomerExportHandler downloads a background export. It doesn't need a token, as the link
+// is signed, so it can be opened in a browser.
+func (a *API) downloadCustomerExportHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	exportID, err := uuid.Parse(c.Param("exportId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid exportId"})
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid expires"})
+		return
+	}
+
+	err = gdpr.Verify(a.exportKey, exportID, time.Unix(expires, 0), c.Query("signature"), time.Now())
+	switch {
+	case errors.Is(err, gdpr.ErrInvalidSignature):
+		c.JSON(http.StatusForbidden, gin.H{"code": "INVALID_SIGNATURE", "message": "Download link is invalid"})
+		return
+	case errors.Is(err, gdpr.ErrLinkExpired):
+		c.JSON(http.StatusGone, gin.H{"code": "LINK_EXPIRED", "message": "Download link has expired"})
+		return
+	}
+
+	e, err := a.cr.GetExportByID(c, exportID)
+	if errors.Is(err, customer.ErrExportNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": "EXPORT_NOT_FOUND", "message": "Export not found"})
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer export", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	// The archive may have been deleted already
+	if e.Status != customer.ExportReady || !e.BlobKey.Valid {
+		c.JSON(http.StatusGone, gin.H{"code": "LINK_EXPIRED", "message": "Download link has expired"})
+		return
+	}
+
+	r, err := a.blobs.Get(c, e.BlobKey.String)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer export archive", "exportId", e.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	defer r.Close()
+
+	c.DataFromReader(http.StatusOK, -1, gdpr.ContentType, r, map[string]string{
+		"Content-Disposition": exportDisposition(e.CompletedAt.Time),
+		"Cache-Control":       "private, no-store",
+	})
+}
+
+func exportDisposition(t time.Time) string {
+	return `attachment; filename="personal-data-` + t.UTC().Format("2006-01-02") + `.zip"`
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+)
+
+const geoJSONContentType = "application/geo+json"
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type     string   `json:"type"`
+	ID       string   `json:"id"`
+	Geometry geometry `json:"geometry"`
+	// Properties are the station or bike as returned by the rest of the API
+	Properties any `json:"properties"`
+}
+
+type geometry struct {
+	Type string `json:"type"`
+	// Coordinates are longitude then latitude
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func pointFeature(id uuid.UUID, lat, lng float64, properties any) feature {
+	return feature{
+		Type:       "Feature",
+		ID:         id.String(),
+		Geometry:   geometry{Type: "Point", Coordinates: [2]float64{lng, lat}},
+		Properties: properties,
+	}
+}
+
+// publicStationsGeoJSONHandler publishes the public stations, with their live counts, as GeoJSON.
+func (a *API) publicStationsGeoJSONHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	stations, err := a.sr.GetStations()
+	if err != nil {
+		logger.ErrorContext(c, "failed to get stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	// Anonymous callers can't see any private station
+	hidden, err := a.sr.GetHiddenStations(c, uuid.Nil)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get hidden stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	visible := stations[:0]
+	for _, s := range stations {
+		if !hidden[s.ID] {
+			visible = append(visible, s)
+		}
+	}
+
+	ids := make([]uuid.UUID, 0, len(visible))
+	for _, s := range visible {
+		ids = append(ids, s.ID)
+	}
+	now := time.Now()
+	occupancy, err := a.sr.GetOccupancy(c, ids, uuid.Nil, now, now.Add(availability.BookingBuffer))
+	if err != nil {
+		logger.ErrorContext(c, "failed to get station occupancy", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	fc := featureCollection{Type: "FeatureCollection", Features: make([]feature, 0, len(visible))}
+	for _, s := range visible {
+		resp := toStationResponse(s)
+		resp.Occupancy = toStationOccupancyResponse(occupancy[s.ID])
+		fc.Features = append(fc.Features, pointFeature(s.ID, s.Location.Lat, s.Location.Lng, resp))
+	}
+	writeGeoJSON(c, fc)
+}
+
+// bikesGeoJSONHandler exports the active bikes as GeoJSON for the operations map. It is only for
+// admins, as the bikes' IDs and live positions would let anyone follow riders.
+func (a *API) bikesGeoJSONHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	bikes, err := a.br.GetBikesWithStations(c, nil, bike.ModelFilter{})
+	if err != nil {
+		logger.ErrorContext(c, "failed to get bikes with stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	plain := make([]bike.Bike, 0, len(bikes))
+	for _, b := range bikes {
+		nb := b.Bike
+		nb.StationName = &b.StationName
+		plain = append(plain, nb)
+	}
+
+	avail, err := a.avail.CheckAll(c, plain, uuid.Nil, time.Now())
+	if err != nil {
+		logger.ErrorContext(c, "failed to check availability", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	fc := featureCollection{Type: "FeatureCollection", Features: make([]feature, 0, len(plain))}
+	for _, b := range plain {
+		resp := toBikeResponse(b, avail[b.ID], a.batteryCurves)
+		fc.Features = append(fc.Features, pointFeature(b.ID, b.Location.Lat, b.Location.Lng, resp))
+	}
+	writeGeoJSON(c, fc)
+}
+
+func writeGeoJSON(c *gin.Context, fc featureCollection) {
+	body, err := json.Marshal(fc)
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to encode GeoJSON", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	writeWithETag(c, geoJSONContentType, body)
+}
+
+var stationCSVHeader = []string{
+	"id", "name", "address", "type", "latitude", "longitude", "opening_hours", "capacity", "return_radius",
+	"available_bikes", "riding_bikes", "bookings_soon", "free_spaces", "deactivated",
+}
+
+// exportStationsHandler exports every station, including private and deactivated stations, as CSV.
+func (a *API) exportStationsHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	stations, err := a.sr.ListStations(c, true)
+	if err != nil {
+		logger.ErrorContext(c, "failed to list stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(stations))
+	for _, s := range stations {
+		ids = append(ids, s.ID)
+	}
+	now := time.Now()
+	occupancy, err := a.sr.GetOccupancy(c, ids, uuid.Nil, now, now.Add(availability.BookingBuffer))
+	if err != nil {
+		logger.ErrorContext(c, "failed to get station occupancy", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	rows := make([][]string, 0, len(stations))
+	for _, s := range stations {
+		resp := toStationResponse(s)
+		resp.Occupancy = toStationOccupancyResponse(occupancy[s.ID])
+		rows = append(rows, []string{
+			resp.ID.String(),
+			resp.Name,
+			resp.Address,
+			resp.Type.String(),
+			formatCoordinate(resp.Lat),
+			formatCoordinate(resp.Lng),
+			resp.OpeningHours,
+			formatOptionalInt32(resp.Capacity),
+			strconv.Itoa(resp.ReturnRadius),
+			strconv.Itoa(resp.Occupancy.AvailableBikes),
+			strconv.Itoa(resp.Occupancy.RidingBikes),
+			strconv.Itoa(resp.Occupancy.BookingsSoon),
+			formatOptionalInt32(resp.Occupancy.FreeSpaces),
+			strconv.FormatBool(!s.Active()),
+		})
+	}
+	writeCSV(c, "stations", stationCSVHeader, rows)
+}
+
+var bikeCSVHeader = []string{
+	"id", "label", "state", "model", "latitude", "longitude", "battery", "battery_updated_at", "station",
+	"available", "unavailable_reason", "available_until",
+}
+
+// exportBikesHandler exports every bike which hasn't been retired as CSV.
+func (a *API) exportBikesHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	bikes, err := a.br.ListBikes(c, false)
+	if err != nil {
+		logger.ErrorContext(c, "failed to list bikes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	avail, err := a.avail.CheckAll(c, bikes, uuid.Nil, time.Now())
+	if err != nil {
+		logger.ErrorContext(c, "failed to check availability", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	rows := make([][]string, 0, len(bikes))
+	for _, b := range bikes {
+		resp := toBikeResponse(b, avail[b.ID], a.batteryCurves)
+		row := []string{
+			resp.ID.String(),
+			resp.Label,
+			string(b.State),
+			resp.DisplayName,
+			formatCoordinate(resp.Lat),
+			formatCoordinate(resp.Lng),
+			"",
+			"",
+			resp.StationName,
+			strconv.FormatBool(resp.Available),
+			string(resp.UnavailableReason),
+			formatOptionalTime(resp.AvailableUntil),
+		}
+		if resp.BatteryUpdatedAt != nil {
+			row[6] = strconv.Itoa(resp.BatteryVoltage)
+			row[7] = formatOptionalTime(resp.BatteryUpdatedAt)
+		}
+		rows = append(rows, row)
+	}
+	writeCSV(c, "bikes", bikeCSVHeader, rows)
+}
+
+func writeCSV(c *gin.Context, name string, header []string, rows [][]string) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	_ = cw.Write(header)
+	_ = cw.WriteAll(rows)
+	if err := cw.Error(); err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to write CSV", "export", name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+	writeWithETag(c, "text/csv", buf.Bytes())
+}
+
+func formatCoordinate(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+func formatOptionalInt32(i *int32) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(int(*i))
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeWithETag writes the body tagged with a hash of its content, or 304 Not Modified if the client
+// sent the same tag in If-None-Match. Clients are asked to revalidate every time, as the live counts
+// change.
+func writeWithETag(c *gin.Context, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// etagMatches reports whether an If-None-Match header matches the tag. Weak tags match their strong
+// form, as the comparison for If-None-Match is weak.
+func etagMatches(header, etag string) bool {
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
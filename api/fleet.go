@@ -0,0 +1,406 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/booking"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+)
+
+type createBikeRequest struct {
+	Label     string     `json:"label" binding:"required,max=64"`
+	IMEI      string     `json:"imei" binding:"required,max=32"`
+	ModelID   *uuid.UUID `json:"modelId"`
+	StationID *uuid.UUID `json:"stationId"`
+	Latitude  *float64   `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude *float64   `json:"longitude" binding:"required,min=-180,max=180"`
+}
+
+// updateBikeRequest changes only the fields which are present. An empty stationId or modelId
+// unassigns the bike from its station or model.
+type updateBikeRequest struct {
+	Label     *string  `json:"label" binding:"omitempty,max=64"`
+	IMEI      *string  `json:"imei" binding:"omitempty,max=32"`
+	ModelID   *string  `json:"modelId"`
+	StationID *string  `json:"stationId"`
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+}
+
+type adminBikeResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	Label             string     `json:"label"`
+	IMEI              string     `json:"imei"`
+	ModelID           *uuid.UUID `json:"modelId"`
+	ModelName         *string    `json:"modelName"`
+	BatteryModel      *string    `json:"batteryModel"`
+	StationID         *uuid.UUID `json:"stationId"`
+	StationName       *string    `json:"stationName"`
+	Latitude          float64    `json:"latitude"`
+	Longitude         float64    `json:"longitude"`
+	LocationUpdatedAt *time.Time `json:"locationUpdatedAt,omitempty"`
+	State             bike.State `json:"state"`
+	StateReason       *string    `json:"stateReason,omitempty"`
+	StateChangedAt    *time.Time `json:"stateChangedAt,omitempty"`
+}
+
+type setBikeStateRequest struct {
+	State  bike.State `json:"state" binding:"required"`
+	Reason string     `json:"reason" binding:"max=500"`
+}
+
+type setBikeStateResponse struct {
+	Bike adminBikeResponse `json:"bike"`
+	// ClashingBookings are the bookings of the bike which can't be honoured now that it has been taken
+	// off the road
+	ClashingBookings []clashingBookingResponse `json:"clashingBookings"`
+}
+
+type clashingBookingResponse struct {
+	ID         uuid.UUID `json:"id"`
+	CustomerID uuid.UUID `json:"customerId"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+}
+
+type stateChangeResponse struct {
+	FromState bike.State `json:"fromState"`
+	ToState   bike.State `json:"toState"`
+	Reason    *string    `json:"reason,omitempty"`
+	ChangedBy string     `json:"changedBy"`
+	ChangedAt time.Time  `json:"changedAt"`
+}
+
+func toAdminBikeResponse(b bike.Bike) adminBikeResponse {
+	resp := adminBikeResponse{
+		ID:           b.ID,
+		Label:        b.Label,
+		IMEI:         b.IMEI,
+		ModelName:    b.ModelName,
+		BatteryModel: b.BatteryModel,
+		StationID:    b.StationID,
+		StationName:  b.StationName,
+		Latitude:     b.Location.Lat,
+		Longitude:    b.Location.Lng,
+		State:        b.State,
+		StateReason:  b.StateReason,
+	}
+	if b.ModelID.Valid {
+		resp.ModelID = &b.ModelID.UUID
+	}
+	if b.LocationUpdatedAt.Valid {
+		resp.LocationUpdatedAt = &b.LocationUpdatedAt.Time
+	}
+	if b.StateChangedAt.Valid {
+		resp.StateChangedAt = &b.StateChangedAt.Time
+	}
+	return resp
+}
+
+func (a *API) listFleetHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	bikes, err := a.br.ListBikes(c, c.Query("includeRetired") == "true")
+	if err != nil {
+		logger.ErrorContext(c, "failed to list bikes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]adminBikeResponse, 0, len(bikes))
+	for _, b := range bikes {
+		resp = append(resp, toAdminBikeResponse(b))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a *API) getFleetBikeHandler(c *gin.Context) {
+	b, ok := a.fleetBike(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toAdminBikeResponse(b))
+}
+
+func (a *API) createFleetBikeHandler(c *gin.Context) {
+	var req createBikeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+
+	if !a.validateLabel(c, req.Label) {
+		return
+	}
+
+	b := bike.Bike{
+		ID:        uuid.New(),
+		Label:     req.Label,
+		IMEI:      req.IMEI,
+		Location:  geo.Point{Lat: *req.Latitude, Lng: *req.Longitude},
+		StationID: req.StationID,
+	}
+	if req.ModelID != nil {
+		b.ModelID = uuid.NullUUID{UUID: *req.ModelID, Valid: true}
+	}
+	if err := a.br.Create(c, &b); err != nil {
+		fleetWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAdminBikeResponse(b))
+}
+
+func (a *API) updateFleetBikeHandler(c *gin.Context) {
+	var req updateBikeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+
+	b, ok := a.fleetBike(c)
+	if !ok {
+		return
+	}
+
+	if req.Label != nil && *req.Label != b.Label {
+		if !a.validateLabel(c, *req.Label) {
+			return
+		}
+		b.Label = *req.Label
+	}
+	if req.IMEI != nil {
+		b.IMEI = *req.IMEI
+	}
+	if req.ModelID != nil {
+		if *req.ModelID == "" {
+			b.ModelID = uuid.NullUUID{}
+		} else {
+			modelID, err := uuid.Parse(*req.ModelID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid modelId"})
+				return
+			}
+			b.ModelID = uuid.NullUUID{UUID: modelID, Valid: true}
+		}
+	}
+	if req.StationID != nil {
+		if *req.StationID == "" {
+			b.StationID = nil
+		} else {
+			stationID, err := uuid.Parse(*req.StationID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid stationId"})
+				return
+			}
+			b.StationID = &stationID
+		}
+	}
+	if req.Latitude != nil || req.Longitude != nil {
+		if req.Latitude != nil {
+			b.Location.Lat = *req.Latitude
+		}
+		if req.Longitude != nil {
+			b.Location.Lng = *req.Longitude
+		}
+	}
+
+	if err := a.br.Update(c, &b); err != nil {
+		fleetWriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toAdminBikeResponse(b))
+}
+
+// retireFleetBikeHandler soft deletes a bike. The bike is kept so that its rides and bookings stay
+// intact, but it is no longer offered to customers.
+func (a *API) retireFleetBikeHandler(c *gin.Context) {
+	b, ok := a.fleetBike(c)
+	if !ok {
+		return
+	}
+	if b.State == bike.StateRetired {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if _, _, ok := a.setBikeState(c, b, bike.StateRetired, "Retired"); !ok {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// setFleetBikeStateHandler moves a bike through its lifecycle, e.g. into the workshop and back. Any
+// bookings which clash with the bike being taken off the road are returned so they can be dealt with.
+func (a *API) setFleetBikeStateHandler(c *gin.Context) {
+	var req setBikeStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+	if !req.State.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_STATE", "message": "Unknown bike state"})
+		return
+	}
+
+	b, ok := a.fleetBike(c)
+	if !ok {
+		return
+	}
+
+	b, clashes, ok := a.setBikeState(c, b, req.State, req.Reason)
+	if !ok {
+		return
+	}
+
+	resp := setBikeStateResponse{
+		Bike:             toAdminBikeResponse(b),
+		ClashingBookings: make([]clashingBookingResponse, 0, len(clashes)),
+	}
+	for _, bk := range clashes {
+		resp.ClashingBookings = append(resp.ClashingBookings, clashingBookingResponse{
+			ID:         bk.ID,
+			CustomerID: bk.UserID,
+			StartTime:  bk.StartTime,
+			EndTime:    bk.EndTime,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a *API) fleetBikeStateHistoryHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	b, ok := a.fleetBike(c)
+	if !ok {
+		return
+	}
+
+	changes, err := a.br.GetStateHistory(c, b.ID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get bike state history", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]stateChangeResponse, 0, len(changes))
+	for _, sc := range changes {
+		r := stateChangeResponse{
+			FromState: sc.FromState,
+			ToState:   sc.ToState,
+			ChangedBy: sc.ChangedBy,
+			ChangedAt: sc.ChangedAt,
+		}
+		if sc.Reason.Valid {
+			r.Reason = &sc.Reason.String
+		}
+		resp = append(resp, r)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// setBikeState moves a bike to a new state on behalf of the admin making the request. When the bike
+// is taken off the road its upcoming bookings are returned and ops are told about them. If the change
+// fails a response has been written and ok is false.
+func (a *API) setBikeState(c *gin.Context, b bike.Bike, to bike.State, reason string) (bike.Bike, []booking.Booking, bool) {
+	logger := middleware.GetLogger(c)
+
+	changedBy, _ := middleware.GetAuth0ID(c)
+	updated, err := a.br.SetState(c, b.ID, to, reason, changedBy)
+	if err != nil {
+		if errors.Is(err, bike.ErrInvalidTransition) {
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    "INVALID_STATE_TRANSITION",
+				"message": fmt.Sprintf("A bike can't go from %s to %s", b.State, to),
+			})
+			return bike.Bike{}, nil, false
+		}
+		logger.ErrorContext(c, "failed to set bike state", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return bike.Bike{}, nil, false
+	}
+
+	if b.State != bike.StateActive || to == bike.StateActive {
+		return updated, nil, true
+	}
+
+	clashes, err := a.bkr.GetUpcomingBookingsForBike(c, b.ID, time.Now())
+	if err != nil {
+		// The state has changed, so report the failure without failing the request
+		logger.ErrorContext(c, "failed to get clashing bookings", "error", err, "bikeId", b.ID)
+		return updated, nil, true
+	}
+	if len(clashes) > 0 {
+		subject := fmt.Sprintf("Bike %s has bookings but is now %s", b.Label, to)
+		if err := a.notifier.NotifyOps(c, subject, clashingBookingsMessage(clashes)); err != nil {
+			logger.WarnContext(c, "failed to notify ops of clashing bookings", "error", err)
+		}
+	}
+	return updated, clashes, true
+}
+
+// clashingBookingsMessage describes bookings which can't go ahead, for a message to ops.
+func clashingBookingsMessage(clashes []booking.Booking) string {
+	if len(clashes) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n%d booking(s) clash:", len(clashes))
+	for _, bk := range clashes {
+		fmt.Fprintf(&sb, "\n- %s: %s to %s", bk.ID, bk.StartTime.Format(time.RFC3339), bk.EndTime.Format(time.RFC3339))
+	}
+	return sb.String()
+}
+
+// fleetBike looks up the bike named by the bikeId parameter. If it can't be found a response has been
+// written and ok is false.
+func (a *API) fleetBike(c *gin.Context) (bike.Bike, bool) {
+	id, err := uuid.Parse(c.Param("bikeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid bikeId"})
+		return bike.Bike{}, false
+	}
+
+	b, err := a.br.GetBikeByID(c, id)
+	if err != nil {
+		if errors.Is(err, bike.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "BIKE_NOT_FOUND", "message": "Bike not found"})
+			return bike.Bike{}, false
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to get bike", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return bike.Bike{}, false
+	}
+	return b, true
+}
+
+// fleetWriteError writes the response for a failure to create or update a bike.
+func fleetWriteError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, bike.ErrLabelTaken):
+		c.JSON(http.StatusConflict, gin.H{"code": "LABEL_TAKEN", "message": "Another bike already has this label"})
+	case errors.Is(err, bike.ErrIMEITaken):
+		c.JSON(http.StatusConflict, gin.H{"code": "IMEI_TAKEN", "message": "Another bike already has this IMEI"})
+	case errors.Is(err, bike.ErrUnknownStation):
+		c.JSON(http.StatusBadRequest, gin.H{"code": "STATION_NOT_FOUND", "message": "Station not found"})
+	case errors.Is(err, bike.ErrUnknownModel):
+		c.JSON(http.StatusBadRequest, gin.H{"code": "MODEL_NOT_FOUND", "message": "Bike model not found"})
+	case errors.Is(err, bike.ErrRetired):
+		c.JSON(http.StatusConflict, gin.H{"code": "BIKE_RETIRED", "message": "Retired bikes can't be changed"})
+	case errors.Is(err, bike.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": "BIKE_NOT_FOUND", "message": "Bike not found"})
+	default:
+		middleware.GetLogger(c).ErrorContext(c, "failed to save bike", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/gbfs"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+)
+
+// gbfsHandler serves the public GBFS feeds at /gbfs/<version>/<feed>.json.
+func (a *API) gbfsHandler(c *gin.Context) {
+	v := gbfs.Version(c.Param("version"))
+	if !v.Valid() {
+		c.JSON(http.StatusNotFound, gin.H{"code": "FEED_NOT_FOUND", "message": "Unknown GBFS version"})
+		return
+	}
+
+	feed, err := a.feeds.Feed(c, v, strings.TrimSuffix(c.Param("feed"), ".json"), time.Now())
+	if err != nil {
+		if errors.Is(err, gbfs.ErrUnknownFeed) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "FEED_NOT_FOUND", "message": "Unknown GBFS feed"})
+			return
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to build GBFS feed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(feed.TTL))
+	c.JSON(http.StatusOK, feed)
+}
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/issue"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+)
+
+const (
+	// maxPhotosPerIssue bounds the number of photos attached to a single issue.
+	maxPhotosPerIssue = 5
+	// maxPhotoSize is the largest photo accepted, in bytes.
+	maxPhotoSize = 10 << 20
+	// issueRideWindow is how long after a ride ends an issue the customer reports with the bike is
+	// linked to the ride.
+	issueRideWindow = time.Hour
+)
+
+// photoExtensions maps the accepted photo content types onto file extensions.
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+type issueRequest struct {
+	Category    issue.Category `json:"category" form:"category" binding:"required"`
+	Description string         `json:"description" form:"description" binding:"max=2000"`
+}
+
+type issueResponse struct {
+	ID          uuid.UUID      `json:"id"`
+	BikeID      uuid.UUID      `json:"bikeId"`
+	RideID      *uuid.UUID     `json:"rideId,omitempty"`
+	Category    issue.Category `json:"category"`
+	Description string         `json:"description"`
+	Status      issue.Status   `json:"status"`
+	Photos      int            `json:"photos"`
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+func toIssueResponse(i issue.Issue, photos int) issueResponse {
+	resp := issueResponse{
+		ID:          i.ID,
+		BikeID:      i.BikeID,
+		Category:    i.Category,
+		Description: i.Description,
+		Status:      i.Status,
+		Photos:      photos,
+		CreatedAt:   i.CreatedAt,
+	}
+	if i.RideID.Valid {
+		resp.RideID = &i.RideID.UUID
+	}
+	return resp
+}
+
+// createIssueHandler reports an issue with a bike. It accepts a multipart form with the category,
+// description and up to maxPhotosPerIssue "photos".
+func (a *API) createIssueHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	userID, _ := middleware.GetAuth0ID(c)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
+	if err != nil {
+		if errors.Is(err, customer.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "Authentication required"})
+			return
+		}
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPhotosPerIssue*maxPhotoSize+1<<20)
+
+	var req issueRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+	if !req.Category.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_CATEGORY", "message": "Unknown issue category"})
+		return
+	}
+
+	var photos []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		photos = form.File["photos"]
+	}
+	if len(photos) > maxPhotosPerIssue {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "TOO_MANY_PHOTOS",
+			"message": fmt.Sprintf("At most %d photos can be attached", maxPhotosPerIssue),
+		})
+		return
+	}
+
+	uploads, ok := readPhotos(c, photos)
+	if !ok {
+		return
+	}
+
+	b, err := a.br.GetBike(c, c.Param("label"))
+	if err != nil {
+		if errors.Is(err, bike.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "BIKE_NOT_FOUND", "message": "Bike not found"})
+			return
+		}
+		logger.ErrorContext(c, "failed to get bike", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	// Issues reported during or just after a ride on the bike are linked to it, so they are trusted
+	// to take the bike out of service
+	var rideID uuid.NullUUID
+	r, err := a.rr.GetRecentRideOnBike(c, cust.ID, b.ID, time.Now().Add(-issueRideWindow))
+	switch {
+	case err == nil:
+		rideID = uuid.NullUUID{UUID: r.ID, Valid: true}
+	case !errors.Is(err, ride.ErrNotFound):
+		logger.ErrorContext(c, "failed to get recent ride", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	i, err := a.reportIssue(c, b, cust.ID, rideID, req)
+	if err != nil {
+		logger.ErrorContext(c, "failed to report issue", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if !a.storePhotos(c, i.ID, uploads) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, toIssueResponse(i, len(uploads)))
+}
+
+// addIssuePhotosHandler attaches more photos to an issue the customer reported, e.g. one reported
+// when ending a ride.
+func (a *API) addIssuePhotosHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	userID, _ := middleware.GetAuth0ID(c)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "Authentication required"})
+		return
+	}
+
+	issueID, err := uuid.Parse(c.Param("issueId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid issueId"})
+		return
+	}
+
+	i, err := a.ir.GetByID(c, issueID)
+	if err != nil {
+		if errors.Is(err, issue.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "ISSUE_NOT_FOUND", "message": "Issue not found"})
+			return
+		}
+		logger.ErrorContext(c, "failed to get issue", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if i.CustomerID.UUID != cust.ID {
+		c.JSON(http.StatusNotFound, gin.H{"code": "ISSUE_NOT_FOUND", "message": "Issue not found"})
+		return
+	}
+
+	existing, err := a.ir.CountAttachments(c, i.ID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to count issue photos", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPhotosPerIssue*maxPhotoSize+1<<20)
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+	photos := form.File["photos"]
+	if len(photos) == 0 || existing+len(photos) > maxPhotosPerIssue {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "TOO_MANY_PHOTOS",
+			"message": fmt.Sprintf("Between 1 and %d photos can be attached", maxPhotosPerIssue-existing),
+		})
+		return
+	}
+
+	uploads, ok := readPhotos(c, photos)
+	if !ok {
+		return
+	}
+	if !a.storePhotos(c, i.ID, uploads) {
+		return
+	}
+
+	c.JSON(http.StatusOK, toIssueResponse(i, existing+len(uploads)))
+}
+
+// reportIssue records an issue and alerts ops. A severe issue reported on a ride takes the bike out of
+// service. Severe issues from customers who haven't ridden the bike are left for ops to check, so a
+// customer can't block a bike with one report.
+func (a *API) reportIssue(c *gin.Context, b bike.Bike, customerID uuid.UUID, rideID uuid.NullUUID,
+	req issueRequest) (issue.Issue, error) {
+	i := issue.Issue{
+		ID:          uuid.New(),
+		BikeID:      b.ID,
+		CustomerID:  uuid.NullUUID{UUID: customerID, Valid: true},
+		RideID:      rideID,
+		Category:    req.Category,
+		Description: req.Description,
+	}
+	if err := a.ir.Create(c, &i); err != nil {
+		return issue.Issue{}, err
+	}
+
+	subject := fmt.Sprintf("Issue reported on bike %s", b.Label)
+	body := fmt.Sprintf("Issue %s: %s\n%s", i.ID, i.Category, i.Description)
+	severe := req.Category.Severe() && b.State == bike.StateActive
+	if severe && !rideID.Valid {
+		subject = fmt.Sprintf("Severe issue reported on bike %s", b.Label)
+		body += "\nThe customer hasn't ridden the bike recently, so it is still in service. Please check it."
+	}
+	if severe && rideID.Valid {
+		reason := fmt.Sprintf("Issue %s: %s", i.ID, i.Category)
+		if _, err := a.br.SetState(c, b.ID, bike.StateOutOfService, reason, bike.ChangedBySystem); err != nil {
+			return i, fmt.Errorf("failed to take bike out of service: %w", err)
+		}
+		subject = fmt.Sprintf("Bike %s taken out of service", b.Label)
+
+		clashes, err := a.bkr.GetUpcomingBookingsForBike(c, b.ID, time.Now())
+		if err != nil {
+			return i, fmt.Errorf("failed to get clashing bookings: %w", err)
+		}
+		body += clashingBookingsMessage(clashes)
+	}
+
+	if err := a.notifier.NotifyOps(c, subject, body); err != nil {
+		middleware.GetLogger(c).WarnContext(c, "failed to notify ops of issue", "error", err)
+	}
+	return i, nil
+}
+
+// photo is an uploaded photo which has passed validation.
+type photo struct {
+	data        []byte
+	contentType string
+}
+
+// readPhotos reads and validates uploaded photos. If a photo is rejected a response has been written
+// and ok is false.
+func readPhotos(c *gin.Context, files []*multipart.FileHeader) ([]photo, bool) {
+	logger := middleware.GetLogger(c)
+
+	photos := make([]photo, 0, len(files))
+	for _, fh := range files {
+		if fh.Size > maxPhotoSize {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "PHOTO_TOO_LARGE", "message": "Photos must be under 10MB"})
+			return nil, false
+		}
+
+		p, err := readPhoto(fh)
+		if err != nil {
+			logger.ErrorContext(c, "failed to read photo", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_PHOTO", "message": "Could not read photo"})
+			return nil, false
+		}
+		if _, ok := photoExtensions[p.contentType]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_PHOTO", "message": "Photos must be JPEG, PNG or WebP"})
+			return nil, false
+		}
+		photos = append(photos, p)
+	}
+	return photos, true
+}
+
+// storePhotos saves photos to blob storage and attaches them to an issue. If storing fails a response
+// has been written and false is returned.
+func (a *API) storePhotos(c *gin.Context, issueID uuid.UUID, photos []photo) bool {
+	logger := middleware.GetLogger(c)
+
+	for _, p := range photos {
+		att := issue.Attachment{
+			ID:          uuid.New(),
+			IssueID:     issueID,
+			ContentType: p.contentType,
+		}
+		att.BlobKey = fmt.Sprintf("issues/%s/%s%s", issueID, att.ID, photoExtensions[p.contentType])
+
+		if err := a.blobs.Put(c, att.BlobKey, bytes.NewReader(p.data)); err != nil {
+			logger.ErrorContext(c, "failed to store photo", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return false
+		}
+		if err := a.ir.AddAttachment(c, &att); err != nil {
+			logger.ErrorContext(c, "failed to record photo", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return false
+		}
+	}
+	return true
+}
+
+// readPhoto reads an uploaded photo and sniffs its content type.
+func readPhoto(fh *multipart.FileHeader) (photo, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return photo{}, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxPhotoSize))
+	if err != nil {
+		return photo{}, err
+	}
+	return photo{data: data, contentType: http.DetectContentType(data)}, nil
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/label"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+)
+
+// maxStickersPerSheet bounds the number of stickers rendered in one request.
+const maxStickersPerSheet = 500
+
+// bikeLabelHandler renders the sticker for one bike.
+func (a *API) bikeLabelHandler(c *gin.Context) {
+	format, ok := labelOutputFormat(c)
+	if !ok {
+		return
+	}
+
+	b, ok := a.fleetBike(c)
+	if !ok {
+		return
+	}
+
+	a.renderStickers(c, []bike.Bike{b}, format, b.Label)
+}
+
+// labelSheetHandler renders a sheet of stickers for the bikes given by bikeId, or for the whole
+// fleet if none are given. The fleet is printed maxStickersPerSheet bikes at a time, chosen by the
+// page query parameter.
+func (a *API) labelSheetHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	format, ok := labelOutputFormat(c)
+	if !ok {
+		return
+	}
+
+	var bikes []bike.Bike
+	if ids := c.QueryArray("bikeId"); len(ids) > 0 {
+		if len(ids) > maxStickersPerSheet {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "TOO_MANY_BIKES",
+				"message": fmt.Sprintf("At most %d labels can be printed at once", maxStickersPerSheet),
+			})
+			return
+		}
+		for _, s := range ids {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid bikeId"})
+				return
+			}
+			b, err := a.br.GetBikeByID(c, id)
+			if err != nil {
+				if errors.Is(err, bike.ErrNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"code": "BIKE_NOT_FOUND", "message": "Bike " + s + " not found"})
+					return
+				}
+				logger.ErrorContext(c, "failed to get bike", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+				return
+			}
+			bikes = append(bikes, b)
+		}
+	} else {
+		n, ok := pageQuery(c)
+		if !ok {
+			return
+		}
+		fleet, err := a.br.ListBikes(c, false)
+		if err != nil {
+			logger.ErrorContext(c, "failed to list bikes", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		if start := (n - 1) * maxStickersPerSheet; start < len(fleet) {
+			bikes = fleet[start:min(start+maxStickersPerSheet, len(fleet))]
+		}
+	}
+
+	if len(bikes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"code": "BIKE_NOT_FOUND", "message": "No bikes to print labels for"})
+		return
+	}
+	if format != label.PDF && len(bikes) > label.SheetSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "TOO_MANY_BIKES",
+			"message": fmt.Sprintf("PNG and SVG hold one sheet of %d labels, use PDF for more", label.SheetSize),
+		})
+		return
+	}
+
+	a.renderStickers(c, bikes, format, "labels")
+}
+
+func (a *API) renderStickers(c *gin.Context, bikes []bike.Bike, format label.OutputFormat, filename string) {
+	stickers := make([]label.Sticker, 0, len(bikes))
+	for _, b := range bikes {
+		stickers = append(stickers, label.Sticker{
+			Label: b.Label,
+			Link:  a.labelLinkBase + url.PathEscape(b.Label),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := label.Render(&buf, stickers, format); err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to render labels", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename+"."+string(format)))
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+// labelOutputFormat reads the format query parameter, which defaults to PDF. If it isn't supported a
+// response has been written and ok is false.
+func labelOutputFormat(c *gin.Context) (label.OutputFormat, bool) {
+	format := label.OutputFormat(c.DefaultQuery("format", string(label.PDF)))
+	if !format.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_FORMAT", "message": "format must be png, svg or pdf"})
+		return "", false
+	}
+	return format, true
+}
+
+// validateLabel checks a label against the configured label format. If it doesn't match a response
+// has been written and false is returned.
+func (a *API) validateLabel(c *gin.Context, l string) bool {
+	err := a.labelFormat.Validate(l)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, label.ErrCheckDigit):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "INVALID_LABEL",
+			"message": "The label's check digit is wrong, please check it was typed correctly",
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_LABEL", "message": "The label isn't in the expected format"})
+	}
+	return false
+}
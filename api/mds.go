@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/mds"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+)
+
+type mdsResponse struct {
+	Version string         `json:"version"`
+	Data    map[string]any `json:"data"`
+	Links   *mdsLinks      `json:"links,omitempty"`
+}
+
+type mdsLinks struct {
+	Next string `json:"next,omitempty"`
+}
+
+// mdsTripsHandler reports the trips which ended in the hour given by end_time.
+func (a *API) mdsTripsHandler(c *gin.Context) {
+	hour, ok := mdsHour(c, "end_time")
+	if !ok {
+		return
+	}
+	n, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+
+	trips, more, err := a.mds.Trips(c, hour, n, time.Now())
+	if !mdsError(c, err) {
+		return
+	}
+	writeMDS(c, "trips", trips, n, more)
+}
+
+// mdsStatusChangesHandler reports the status changes which happened in the hour given by event_time.
+func (a *API) mdsStatusChangesHandler(c *gin.Context) {
+	hour, ok := mdsHour(c, "event_time")
+	if !ok {
+		return
+	}
+	n, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+
+	changes, more, err := a.mds.StatusChanges(c, hour, n, time.Now())
+	if !mdsError(c, err) {
+		return
+	}
+	writeMDS(c, "status_changes", changes, n, more)
+}
+
+// mdsVehiclesHandler reports the current state of the fleet.
+func (a *API) mdsVehiclesHandler(c *gin.Context) {
+	n, ok := pageQuery(c)
+	if !ok {
+		return
+	}
+
+	vehicles, more, err := a.mds.Vehicles(c, n, time.Now())
+	if !mdsError(c, err) {
+		return
+	}
+	writeMDS(c, "vehicles", vehicles, n, more)
+}
+
+// mdsHour reads the hour in the named query parameter. If it is missing or invalid a response has
+// been written and ok is false.
+func mdsHour(c *gin.Context, param string) (time.Time, bool) {
+	hour, err := mds.ParseHour(c.Query(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_HOUR", "message": param + " must be in YYYY-MM-DDTHH format"})
+		return time.Time{}, false
+	}
+	return hour, true
+}
+
+// pageQuery reads the 1-based page query parameter. If it is invalid a response has been written and
+// ok is false.
+func pageQuery(c *gin.Context) (int, bool) {
+	p := c.DefaultQuery("page", "1")
+	n, err := strconv.Atoi(p)
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_PAGE", "message": "page must be a positive number"})
+		return 0, false
+	}
+	return n, true
+}
+
+// mdsError writes the response for an error building a report. It returns true if there was no
+// error.
+func mdsError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, mds.ErrNotProcessed):
+		c.JSON(http.StatusNotFound, gin.H{"code": "NOT_PROCESSED", "message": "The hour has not been processed yet"})
+	default:
+		middleware.GetLogger(c).ErrorContext(c, "failed to build MDS report", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+	return false
+}
+
+// writeMDS writes a page of records, linking to the next page if there is one.
+func writeMDS(c *gin.Context, name string, records any, n int, more bool) {
+	resp := mdsResponse{Version: mds.Version, Data: map[string]any{name: records}}
+	if more {
+		next := *c.Request.URL
+		q := next.Query()
+		q.Set("page", strconv.Itoa(n+1))
+		next.RawQuery = q.Encode()
+		next.Scheme, next.Host = "https", c.Request.Host
+		if c.Request.TLS == nil && c.GetHeader("X-Forwarded-Proto") != "https" {
+			next.Scheme = "http"
+		}
+		resp.Links = &mdsLinks{Next: next.String()}
+	}
+
+	c.Header("Content-Type", mds.ContentType)
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+type joinStationRequest struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+type createInviteRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+	MaxUses   *int32     `json:"maxUses" binding:"omitempty,min=1"`
+}
+
+type inviteResponse struct {
+	Code      string     `json:"code"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxUses   *int32     `json:"maxUses,omitempty"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func toInviteResponse(i station.Invite) inviteResponse {
+	resp := inviteResponse{
+		Code:      i.Code,
+		MaxUses:   nullInt32Ptr(i.MaxUses),
+		Uses:      i.Uses,
+		CreatedAt: i.CreatedAt,
+	}
+	if i.ExpiresAt.Valid {
+		resp.ExpiresAt = &i.ExpiresAt.Time
+	}
+	return resp
+}
+
+type memberResponse struct {
+	CustomerID uuid.UUID `json:"customerId"`
+	Email      string    `json:"email,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	InviteCode string    `json:"inviteCode,omitempty"`
+	JoinedAt   time.Time `json:"joinedAt"`
+}
+
+type emailDomainRequest struct {
+	Domain string `json:"domain" binding:"required,fqdn"`
+}
+
+type emailDomainResponse struct {
+	Domain    string    `json:"domain"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// hiddenStations returns the private stations the customer making the request isn't a member of.
+// Their stations and bikes are hidden from the customer and can't be booked.
+func (a *API) hiddenStations(c *gin.Context) (map[uuid.UUID]bool, error) {
+	customerID, err := a.currentCustomerID(c)
+	if err != nil {
+		return nil, err
+	}
+	return a.sr.GetHiddenStations(c, customerID)
+}
+
+// hiddenStationIDs lists the hidden stations, for queries which leave them out.
+func hiddenStationIDs(hidden map[uuid.UUID]bool) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(hidden))
+	for id := range hidden {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// atHiddenStation reports whether the bike is assigned to one of the hidden stations.
+func atHiddenStation(b bike.Bike, hidden map[uuid.UUID]bool) bool {
+	return b.StationID != nil && hidden[*b.StationID]
+}
+
+// hiddenBike reports whether the bike is at a private station the customer isn't a member of.
+func (a *API) hiddenBike(c *gin.Context, b bike.Bike, customerID uuid.UUID) (bool, error) {
+	if b.StationID == nil {
+		return false, nil
+	}
+	hidden, err := a.sr.GetHiddenStations(c, customerID)
+	if err != nil {
+		return false, err
+	}
+	return atHiddenStation(b, hidden), nil
+}
+
+// joinStationHandler makes the customer a member of a private station using an invite code.
+func (a *API) joinStationHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	userID, _ := middleware.GetAuth0ID(c)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
+	if err != nil {
+		if errors.Is(err, customer.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "Authentication required"})
+			return
+		}
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	var req joinStationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+
+	s, err := a.sr.RedeemInvite(c, req.Code, cust.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, station.ErrInviteNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"code": "INVITE_NOT_FOUND", "message": "Invite code not found"})
+		case errors.Is(err, station.ErrInviteExpired):
+			c.JSON(http.StatusGone, gin.H{"code": "INVITE_EXPIRED", "message": "This invite code has expired"})
+		default:
+			logger.ErrorContext(c, "failed to redeem invite", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, toStationResponse(s))
+}
+
+func (a *API) listInvitesHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	invites, err := a.sr.GetInvites(c, s.ID)
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to get invites", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]inviteResponse, 0, len(invites))
+	for _, i := range invites {
+		resp = append(resp, toInviteResponse(i))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a *API) createInviteHandler(c *gin.Context) {
+	var req createInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+
+	s, ok := a.privateStation(c)
+	if !ok {
+		return
+	}
+
+	inv := station.Invite{StationID: s.ID, MaxUses: int32PtrNull(req.MaxUses)}
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_DATE", "message": "expiresAt must be in the future"})
+			return
+		}
+		inv.ExpiresAt.Time, inv.ExpiresAt.Valid = *req.ExpiresAt, true
+	}
+
+	if err := a.sr.CreateInvite(c, &inv); err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to create invite", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(http.StatusCreated, toInviteResponse(inv))
+}
+
+func (a *API) deleteInviteHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	if err := a.sr.DeleteInvite(c, s.ID, c.Param("code")); err != nil {
+		if errors.Is(err, station.ErrInviteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "INVITE_NOT_FOUND", "message": "Invite code not found"})
+			return
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to delete invite", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (a *API) listMembersHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	members, err := a.sr.GetMembers(c, s.ID)
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to get members", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]memberResponse, 0, len(members))
+	for _, m := range members {
+		resp = append(resp, memberResponse{
+			CustomerID: m.CustomerID,
+			Email:      m.Email.String,
+			Name:       m.Name.String,
+			InviteCode: m.InviteCode.String,
+			JoinedAt:   m.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a *API) removeMemberHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	customerID, err := uuid.Parse(c.Param("customerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid customerId"})
+		return
+	}
+
+	if err := a.sr.RemoveMember(c, s.ID, customerID); err != nil {
+		if errors.Is(err, station.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "MEMBER_NOT_FOUND", "message": "Customer isn't a member"})
+			return
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to remove member", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (a *API) listEmailDomainsHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	domains, err := a.sr.GetEmailDomains(c, s.ID)
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to get email domains", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]emailDomainResponse, 0, len(domains))
+	for _, d := range domains {
+		resp = append(resp, emailDomainResponse{Domain: d.Domain, CreatedAt: d.CreatedAt})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// addEmailDomainHandler admits every customer whose verified email address is at the domain to a
+// private station, e.g. the employees of a workplace.
+func (a *API) addEmailDomainHandler(c *gin.Context) {
+	var req emailDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+
+	s, ok := a.privateStation(c)
+	if !ok {
+		return
+	}
+
+	d, err := a.sr.AddEmailDomain(c, s.ID, req.Domain)
+	if err != nil {
+		if errors.Is(err, station.ErrDomainTaken) {
+			c.JSON(http.StatusConflict, gin.H{"code": "DOMAIN_TAKEN", "message": "The station already admits this domain"})
+			return
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to add email domain", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(http.StatusCreated, emailDomainResponse{Domain: d.Domain, CreatedAt: d.CreatedAt})
+}
+
+func (a *API) removeEmailDomainHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	if err := a.sr.RemoveEmailDomain(c, s.ID, c.Param("domain")); err != nil {
+		if errors.Is(err, station.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "DOMAIN_NOT_FOUND", "message": "The station doesn't admit this domain"})
+			return
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to remove email domain", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// privateStation fetches the station named by the stationId path parameter and checks it is an
+// active private station, which members can be added to. If not a response has been written and ok
+// is false.
+func (a *API) privateStation(c *gin.Context) (station.Station, bool) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return s, false
+	}
+	if s.Type != station.Private || !s.Active() {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    "STATION_NOT_PRIVATE",
+			"message": "Only active private stations have members",
+		})
+		return s, false
+	}
+	return s, true
+}
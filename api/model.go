@@ -0,0 +1,222 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+)
+
+type bikeModelRequest struct {
+	Name         string         `json:"name" binding:"required,max=200"`
+	ImageURL     *string        `json:"imageUrl" binding:"omitempty,url"`
+	Capacity     *int32         `json:"capacity" binding:"omitempty,min=1"`
+	CargoType    bike.CargoType `json:"cargoType" binding:"required"`
+	Description  string         `json:"description" binding:"max=2000"`
+	BatteryModel *string        `json:"batteryModel"`
+	UnlockFee    *int32         `json:"unlockFee" binding:"omitempty,min=0"`
+	PerMinute    *int32         `json:"perMinute" binding:"omitempty,min=0"`
+}
+
+type catalogModelResponse struct {
+	ID           uuid.UUID      `json:"id"`
+	Name         string         `json:"name"`
+	ImageURL     *string        `json:"imageUrl,omitempty"`
+	Capacity     *int32         `json:"capacity,omitempty"`
+	CargoType    bike.CargoType `json:"cargoType"`
+	Description  string         `json:"description"`
+	BatteryModel *string        `json:"batteryModel,omitempty"`
+	// UnlockFee and PerMinute are the model's own tariff in cents, if it has one
+	UnlockFee *int32    `json:"unlockFee,omitempty"`
+	PerMinute *int32    `json:"perMinute,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func toCatalogModelResponse(m bike.Model) catalogModelResponse {
+	return catalogModelResponse{
+		ID:           m.ID,
+		Name:         m.Name,
+		ImageURL:     m.ImageURL,
+		Capacity:     nullInt32Ptr(m.Capacity),
+		CargoType:    m.CargoType,
+		Description:  m.Description,
+		BatteryModel: m.BatteryModel,
+		UnlockFee:    nullInt32Ptr(m.UnlockFee),
+		PerMinute:    nullInt32Ptr(m.PerMinute),
+		CreatedAt:    m.CreatedAt,
+	}
+}
+
+func nullInt32Ptr(n sql.NullInt32) *int32 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int32
+}
+
+func int32PtrNull(p *int32) sql.NullInt32 {
+	if p == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *p, Valid: true}
+}
+
+// bikeModelFilter reads the model and type query parameters bike searches can be filtered by. If
+// either is invalid a response has been written and ok is false.
+func bikeModelFilter(c *gin.Context) (bike.ModelFilter, bool) {
+	var filter bike.ModelFilter
+	if m := c.Query("model"); m != "" {
+		id, err := uuid.Parse(m)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid model"})
+			return filter, false
+		}
+		filter.ModelID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if t := bike.CargoType(c.Query("type")); t != "" {
+		if !t.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_TYPE", "message": "type must be box, longtail or trike"})
+			return filter, false
+		}
+		filter.CargoType = t
+	}
+	return filter, true
+}
+
+// modelsHandler lists the bike model catalog, so riders can filter bikes by model.
+func (a *API) modelsHandler(c *gin.Context) {
+	models, err := a.br.ListModels(c)
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to list bike models", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]catalogModelResponse, 0, len(models))
+	for _, m := range models {
+		resp = append(resp, toCatalogModelResponse(m))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a *API) getModelHandler(c *gin.Context) {
+	m, ok := a.catalogModel(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toCatalogModelResponse(m))
+}
+
+func (a *API) createModelHandler(c *gin.Context) {
+	var req bikeModelRequest
+	if !bindBikeModelRequest(c, &req) {
+		return
+	}
+
+	m := bike.Model{ID: uuid.New()}
+	req.apply(&m)
+	if err := a.br.CreateModel(c, &m); err != nil {
+		modelWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toCatalogModelResponse(m))
+}
+
+// updateModelHandler replaces a model's details. The changes apply to every bike of the model.
+func (a *API) updateModelHandler(c *gin.Context) {
+	var req bikeModelRequest
+	if !bindBikeModelRequest(c, &req) {
+		return
+	}
+
+	m, ok := a.catalogModel(c)
+	if !ok {
+		return
+	}
+
+	req.apply(&m)
+	if err := a.br.UpdateModel(c, &m); err != nil {
+		modelWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toCatalogModelResponse(m))
+}
+
+// deleteModelHandler removes a model from the catalog once no bikes belong to it.
+func (a *API) deleteModelHandler(c *gin.Context) {
+	m, ok := a.catalogModel(c)
+	if !ok {
+		return
+	}
+
+	if err := a.br.DeleteModel(c, m.ID); err != nil {
+		modelWriteError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func bindBikeModelRequest(c *gin.Context, req *bikeModelRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return false
+	}
+	if !req.CargoType.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_TYPE", "message": "cargoType must be box, longtail or trike"})
+		return false
+	}
+	return true
+}
+
+func (req bikeModelRequest) apply(m *bike.Model) {
+	m.Name = req.Name
+	m.ImageURL = req.ImageURL
+	m.Capacity = int32PtrNull(req.Capacity)
+	m.CargoType = req.CargoType
+	m.Description = req.Description
+	m.BatteryModel = req.BatteryModel
+	m.UnlockFee = int32PtrNull(req.UnlockFee)
+	m.PerMinute = int32PtrNull(req.PerMinute)
+}
+
+// catalogModel fetches the model named by the modelId path parameter. If it can't be found a response
+// has been written and ok is false.
+func (a *API) catalogModel(c *gin.Context) (bike.Model, bool) {
+	id, err := uuid.Parse(c.Param("modelId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid modelId"})
+		return bike.Model{}, false
+	}
+
+	m, err := a.br.GetModel(c, id)
+	if err != nil {
+		if errors.Is(err, bike.ErrModelNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "MODEL_NOT_FOUND", "message": "Bike model not found"})
+			return bike.Model{}, false
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to get bike model", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return bike.Model{}, false
+	}
+	return m, true
+}
+
+func modelWriteError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, bike.ErrModelNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"code": "MODEL_NAME_TAKEN", "message": "Another model already has this name"})
+	case errors.Is(err, bike.ErrModelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": "MODEL_NOT_FOUND", "message": "Bike model not found"})
+	case errors.Is(err, bike.ErrModelInUse):
+		c.JSON(http.StatusConflict, gin.H{"code": "MODEL_IN_USE", "message": "Bikes still belong to this model"})
+	default:
+		middleware.GetLogger(c).ErrorContext(c, "failed to save bike model", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
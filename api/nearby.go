@@ -0,0 +1,206 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+const (
+	// defaultNearbyRadius and maxNearbyRadius bound nearby searches, in metres.
+	defaultNearbyRadius = 1000
+	maxNearbyRadius     = 50000
+	// defaultNearbyLimit and maxNearbyLimit bound the number of results of nearby searches.
+	defaultNearbyLimit = 50
+	maxNearbyLimit     = 200
+)
+
+type nearbyQuery struct {
+	center geo.Point
+	radius float64
+	limit  int
+}
+
+// parseNearbyQuery reads the near=lat,lng, radius and limit query parameters. If they are invalid a
+// response has been written and ok is false.
+func parseNearbyQuery(c *gin.Context) (nearbyQuery, bool) {
+	center, err := geo.ParsePoint(c.Query("near"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_LOCATION", "message": "near must be latitude,longitude"})
+		return nearbyQuery{}, false
+	}
+
+	q := nearbyQuery{center: center, radius: defaultNearbyRadius, limit: defaultNearbyLimit}
+	if r := c.Query("radius"); r != "" {
+		q.radius, err = strconv.ParseFloat(r, 64)
+		if err != nil || q.radius <= 0 || q.radius > maxNearbyRadius {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "INVALID_RADIUS",
+				"message": "radius must be between 0 and 50000 metres",
+			})
+			return nearbyQuery{}, false
+		}
+	}
+	if l := c.Query("limit"); l != "" {
+		q.limit, err = strconv.Atoi(l)
+		if err != nil || q.limit <= 0 || q.limit > maxNearbyLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_LIMIT", "message": "limit must be between 1 and 200"})
+			return nearbyQuery{}, false
+		}
+	}
+	return q, true
+}
+
+type nearbyStationResponse struct {
+	stationResponse
+	// Distance is how far the station is from the point searched around, in metres
+	Distance       float64 `json:"distance"`
+	AvailableBikes int     `json:"availableBikes"`
+}
+
+// nearbyStationsHandler finds the stations near a point, nearest first. They can be filtered by
+// type, and to the stations which have a bike available now.
+func (a *API) nearbyStationsHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	q, ok := parseNearbyQuery(c)
+	if !ok {
+		return
+	}
+
+	var stationType *station.Type
+	switch c.Query("type") {
+	case "":
+	case "public":
+		t := station.Public
+		stationType = &t
+	case "private":
+		t := station.Private
+		stationType = &t
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_TYPE", "message": "type must be public or private"})
+		return
+	}
+
+	// The hidden stations are left out by the query, so they don't count towards the limit
+	hidden, err := a.hiddenStations(c)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get hidden stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	stations, err := a.sr.GetNearby(c, q.center, q.radius, stationType, hiddenStationIDs(hidden), q.limit)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get nearby stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	plain := make([]station.Station, 0, len(stations))
+	for _, s := range stations {
+		plain = append(plain, s.Station)
+	}
+	occupancy, err := a.stationOccupancy(c, plain)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get station occupancy", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	onlyAvailable := c.Query("available") == "true"
+	resp := make([]nearbyStationResponse, 0, len(stations))
+	for _, s := range stations {
+		o := occupancy[s.ID]
+		if onlyAvailable && o.AvailableBikes == 0 {
+			continue
+		}
+		sr := toStationResponse(s.Station)
+		sr.Occupancy = toStationOccupancyResponse(o)
+		resp = append(resp, nearbyStationResponse{
+			stationResponse: sr,
+			Distance:        s.Distance,
+			AvailableBikes:  o.AvailableBikes,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+type nearbyBikeResponse struct {
+	bikeResponse
+	// Distance is how far the bike is from the point searched around, in metres
+	Distance float64 `json:"distance"`
+}
+
+// nearbyBikesHandler finds the active bikes near a point, nearest first. They can be filtered by
+// model or cargo type, and to the bikes which are available now.
+func (a *API) nearbyBikesHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	q, ok := parseNearbyQuery(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := bikeModelFilter(c)
+	if !ok {
+		return
+	}
+
+	customerID, err := a.currentCustomerID(c)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	hidden, err := a.sr.GetHiddenStations(c, customerID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get hidden stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	// The bikes at hidden stations are left out by the query, so they don't count towards the limit
+	bikes, err := a.br.GetNearby(c, q.center, q.radius, filter, hiddenStationIDs(hidden), q.limit)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get nearby bikes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	plain := make([]bike.Bike, 0, len(bikes))
+	for _, b := range bikes {
+		plain = append(plain, b.Bike)
+	}
+	avail, err := a.avail.CheckAll(c, plain, customerID, time.Now())
+	if err != nil {
+		logger.ErrorContext(c, "failed to check availability", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	onlyAvailable := c.Query("available") == "true"
+	resp := make([]nearbyBikeResponse, 0, len(bikes))
+	for _, b := range bikes {
+		result := avail[b.ID]
+		if onlyAvailable && !result.Available {
+			continue
+		}
+
+		nb := b.Bike
+		nb.StationName = &b.StationName
+		resp = append(resp, nearbyBikeResponse{
+			bikeResponse: toBikeResponse(nb, result, a.batteryCurves),
+			Distance:     b.Distance,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/issue"
+	"github.com/semanticallynull/bookingengine-backend/rating"
+	riderepo "github.com/semanticallynull/bookingengine-backend/ride"
+)
+
+type ratingRequest struct {
+	Score   int          `json:"score" binding:"required,min=1,max=5"`
+	Tags    []rating.Tag `json:"tags" binding:"max=10"`
+	Comment string       `json:"comment" binding:"max=2000"`
+}
+
+type ratingResponse struct {
+	ID        uuid.UUID    `json:"id"`
+	RideID    uuid.UUID    `json:"rideId"`
+	Score     int          `json:"score"`
+	Tags      []rating.Tag `json:"tags"`
+	Comment   string       `json:"comment"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type ratingAggregateResponse struct {
+	Ratings      int              `json:"ratings"`
+	AverageScore float64          `json:"averageScore"`
+	LowRatings   int              `json:"lowRatings"`
+	LastRatedAt  *time.Time       `json:"lastRatedAt,omitempty"`
+	Tags         rating.TagCounts `json:"tags"`
+}
+
+type bikeRatingsResponse struct {
+	BikeID    uuid.UUID `json:"bikeId"`
+	BikeLabel string    `json:"bikeLabel"`
+	ratingAggregateResponse
+}
+
+type stationRatingsResponse struct {
+	StationID   uuid.UUID `json:"stationId"`
+	StationName string    `json:"stationName"`
+	ratingAggregateResponse
+}
+
+func toRatingAggregateResponse(agg rating.Aggregate) ratingAggregateResponse {
+	resp := ratingAggregateResponse{
+		Ratings:      agg.Ratings,
+		AverageScore: agg.AverageScore,
+		LowRatings:   agg.LowRatings,
+		Tags:         agg.TagCounts,
+	}
+	if agg.LastRatedAt.Valid {
+		resp.LastRatedAt = &agg.LastRatedAt.Time
+	}
+	if resp.Tags == nil {
+		resp.Tags = rating.TagCounts{}
+	}
+	return resp
+}
+
+// rateRideHandler records the customer's rating of a ride they have finished.
+func (a *API) rateRideHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	userID, _ := middleware.GetAuth0ID(c)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
+	if err != nil {
+		if errors.Is(err, customer.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "Authentication required"})
+			return
+		}
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	rideID, err := uuid.Parse(c.Param("rideId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Invalid rideId"})
+		return
+	}
+
+	var req ratingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+	for _, t := range req.Tags {
+		if !t.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_TAG", "message": fmt.Sprintf("Unknown tag %q", t)})
+			return
+		}
+	}
+
+	ride, err := a.rr.GetRide(c, rideID)
+	if err != nil && !errors.Is(err, riderepo.ErrNotFound) {
+		logger.ErrorContext(c, "failed to get ride", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if err != nil || ride.CustomerID != cust.ID {
+		c.JSON(http.StatusNotFound, gin.H{"code": "RIDE_NOT_FOUND", "message": "Ride not found"})
+		return
+	}
+	if !ride.EndedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"code": "RIDE_NOT_ENDED", "message": "Rides can be rated once they have ended"})
+		return
+	}
+	if time.Since(ride.EndedAt.Time) > rating.Window {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    "RATING_WINDOW_CLOSED",
+			"message": "Rides can only be rated within 24 hours of ending",
+		})
+		return
+	}
+
+	rt := rating.Rating{
+		ID:         uuid.New(),
+		RideID:     ride.ID,
+		CustomerID: cust.ID,
+		BikeID:     ride.BikeID,
+		Score:      req.Score,
+		Tags:       rating.TagList(req.Tags),
+		Comment:    req.Comment,
+	}
+	if err := a.ratr.Create(c, &rt); err != nil {
+		if errors.Is(err, rating.ErrAlreadyRated) {
+			c.JSON(http.StatusConflict, gin.H{"code": "ALREADY_RATED", "message": "This ride has already been rated"})
+			return
+		}
+		logger.ErrorContext(c, "failed to save rating", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if rt.Score <= rating.LowScore {
+		if err := a.checkLowRatings(c, ride); err != nil {
+			logger.ErrorContext(c, "failed to check low ratings", "error", err, "bikeId", ride.BikeID)
+		}
+	}
+
+	tags := []rating.Tag(rt.Tags)
+	if tags == nil {
+		tags = []rating.Tag{}
+	}
+	c.JSON(http.StatusCreated, ratingResponse{
+		ID:        rt.ID,
+		RideID:    rt.RideID,
+		Score:     rt.Score,
+		Tags:      tags,
+		Comment:   rt.Comment,
+		CreatedAt: rt.CreatedAt,
+	})
+}
+
+// checkLowRatings puts the ride's bike in the maintenance queue if it has been rated badly too often.
+func (a *API) checkLowRatings(c *gin.Context, ride riderepo.HistoryEntry) error {
+	n, err := a.ratr.CountLowSince(c, ride.BikeID, time.Now().Add(-rating.LowRatingPeriod))
+	if err != nil {
+		return err
+	}
+	if n < rating.LowRatingThreshold {
+		return nil
+	}
+
+	open, err := a.ir.HasOpen(c, ride.BikeID, issue.CategoryLowRatings)
+	if err != nil || open {
+		return err
+	}
+
+	days := int(rating.LowRatingPeriod.Hours() / 24)
+	i := issue.Issue{
+		ID:          uuid.New(),
+		BikeID:      ride.BikeID,
+		Category:    issue.CategoryLowRatings,
+		Description: fmt.Sprintf("%d low ratings in the last %d days", n, days),
+	}
+	if err := a.ir.Create(c, &i); err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("Bike %s needs checking", ride.BikeLabel)
+	body := fmt.Sprintf("Issue %s: bike %s has had %d low ratings in the last %d days.", i.ID, ride.BikeLabel, n, days)
+	if err := a.notifier.NotifyOps(c, subject, body); err != nil {
+		middleware.GetLogger(c).WarnContext(c, "failed to notify ops of low ratings", "error", err)
+	}
+	return nil
+}
+
+// bikeRatingsHandler aggregates ratings per bike for admins.
+func (a *API) bikeRatingsHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	from, to, err := parseDate(c.Query("startDate"), c.Query("endDate"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_DATE", "message": err.Error()})
+		return
+	}
+
+	aggs, err := a.ratr.GetBikeAggregates(c, from, to)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get bike ratings", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]bikeRatingsResponse, 0, len(aggs))
+	for _, agg := range aggs {
+		resp = append(resp, bikeRatingsResponse{
+			BikeID:                  agg.BikeID,
+			BikeLabel:               agg.BikeLabel,
+			ratingAggregateResponse: toRatingAggregateResponse(agg.Aggregate),
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// stationRatingsHandler aggregates ratings per station for admins.
+func (a *API) stationRatingsHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	from, to, err := parseDate(c.Query("startDate"), c.Query("endDate"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_DATE", "message": err.Error()})
+		return
+	}
+
+	aggs, err := a.ratr.GetStationAggregates(c, from, to)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get station ratings", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]stationRatingsResponse, 0, len(aggs))
+	for _, agg := range aggs {
+		resp = append(resp, stationRatingsResponse{
+			StationID:               agg.StationID,
+			StationName:             agg.StationName,
+			ratingAggregateResponse: toRatingAggregateResponse(agg.Aggregate),
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/internal/rebalance"
+)
+
+type moveStationResponse struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type moveResponse struct {
+	BikeID    uuid.UUID            `json:"bikeId"`
+	BikeLabel string               `json:"bikeLabel"`
+	From      *moveStationResponse `json:"from"`
+	Lat       float64              `json:"latitude"`
+	Lng       float64              `json:"longitude"`
+	To        moveStationResponse  `json:"to"`
+	By        time.Time            `json:"by"`
+	Reason    rebalance.Reason     `json:"reason"`
+	BookingID *uuid.UUID           `json:"bookingId,omitempty"`
+	// Distance is in metres
+	Distance int `json:"distance"`
+}
+
+func toMoveResponse(m rebalance.Move) moveResponse {
+	resp := moveResponse{
+		BikeID:    m.BikeID,
+		BikeLabel: m.BikeLabel,
+		Lat:       m.Location.Lat,
+		Lng:       m.Location.Lng,
+		To:        moveStationResponse{ID: m.To.ID, Name: m.To.Name},
+		By:        m.By,
+		Reason:    m.Reason,
+		Distance:  int(m.Distance),
+	}
+	if m.From != nil {
+		resp.From = &moveStationResponse{ID: m.From.ID, Name: m.From.Name}
+	}
+	if m.Booking.Valid {
+		resp.BookingID = &m.Booking.UUID
+	}
+	return resp
+}
+
+// rebalancingHandler recommends bike moves for ops. The optional horizon, e.g. "6h", is how far
+// ahead bookings are planned for.
+func (a *API) rebalancingHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	horizon := rebalance.DefaultHorizon
+	if h := c.Query("horizon"); h != "" {
+		d, err := time.ParseDuration(h)
+		if err != nil || d <= 0 || d > rebalance.MaxHorizon {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "INVALID_HORIZON",
+				"message": "horizon must be a duration such as 12h, of at most a week",
+			})
+			return
+		}
+		horizon = d
+	}
+
+	moves, err := a.rebalancer.Plan(c, time.Now(), horizon)
+	if err != nil {
+		logger.ErrorContext(c, "failed to plan rebalancing", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]moveResponse, 0, len(moves))
+	for _, m := range moves {
+		resp = append(resp, toMoveResponse(m))
+	}
+	c.JSON(http.StatusOK, resp)
+}
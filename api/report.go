@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/report"
+)
+
+type reportMetricsResponse struct {
+	Rides          int     `json:"rides"`
+	RiddenHours    float64 `json:"riddenHours"`
+	Bookings       int     `json:"bookings"`
+	BookedHours    float64 `json:"bookedHours"`
+	Cancellations  int     `json:"cancellations"`
+	NoShows        int     `json:"noShows"`
+	Revenue        int     `json:"revenue"`
+	AvailableHours float64 `json:"availableHours"`
+	IdleHours      float64 `json:"idleHours"`
+	Utilization    float64 `json:"utilization"`
+}
+
+func toReportMetricsResponse(m report.Metrics) reportMetricsResponse {
+	return reportMetricsResponse{
+		Rides:          m.Rides,
+		RiddenHours:    m.RiddenHours,
+		Bookings:       m.Bookings,
+		BookedHours:    m.BookedHours,
+		Cancellations:  m.Cancellations,
+		NoShows:        m.NoShows,
+		Revenue:        m.Revenue,
+		AvailableHours: m.AvailableHours,
+		IdleHours:      m.IdleHours,
+		Utilization:    m.Utilization(),
+	}
+}
+
+type reportLineResponse struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Bucket time.Time `json:"bucket"`
+	reportMetricsResponse
+}
+
+type reportResponse struct {
+	From   time.Time             `json:"from"`
+	To     time.Time             `json:"to"`
+	Bucket report.Bucket         `json:"bucket"`
+	Lines  []reportLineResponse  `json:"lines"`
+	Total  reportMetricsResponse `json:"total"`
+}
+
+// bikeUtilizationHandler reports the utilization and revenue of each bike for admins.
+func (a *API) bikeUtilizationHandler(c *gin.Context) {
+	a.utilizationReport(c, "bike-utilization", a.rptr.GetBikeUtilization)
+}
+
+// stationUtilizationHandler reports the utilization and revenue of the bikes at each station for
+// admins.
+func (a *API) stationUtilizationHandler(c *gin.Context) {
+	a.utilizationReport(c, "station-utilization", a.rptr.GetStationUtilization)
+}
+
+// utilizationReport runs a report over the startDate to endDate range, grouped by the bucket query
+// parameter, and writes it as JSON or, if format is csv, as a CSV download.
+func (a *API) utilizationReport(c *gin.Context, name string,
+	get func(ctx context.Context, from, to time.Time, bucket report.Bucket) ([]report.Line, error)) {
+	logger := middleware.GetLogger(c)
+
+	from, to, err := parseDate(c.Query("startDate"), c.Query("endDate"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_DATE", "message": err.Error()})
+		return
+	}
+	if from == nil || to == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_DATE", "message": "startDate and endDate are required"})
+		return
+	}
+	if err := report.ValidateRange(*from, *to); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "INVALID_DATE",
+			"message": "endDate must be after startDate and reports can cover at most a year",
+		})
+		return
+	}
+
+	bucket := report.Bucket(c.DefaultQuery("bucket", string(report.BucketDay)))
+	if !bucket.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_BUCKET", "message": "bucket must be day, week or month"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_FORMAT", "message": "format must be json or csv"})
+		return
+	}
+
+	lines, err := get(c, *from, *to, bucket)
+	if err != nil {
+		logger.ErrorContext(c, "failed to run report", "report", name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if format == "csv" {
+		c.Header("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		if err := writeReportCSV(c.Writer, lines); err != nil {
+			logger.ErrorContext(c, "failed to write report", "report", name, "error", err)
+		}
+		return
+	}
+
+	resp := reportResponse{
+		From:   *from,
+		To:     *to,
+		Bucket: bucket,
+		Lines:  make([]reportLineResponse, 0, len(lines)),
+	}
+	var total report.Metrics
+	for _, l := range lines {
+		resp.Lines = append(resp.Lines, reportLineResponse{
+			ID:                    l.ID,
+			Name:                  l.Name,
+			Bucket:                l.Bucket,
+			reportMetricsResponse: toReportMetricsResponse(l.Metrics),
+		})
+		total = total.Add(l.Metrics)
+	}
+	resp.Total = toReportMetricsResponse(total)
+	c.JSON(http.StatusOK, resp)
+}
+
+var reportCSVHeader = []string{
+	"id", "name", "bucket", "rides", "ridden_hours", "bookings", "booked_hours", "cancellations", "no_shows",
+	"revenue", "available_hours", "idle_hours", "utilization",
+}
+
+func writeReportCSV(w http.ResponseWriter, lines []report.Line) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(reportCSVHeader); err != nil {
+		return err
+	}
+	hours := func(h float64) string { return strconv.FormatFloat(h, 'f', 2, 64) }
+	for _, l := range lines {
+		err := cw.Write([]string{
+			l.ID.String(),
+			l.Name,
+			l.Bucket.UTC().Format(time.DateOnly),
+			strconv.Itoa(l.Rides),
+			hours(l.RiddenHours),
+			strconv.Itoa(l.Bookings),
+			hours(l.BookedHours),
+			strconv.Itoa(l.Cancellations),
+			strconv.Itoa(l.NoShows),
+			strconv.Itoa(l.Revenue),
+			hours(l.AvailableHours),
+			hours(l.IdleHours),
+			strconv.FormatFloat(l.Utilization(), 'f', 4, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
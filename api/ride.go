@@ -1,19 +1,25 @@
 package api
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stripe/stripe-go/v84"
-	"github.com/stripe/stripe-go/v84/invoice"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 
+	bikerepo "github.com/semanticallynull/bookingengine-backend/bike"
 	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+	"github.com/semanticallynull/bookingengine-backend/internal/lockgw"
 	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/issue"
 	riderepo "github.com/semanticallynull/bookingengine-backend/ride"
+	"github.com/semanticallynull/bookingengine-backend/track"
 )
 
 type rideRequest struct {
@@ -44,24 +50,31 @@ func (a *API) startRideHandler(c *gin.Context) {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
+	if !bikeInService(c, bike) {
+		return
+	}
 
-	// Check for upcoming booking conflict: another user has a booking starting within 1 hour
-	now := time.Now()
-	nextBooking, err := a.bkr.GetNextBookingByOtherUser(c, req.BikeID, userID, now)
+	// Only members can ride the bikes at a private station
+	hidden, err := a.hiddenBike(c, bike, customer.ID)
 	if err != nil {
-		logger.Error("Failed to check upcoming bookings", "error", err)
-		c.JSON(500, gin.H{"error": err.Error()})
+		logger.Error("Failed to get hidden stations", "error", err)
+		c.JSON(500, gin.H{"error": "internal error"})
 		return
 	}
-	if nextBooking != nil && nextBooking.StartTime.Before(now.Add(time.Hour)) {
-		c.JSON(409, gin.H{
-			"code":    "UPCOMING_BOOKING_CONFLICT",
-			"message": "Cannot start ride: another user has a booking starting soon",
+	if hidden {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    "PRIVATE_STATION",
+			"message": "This bike belongs to a private station you aren't a member of",
 		})
 		return
 	}
 
-	ride, err := a.rr.StartRide(c, bike.ID, customer.ID)
+	bookingID, ok := a.checkRideAvailability(c, bike, customer.ID)
+	if !ok {
+		return
+	}
+
+	ride, err := a.rr.StartRide(c, bike.ID, customer.ID, bookingID)
 	if err != nil {
 		custID, ok := riderepo.CustomerFromRideInProgressError(err)
 		if ok && custID == customer.ID {
@@ -69,107 +82,229 @@ func (a *API) startRideHandler(c *gin.Context) {
 			c.JSON(200, gin.H{"ok": "Customer already has an active ride"})
 			return
 		}
+		if errors.Is(err, riderepo.ErrBikeInUse) {
+			c.JSON(http.StatusConflict, gin.H{"code": "BIKE_IN_USE", "message": "Someone else is already riding this bike"})
+			return
+		}
+		if errors.Is(err, riderepo.ErrCustomerHasActiveRide) {
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    "RIDE_IN_PROGRESS",
+				"message": "You already have a ride in progress on another bike",
+			})
+			return
+		}
 
 		logger.Error("Failed to start ride", "error", err)
 		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The ride only starts once the lock confirms it has opened
+	_, err = a.locks.Send(c, bike.IMEI, lockgw.Unlock, strconv.FormatInt(ride.LockUserID.Int64, 10))
+	if err != nil {
+		logger.Error("Failed to unlock bike", "error", err, "imei", bike.IMEI)
+		if cerr := a.rr.CancelRide(context.WithoutCancel(c), ride.ID); cerr != nil {
+			logger.Error("Failed to cancel ride after unlock failure", "error", cerr, "rideId", ride.ID)
+		}
+		switch {
+		case errors.Is(err, lockgw.ErrNotConnected):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"code": "LOCK_NOT_CONNECTED", "message": "The bike's lock is offline"})
+		case errors.Is(err, lockgw.ErrRejected):
+			c.JSON(http.StatusBadGateway, gin.H{"code": "UNLOCK_REJECTED", "message": "The bike's lock refused to unlock"})
+		default:
+			c.JSON(http.StatusGatewayTimeout, gin.H{"code": "UNLOCK_TIMEOUT", "message": "The bike's lock did not respond"})
+		}
+		return
 	}
 
 	c.JSON(200, ride)
 }
 
+// checkRideAvailability checks that the customer can take the bike now. If they are riding it under
+// their own booking the booking's ID is returned. If they can't take it a response has been written and
+// ok is false.
+func (a *API) checkRideAvailability(c *gin.Context, b bikerepo.Bike, customerID uuid.UUID) (bookingID *uuid.UUID,
+	ok bool) {
+	logger := middleware.GetLogger(c)
+
+	avail, err := a.avail.Check(c, b, customerID, time.Now())
+	if err != nil {
+		logger.Error("Failed to check availability", "error", err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return nil, false
+	}
+
+	switch avail.Reason {
+	case availability.ReasonBooked:
+		c.JSON(409, gin.H{
+			"code":    "BOOKED_BY_OTHER_USER",
+			"message": "Cannot start ride: this bike is currently booked by another user",
+		})
+		return nil, false
+	case availability.ReasonBookingSoon:
+		c.JSON(409, gin.H{
+			"code":    "UPCOMING_BOOKING_CONFLICT",
+			"message": "Cannot start ride: another user has a booking starting soon",
+		})
+		return nil, false
+	}
+
+	// Rides already in progress are reported by StartRide, which knows who is riding the bike
+	if avail.Booking != nil {
+		return &avail.Booking.ID, true
+	}
+	return nil, true
+}
+
+// alarmHandler sounds the alarm on the bike the customer is currently riding, to help them find it.
+func (a *API) alarmHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	userID, _ := middleware.GetAuth0ID(c)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	current, err := a.cr.CurrentRide(cust.ID)
+	if err != nil {
+		if errors.Is(err, customer.ErrNoRideInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"code": "NO_ACTIVE_RIDE", "message": "No ride in progress"})
+			return
+		}
+		logger.ErrorContext(c, "failed to get current ride", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	b, err := a.br.GetBike(c, current.BikeID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get bike", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if _, err := a.locks.Send(c, b.IMEI, lockgw.Alarm); err != nil {
+		logger.WarnContext(c, "failed to sound alarm", "error", err, "imei", b.IMEI)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"code": "ALARM_FAILED", "message": "Could not reach the bike's lock"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type endRideRequest struct {
+	// RideID identifies the ride being ended, so that retried requests end the right ride
+	RideID *uuid.UUID `json:"rideId"`
+	// Issue optionally reports a problem with the bike; photos can be added to it afterwards
+	Issue *issueRequest `json:"issue"`
+}
+
+type endRideResponse struct {
+	RideID        uuid.UUID  `json:"rideId"`
+	BikeID        uuid.UUID  `json:"bikeId"`
+	BikeLabel     string     `json:"bikeLabel"`
+	StartedAt     time.Time  `json:"startedAt"`
+	EndedAt       time.Time  `json:"endedAt"`
+	BilledMinutes int        `json:"billedMinutes"`
+	UnlockFee     int        `json:"unlockFee"`
+	TimeCharge    int        `json:"timeCharge"`
+	Price         int        `json:"price"`
+	Currency      string     `json:"currency"`
+	AlreadyEnded  bool       `json:"alreadyEnded"`
+	IssueID       *uuid.UUID `json:"issueId,omitempty"`
+}
+
 func (a *API) endRideHandler(c *gin.Context) {
 	logger := middleware.GetLogger(c)
 
-	var req rideRequest
-	if err := c.Bind(&req); err != nil {
+	var req endRideRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
 		logger.Error("Failed to bind request", "error", err)
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+	if req.Issue != nil && !req.Issue.Category.Valid() {
+		c.JSON(400, gin.H{"code": "INVALID_CATEGORY", "message": "Unknown issue category"})
 		return
 	}
 
 	userID, _ := middleware.GetAuth0ID(c)
-	customer, err := a.cr.GetCustomerByAuth0ID(userID)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
 	if err != nil {
+		if errors.Is(err, customer.ErrNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"code": "NO_ACTIVE_RIDE", "message": "No ride in progress"})
+			return
+		}
 		logger.Error("Failed to get customer", "error", err)
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	mins, err := a.rr.EndRide(c, customer.ID)
-	if err != nil {
-		logger.Error("Failed to start ride", "error", err)
-		c.JSON(500, gin.H{"error": err.Error()})
+	summary, err := a.rr.EndRide(c, cust.ID, req.RideID)
+	alreadyEnded := errors.Is(err, riderepo.ErrAlreadyEnded)
+	if err != nil && !alreadyEnded {
+		switch {
+		case errors.Is(err, riderepo.ErrNoActiveRide):
+			c.JSON(http.StatusConflict, gin.H{"code": "NO_ACTIVE_RIDE", "message": "No ride in progress"})
+		case errors.Is(err, riderepo.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"code": "RIDE_NOT_FOUND", "message": "Ride not found"})
+		default:
+			logger.Error("Failed to end ride", "error", err)
+			c.JSON(500, gin.H{"error": "internal error"})
+		}
 		return
 	}
 
-	go func() {
-		inParams := &stripe.InvoiceParams{
-			Customer: stripe.String(customer.StripeID.String),
-		}
-		in, err := invoice.New(inParams)
-		if err != nil {
-			logger.Error("Failed to create invoice", "error", err)
-			return
+	// Charging is idempotent. A charge which fails is picked up by a retried end request, or by the
+	// uncharged rides job
+	go func(ctx context.Context) {
+		if err := a.biller.ChargeRide(ctx, cust.StripeID.String, summary); err != nil {
+			logger.Error("Failed to charge ride", "error", err, "rideId", summary.RideID)
 		}
+	}(context.WithoutCancel(c))
 
-		ilParams := &stripe.InvoiceAddLinesParams{
-			Params:          stripe.Params{},
-			Expand:          nil,
-			InvoiceMetadata: nil,
-			Lines: []*stripe.InvoiceAddLinesLineParams{
-				{
-					Amount:      stripe.Int64(100),
-					Description: stripe.String("Ride Unlock"),
-					TaxAmounts: []*stripe.InvoiceAddLinesLineTaxAmountParams{
-						{
-							Amount:        stripe.Int64(12),
-							TaxableAmount: stripe.Int64(88),
-							TaxRateData: &stripe.InvoiceAddLinesLineTaxAmountTaxRateDataParams{
-								Percentage:  stripe.Float64(13.5),
-								Description: stripe.String("VAT - Reduced Rate"),
-								DisplayName: stripe.String("VAT - Reduced Rate (13.5%)"),
-								Inclusive:   stripe.Bool(true),
-							},
-						},
-					},
-				},
-				{
-					Amount:      stripe.Int64(int64(15 * mins)),
-					Description: stripe.String(fmt.Sprintf("Ride - %d minutes", mins)),
-					TaxAmounts: []*stripe.InvoiceAddLinesLineTaxAmountParams{
-						{
-							Amount:        stripe.Int64(int64(2 * mins)),
-							TaxableAmount: stripe.Int64(int64(13 * mins)),
-							TaxRateData: &stripe.InvoiceAddLinesLineTaxAmountTaxRateDataParams{
-								Percentage:  stripe.Float64(13.5),
-								Description: stripe.String("VAT - Reduced Rate"),
-								DisplayName: stripe.String("VAT - Reduced Rate (13.5%)"),
-								Inclusive:   stripe.Bool(true),
-							},
-						},
-					},
-				},
-			},
-		}
-		_, err = invoice.AddLines(in.ID, ilParams)
-		if err != nil {
-			logger.Error("Failed to add lines to invoice", "error", err)
-			return
-		}
+	resp := toEndRideResponse(summary, alreadyEnded)
 
-		params := &stripe.InvoiceFinalizeInvoiceParams{}
-		_, err = invoice.FinalizeInvoice(in.ID, params)
-		if err != nil {
-			logger.Error("Failed to finalize invoice", "error", err)
-			return
-		}
-		_, err = invoice.Pay(in.ID, nil)
-		if err != nil {
-			logger.Error("Failed to pay invoice", "error", err)
+	// The ride has ended either way, so a failure to record the issue doesn't fail the request.
+	// Retries don't report the issue again.
+	if req.Issue != nil && !alreadyEnded {
+		if i, err := a.reportRideIssue(c, summary, cust.ID, *req.Issue); err != nil {
+			logger.Error("Failed to report issue", "error", err, "rideId", summary.RideID)
+		} else {
+			resp.IssueID = &i.ID
 		}
-	}()
+	}
 
-	c.JSON(200, "OK")
+	c.JSON(200, resp)
+}
+
+// reportRideIssue reports an issue with the bike used for a ride.
+func (a *API) reportRideIssue(c *gin.Context, s riderepo.Summary, customerID uuid.UUID,
+	req issueRequest) (issue.Issue, error) {
+	b, err := a.br.GetBike(c, s.BikeLabel)
+	if err != nil {
+		return issue.Issue{}, err
+	}
+	return a.reportIssue(c, b, customerID, uuid.NullUUID{UUID: s.RideID, Valid: true}, req)
+}
+
+func toEndRideResponse(s riderepo.Summary, alreadyEnded bool) endRideResponse {
+	return endRideResponse{
+		RideID:        s.RideID,
+		BikeID:        s.BikeID,
+		BikeLabel:     s.BikeLabel,
+		StartedAt:     s.StartedAt,
+		EndedAt:       s.EndedAt,
+		BilledMinutes: s.BilledMinutes,
+		UnlockFee:     s.UnlockFee,
+		TimeCharge:    s.TimeCharge,
+		Price:         s.Price(),
+		Currency:      "EUR",
+		AlreadyEnded:  alreadyEnded,
+	}
 }
 
 type RideState struct {
@@ -220,3 +355,64 @@ func (a *API) currentRideHandler(c *gin.Context) {
 		StartedAt:  ride.StartedAt,
 	})
 }
+
+type rideHistoryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	BikeLabel      string     `json:"bikeLabel"`
+	StartedAt      time.Time  `json:"startedAt"`
+	EndedAt        *time.Time `json:"endedAt,omitempty"`
+	DistanceMeters int        `json:"distanceMeters"`
+	Polyline       string     `json:"polyline"`
+}
+
+func (a *API) rideHistoryHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	userID, _ := middleware.GetAuth0ID(c)
+	cust, err := a.cr.GetCustomerByAuth0ID(userID)
+	if err != nil {
+		if errors.Is(err, customer.ErrNotFound) {
+			c.JSON(http.StatusOK, []rideHistoryResponse{})
+			return
+		}
+		logger.ErrorContext(c, "failed to get customer", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	rides, err := a.rr.GetHistory(c, cust.ID)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get ride history", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	rideIDs := make([]uuid.UUID, 0, len(rides))
+	for _, r := range rides {
+		rideIDs = append(rideIDs, r.ID)
+	}
+	fixes, err := a.tr.GetFixesForRides(c, rideIDs)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get ride tracks", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	history := make([]rideHistoryResponse, 0, len(rides))
+	for _, r := range rides {
+		stats := track.Summarise(fixes[r.ID])
+		resp := rideHistoryResponse{
+			ID:             r.ID,
+			BikeLabel:      r.BikeLabel,
+			StartedAt:      r.StartedAt,
+			DistanceMeters: stats.DistanceMeters,
+			Polyline:       stats.Polyline,
+		}
+		if r.EndedAt.Valid {
+			resp.EndedAt = &r.EndedAt.Time
+		}
+		history = append(history, resp)
+	}
+
+	c.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
+	"github.com/auth0/go-jwt-middleware/v2/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/booking"
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+	"github.com/semanticallynull/bookingengine-backend/internal/lockgw"
+	"github.com/semanticallynull/bookingengine-backend/internal/testdb"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+// failingLocks fails every command with err.
+type failingLocks struct{ err error }
+
+func (l failingLocks) Send(context.Context, string, lockgw.Command, ...string) ([]string, error) {
+	return nil, l.err
+}
+
+func TestStartRideHandlerUnlockFails(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not connected", lockgw.ErrNotConnected, http.StatusServiceUnavailable, "LOCK_NOT_CONNECTED"},
+		{"rejected", lockgw.ErrRejected, http.StatusBadGateway, "UNLOCK_REJECTED"},
+		{"timeout", context.DeadlineExceeded, http.StatusGatewayTimeout, "UNLOCK_TIMEOUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.Open(t)
+			rr := ride.NewRepository(db)
+			bkr := booking.NewRepository(db)
+			a := &API{
+				br:    bike.NewRepository(db),
+				sr:    station.NewRepository(db),
+				cr:    customer.NewRepository(db),
+				rr:    rr,
+				bkr:   bkr,
+				avail: availability.New(rr, bkr),
+				locks: failingLocks{err: tt.err},
+			}
+			b := testdb.CreateBike(t, db, "CARGO-1")
+			testdb.CreateCustomer(t, db, "auth0|rider")
+
+			status, code := startRide(t, a, "auth0|rider", b.Label)
+			if status != tt.status || code != tt.code {
+				t.Errorf("got %d %s, want %d %s", status, code, tt.status, tt.code)
+			}
+
+			// The ride is cancelled, so the bike is free to be ridden
+			rides, err := rr.GetActiveRidesForBikes(context.Background(), []uuid.UUID{b.ID})
+			if err != nil {
+				t.Fatalf("GetActiveRidesForBikes() error = %v", err)
+			}
+			if len(rides) != 0 {
+				t.Errorf("active rides = %v, want none", rides)
+			}
+		})
+	}
+}
+
+// startRide asks the handler to start a ride on the bike with the label for the customer with the
+// Auth0 ID, and returns the response's status and, for errors, its code.
+func startRide(t *testing.T, a *API, auth0ID, label string) (int, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	claims := &validator.ValidatedClaims{RegisteredClaims: validator.RegisteredClaims{Subject: auth0ID}}
+	ctx := context.WithValue(context.Background(), jwtmiddleware.ContextKey{}, claims)
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/rides/start",
+		strings.NewReader(`{"bikeId":"`+label+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	a.startRideHandler(c)
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Code
+}
@@ -1,22 +1,56 @@
 package api
 
 import (
+	"errors"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
 	"github.com/semanticallynull/bookingengine-backend/station"
 )
 
 func (a *API) stationsHandler(c *gin.Context) {
+	if c.Query("near") != "" {
+		a.nearbyStationsHandler(c)
+		return
+	}
+
 	stations, err := a.sr.GetStations()
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
+	hidden, err := a.hiddenStations(c)
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to get hidden stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	visible := stations[:0]
+	for _, s := range stations {
+		if !hidden[s.ID] {
+			visible = append(visible, s)
+		}
+	}
+	stations = visible
+
+	occupancy, err := a.stationOccupancy(c, stations)
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to get station occupancy", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
 	var stationResponses []stationResponse
 	for _, s := range stations {
-		stationResponses = append(stationResponses, toStationResponse(s))
+		resp := toStationResponse(s)
+		resp.Occupancy = toStationOccupancyResponse(occupancy[s.ID])
+		stationResponses = append(stationResponses, resp)
 	}
 	c.JSON(200, stationResponses)
 }
@@ -25,12 +59,51 @@ func (a *API) stationHandler(c *gin.Context) {
 	id := c.Param("id")
 
 	stations, err := a.sr.GetStation(id)
+	if err == nil && !stations.Active() {
+		err = station.ErrNotFound
+	}
+	if err == nil && stations.Type == station.Private {
+		var hidden map[uuid.UUID]bool
+		if hidden, err = a.hiddenStations(c); err == nil && hidden[stations.ID] {
+			err = station.ErrNotFound
+		}
+	}
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		if errors.Is(err, station.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "STATION_NOT_FOUND", "message": "Station not found"})
+			return
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to get station", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
-	c.JSON(200, toStationResponse(stations))
+	occupancy, err := a.stationOccupancy(c, []station.Station{stations})
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to get station occupancy", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := toStationResponse(stations)
+	resp.Occupancy = toStationOccupancyResponse(occupancy[stations.ID])
+	c.JSON(200, resp)
+}
+
+// stationOccupancy counts the bikes at each of the stations, as seen by the customer making the
+// request.
+func (a *API) stationOccupancy(c *gin.Context, stations []station.Station) (map[uuid.UUID]station.Occupancy, error) {
+	customerID, err := a.currentCustomerID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(stations))
+	for _, s := range stations {
+		ids = append(ids, s.ID)
+	}
+	now := time.Now()
+	return a.sr.GetOccupancy(c, ids, customerID, now, now.Add(availability.BookingBuffer))
 }
 
 type stationResponse struct {
@@ -41,16 +114,48 @@ type stationResponse struct {
 	Lat          float64      `json:"latitude"`
 	Lng          float64      `json:"longitude"`
 	Type         station.Type `json:"type"`
+	// OpeningSchedule is empty for stations which are always open
+	OpeningSchedule station.OpeningSchedule `json:"openingSchedule"`
+	Capacity        *int32                  `json:"capacity,omitempty"`
+	// ReturnRadius is how close, in metres, a bike has to be to the station to be returned there
+	ReturnRadius int                       `json:"returnRadius"`
+	Occupancy    *stationOccupancyResponse `json:"occupancy,omitempty"`
+}
+
+type stationOccupancyResponse struct {
+	AvailableBikes int `json:"availableBikes"`
+	// RidingBikes are the station's bikes which are out on a ride
+	RidingBikes int `json:"ridingBikes"`
+	// BookingsSoon are the bookings of the station's bikes which start within the booking buffer
+	BookingsSoon int `json:"bookingsSoon"`
+	// FreeSpaces is omitted for stations without a capacity
+	FreeSpaces *int32 `json:"freeSpaces,omitempty"`
 }
 
-func toStationResponse(station station.Station) stationResponse {
-	return stationResponse{
-		ID:           station.ID,
-		Name:         station.Name,
-		Address:      station.Address,
-		OpeningHours: station.OpeningHours,
-		Type:         station.Type,
-		Lat:          station.Location.P.X,
-		Lng:          station.Location.P.Y,
+func toStationOccupancyResponse(o station.Occupancy) *stationOccupancyResponse {
+	return &stationOccupancyResponse{
+		AvailableBikes: o.AvailableBikes,
+		RidingBikes:    o.RidingBikes,
+		BookingsSoon:   o.BookingsSoon,
+		FreeSpaces:     nullInt32Ptr(o.FreeSpaces),
+	}
+}
+
+func toStationResponse(s station.Station) stationResponse {
+	resp := stationResponse{
+		ID:              s.ID,
+		Name:            s.Name,
+		Address:         s.Address,
+		OpeningHours:    s.OpeningHours,
+		Type:            s.Type,
+		Lat:             s.Location.Lat,
+		Lng:             s.Location.Lng,
+		OpeningSchedule: s.OpeningSchedule,
+		Capacity:        nullInt32Ptr(s.Capacity),
+		ReturnRadius:    s.ReturnRadius,
+	}
+	if resp.OpeningSchedule == nil {
+		resp.OpeningSchedule = []station.OpeningPeriod{}
 	}
+	return resp
 }
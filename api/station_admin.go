@@ -0,0 +1,257 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+type createStationRequest struct {
+	Name            string                  `json:"name" binding:"required,max=200"`
+	Address         string                  `json:"address" binding:"required,max=500"`
+	OpeningHours    string                  `json:"openingHours" binding:"max=200"`
+	OpeningSchedule station.OpeningSchedule `json:"openingSchedule"`
+	Latitude        *float64                `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude       *float64                `json:"longitude" binding:"required,min=-180,max=180"`
+	Type            string                  `json:"type" binding:"required"`
+	Capacity        *int32                  `json:"capacity" binding:"omitempty,min=1"`
+	ReturnRadius    *int                    `json:"returnRadius" binding:"omitempty,min=1,max=1000"`
+	TargetBikes     int                     `json:"targetBikes" binding:"min=0"`
+}
+
+// updateStationRequest changes only the fields which are present. A capacity of 0 removes the
+// station's capacity limit.
+type updateStationRequest struct {
+	Name            *string                  `json:"name" binding:"omitempty,max=200"`
+	Address         *string                  `json:"address" binding:"omitempty,max=500"`
+	OpeningHours    *string                  `json:"openingHours" binding:"omitempty,max=200"`
+	OpeningSchedule *station.OpeningSchedule `json:"openingSchedule"`
+	Latitude        *float64                 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude       *float64                 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	Type            *string                  `json:"type"`
+	Capacity        *int32                   `json:"capacity" binding:"omitempty,min=0"`
+	ReturnRadius    *int                     `json:"returnRadius" binding:"omitempty,min=1,max=1000"`
+	TargetBikes     *int                     `json:"targetBikes" binding:"omitempty,min=0"`
+}
+
+type adminStationResponse struct {
+	stationResponse
+	TargetBikes   int        `json:"targetBikes"`
+	Active        bool       `json:"active"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+func toAdminStationResponse(s station.Station) adminStationResponse {
+	resp := adminStationResponse{
+		stationResponse: toStationResponse(s),
+		TargetBikes:     s.TargetBikes,
+		Active:          s.Active(),
+		CreatedAt:       s.CreatedAt,
+	}
+	if s.DeactivatedAt.Valid {
+		resp.DeactivatedAt = &s.DeactivatedAt.Time
+	}
+	return resp
+}
+
+func (a *API) listAdminStationsHandler(c *gin.Context) {
+	stations, err := a.sr.ListStations(c, c.Query("includeDeactivated") == "true")
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to list stations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := make([]adminStationResponse, 0, len(stations))
+	for _, s := range stations {
+		resp = append(resp, toAdminStationResponse(s))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a *API) getAdminStationHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toAdminStationResponse(s))
+}
+
+func (a *API) createStationHandler(c *gin.Context) {
+	var req createStationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+
+	typ, err := station.ParseType(req.Type)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_TYPE", "message": "type must be public or private"})
+		return
+	}
+	if !validOpeningSchedule(c, req.OpeningSchedule) {
+		return
+	}
+
+	s := station.Station{
+		ID:              uuid.New(),
+		Name:            req.Name,
+		Address:         req.Address,
+		OpeningHours:    req.OpeningHours,
+		OpeningSchedule: req.OpeningSchedule,
+		Location:        geo.Point{Lat: *req.Latitude, Lng: *req.Longitude},
+		Type:            typ,
+		Capacity:        int32PtrNull(req.Capacity),
+		ReturnRadius:    station.DefaultReturnRadius,
+		TargetBikes:     req.TargetBikes,
+	}
+	if req.ReturnRadius != nil {
+		s.ReturnRadius = *req.ReturnRadius
+	}
+
+	if err := a.sr.Create(c, &s); err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to create station", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(http.StatusCreated, toAdminStationResponse(s))
+}
+
+func (a *API) updateStationHandler(c *gin.Context) {
+	var req updateStationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		s.Name = *req.Name
+	}
+	if req.Address != nil {
+		s.Address = *req.Address
+	}
+	if req.OpeningHours != nil {
+		s.OpeningHours = *req.OpeningHours
+	}
+	if req.OpeningSchedule != nil {
+		if !validOpeningSchedule(c, *req.OpeningSchedule) {
+			return
+		}
+		s.OpeningSchedule = *req.OpeningSchedule
+	}
+	if req.Latitude != nil {
+		s.Location.Lat = *req.Latitude
+	}
+	if req.Longitude != nil {
+		s.Location.Lng = *req.Longitude
+	}
+	if req.Type != nil {
+		typ, err := station.ParseType(*req.Type)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_TYPE", "message": "type must be public or private"})
+			return
+		}
+		s.Type = typ
+	}
+	if req.Capacity != nil {
+		if *req.Capacity == 0 {
+			req.Capacity = nil
+		}
+		s.Capacity = int32PtrNull(req.Capacity)
+	}
+	if req.ReturnRadius != nil {
+		s.ReturnRadius = *req.ReturnRadius
+	}
+	if req.TargetBikes != nil {
+		s.TargetBikes = *req.TargetBikes
+	}
+
+	if err := a.sr.Update(c, &s); err != nil {
+		if errors.Is(err, station.ErrDeactivated) {
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    "STATION_DEACTIVATED",
+				"message": "Deactivated stations can't be changed until they are reactivated",
+			})
+			return
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to update station", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(http.StatusOK, toAdminStationResponse(s))
+}
+
+// deactivateStationHandler takes a station out of use rather than deleting it, so rides and ratings
+// which refer to it stay intact. Bikes have to be moved to another station first.
+func (a *API) deactivateStationHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	if _, err := a.sr.Deactivate(c, s.ID); err != nil {
+		if errors.Is(err, station.ErrHasBikes) {
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    "STATION_HAS_BIKES",
+				"message": "Bikes are still assigned to this station",
+			})
+			return
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to deactivate station", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (a *API) reactivateStationHandler(c *gin.Context) {
+	s, ok := a.adminStation(c)
+	if !ok {
+		return
+	}
+
+	s, err := a.sr.Reactivate(c, s.ID)
+	if err != nil {
+		middleware.GetLogger(c).ErrorContext(c, "failed to reactivate station", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(http.StatusOK, toAdminStationResponse(s))
+}
+
+// adminStation fetches the station named by the stationId path parameter, including deactivated
+// stations. If it can't be found a response has been written and ok is false.
+func (a *API) adminStation(c *gin.Context) (station.Station, bool) {
+	s, err := a.sr.GetStation(c.Param("stationId"))
+	if err != nil {
+		if errors.Is(err, station.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "STATION_NOT_FOUND", "message": "Station not found"})
+			return station.Station{}, false
+		}
+		middleware.GetLogger(c).ErrorContext(c, "failed to get station", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return station.Station{}, false
+	}
+	return s, true
+}
+
+func validOpeningSchedule(c *gin.Context, s station.OpeningSchedule) bool {
+	if err := s.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_OPENING_SCHEDULE", "message": err.Error()})
+		return false
+	}
+	return true
+}
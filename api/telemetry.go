@@ -0,0 +1,122 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/telemetry"
+)
+
+// maxReadingsPerRequest bounds the size of a single telemetry upload.
+const maxReadingsPerRequest = 1000
+
+type telemetryRequest struct {
+	Readings []telemetryReading `json:"readings" binding:"required,dive"`
+}
+
+type telemetryReading struct {
+	RecordedAt     time.Time `json:"recordedAt" binding:"required"`
+	BatteryVoltage *int32    `json:"batteryVoltage,omitempty"`
+	LockState      string    `json:"lockState" binding:"omitempty,oneof=locked unlocked unknown"`
+	SignalStrength *int32    `json:"signalStrength,omitempty"`
+}
+
+func (a *API) telemetryHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	var req telemetryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+	if len(req.Readings) > maxReadingsPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Too many readings in one request"})
+		return
+	}
+
+	readings := make([]telemetry.Reading, 0, len(req.Readings))
+	for _, r := range req.Readings {
+		reading := telemetry.Reading{
+			RecordedAt: r.RecordedAt,
+			LockState:  telemetry.LockState(r.LockState),
+		}
+		if reading.LockState == "" {
+			reading.LockState = telemetry.LockStateUnknown
+		}
+		if r.BatteryVoltage != nil {
+			reading.BatteryVoltage = sql.NullInt32{Int32: *r.BatteryVoltage, Valid: true}
+		}
+		if r.SignalStrength != nil {
+			reading.SignalStrength = sql.NullInt32{Int32: *r.SignalStrength, Valid: true}
+		}
+		readings = append(readings, reading)
+	}
+
+	err := a.telr.Record(c, c.Param("imei"), readings)
+	if err != nil {
+		if errors.Is(err, telemetry.ErrUnknownDevice) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "DEVICE_NOT_FOUND", "message": "Unknown device"})
+			return
+		}
+		logger.ErrorContext(c, "failed to record telemetry", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type batteryPointResponse struct {
+	RecordedAt time.Time `json:"recordedAt"`
+	Voltage    int       `json:"voltage"`
+	Percentage int       `json:"percentage"`
+}
+
+// batteryHistoryHandler lists a bike's battery readings, for charting how its battery decays.
+func (a *API) batteryHistoryHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	b, ok := a.fleetBike(c)
+	if !ok {
+		return
+	}
+
+	// Default to the last week of readings
+	to := time.Now()
+	from := to.Add(-7 * 24 * time.Hour)
+	start, end, err := parseDate(c.Query("startDate"), c.Query("endDate"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_DATE", "message": err.Error()})
+		return
+	}
+	if start != nil {
+		from = *start
+	}
+	if end != nil {
+		to = *end
+	}
+
+	points, err := a.telr.GetBatteryHistory(c, b.ID, from, to)
+	if err != nil {
+		logger.ErrorContext(c, "failed to get battery history", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	curve := a.batteryCurves.For(b.BatteryModel)
+	history := make([]batteryPointResponse, 0, len(points))
+	for _, p := range points {
+		history = append(history, batteryPointResponse{
+			RecordedAt: p.RecordedAt,
+			Voltage:    p.BatteryVoltage,
+			Percentage: curve.Percentage(p.BatteryVoltage),
+		})
+	}
+
+	c.JSON(http.StatusOK, history)
+}
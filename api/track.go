@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/internal/middleware"
+	"github.com/semanticallynull/bookingengine-backend/track"
+)
+
+// maxFixesPerRequest bounds the size of a single position upload.
+const maxFixesPerRequest = 1000
+
+type positionsRequest struct {
+	Fixes []positionFix `json:"fixes" binding:"required,dive"`
+}
+
+type positionFix struct {
+	RecordedAt time.Time `json:"recordedAt" binding:"required"`
+	Lat        float64   `json:"latitude" binding:"min=-90,max=90"`
+	Lng        float64   `json:"longitude" binding:"min=-180,max=180"`
+	Accuracy   *float64  `json:"accuracy,omitempty"`
+}
+
+func (a *API) positionsHandler(c *gin.Context) {
+	logger := middleware.GetLogger(c)
+
+	var req positionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": err.Error()})
+		return
+	}
+	if len(req.Fixes) > maxFixesPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"code": "INVALID_REQUEST", "message": "Too many fixes in one request"})
+		return
+	}
+
+	fixes := make([]track.Fix, 0, len(req.Fixes))
+	for _, f := range req.Fixes {
+		fix := track.Fix{
+			RecordedAt: f.RecordedAt,
+			Location:   geo.Point{Lat: f.Lat, Lng: f.Lng},
+		}
+		if f.Accuracy != nil {
+			fix.Accuracy = sql.NullFloat64{Float64: *f.Accuracy, Valid: true}
+		}
+		fixes = append(fixes, fix)
+	}
+
+	err := a.tr.Record(c, c.Param("imei"), fixes)
+	if err != nil {
+		if errors.Is(err, track.ErrUnknownDevice) {
+			c.JSON(http.StatusNotFound, gin.H{"code": "DEVICE_NOT_FOUND", "message": "Unknown device"})
+			return
+		}
+		logger.ErrorContext(c, "failed to record positions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,81 @@
+package bike
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// CurvePoint maps a pack voltage, in tenths of a volt, to a state of charge percentage.
+type CurvePoint struct {
+	Voltage    int `json:"voltage"`
+	Percentage int `json:"percentage"`
+}
+
+// VoltageCurve is a discharge curve for a battery model. Voltages between points are linearly interpolated.
+type VoltageCurve []CurvePoint
+
+// DefaultCurve is used for bikes without a battery model, or whose model has no configured curve.
+// It treats a 36V pack as empty at 34.0V and full at 41.2V.
+var DefaultCurve = VoltageCurve{
+	{Voltage: 340, Percentage: 0},
+	{Voltage: 412, Percentage: 100},
+}
+
+// Percentage converts a voltage reading into a state of charge between 0 and 100.
+func (vc VoltageCurve) Percentage(voltage int) int {
+	if len(vc) == 0 {
+		return DefaultCurve.Percentage(voltage)
+	}
+	if voltage <= vc[0].Voltage {
+		return vc[0].Percentage
+	}
+	for i := 1; i < len(vc); i++ {
+		lo, hi := vc[i-1], vc[i]
+		if voltage <= hi.Voltage {
+			return lo.Percentage + (voltage-lo.Voltage)*(hi.Percentage-lo.Percentage)/(hi.Voltage-lo.Voltage)
+		}
+	}
+	return vc[len(vc)-1].Percentage
+}
+
+// BatteryCurves holds the voltage curve for each battery model, keyed by model name.
+type BatteryCurves map[string]VoltageCurve
+
+// For returns the curve for a battery model, falling back to DefaultCurve.
+func (bc BatteryCurves) For(model *string) VoltageCurve {
+	if model != nil {
+		if curve, ok := bc[*model]; ok {
+			return curve
+		}
+	}
+	return DefaultCurve
+}
+
+// LoadBatteryCurves reads battery curves from a JSON file of the form
+// {"<model>": [{"voltage": 340, "percentage": 0}, ...]}. An empty path returns no curves.
+func LoadBatteryCurves(path string) (BatteryCurves, error) {
+	curves := BatteryCurves{}
+	if path == "" {
+		return curves, nil
+	}
+
+	f, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(f, &curves); err != nil {
+		return nil, err
+	}
+
+	for model, curve := range curves {
+		sort.Slice(curve, func(i, j int) bool { return curve[i].Voltage < curve[j].Voltage })
+		for i := 1; i < len(curve); i++ {
+			if curve[i].Voltage == curve[i-1].Voltage {
+				return nil, fmt.Errorf("battery curve %q has duplicate voltage %d", model, curve[i].Voltage)
+			}
+		}
+	}
+	return curves, nil
+}
@@ -2,8 +2,11 @@
 package bike
 
 import (
+	"database/sql"
+
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
 )
 
 // Bike represents a bike which can used as part of a booking.
@@ -16,17 +19,37 @@ type Bike struct {
 	// IMEI is the identifier of the SIM card used in the bike. This is what is transmitted by the lock
 	IMEI string
 
-	Location pgtype.Point
+	// Location is the bike's last known position
+	Location geo.Point
+	// LocationUpdatedAt is the time of the position fix which last moved Location
+	LocationUpdatedAt sql.NullTime `db:"location_updated_at"`
 
-	BatteryVoltage int
+	// BatteryVoltage is the latest pack voltage reported by the lock in tenths of a volt
+	BatteryVoltage sql.NullInt32 `db:"battery_voltage"`
+	// LockState is the latest lock state reported by the lock
+	LockState *string `db:"lock_state"`
+	// SignalStrength is the latest cellular signal strength reported by the lock in dBm
+	SignalStrength sql.NullInt32 `db:"signal_strength"`
+	// TelemetryUpdatedAt is the time of the reading which last updated the battery and lock state
+	TelemetryUpdatedAt sql.NullTime `db:"telemetry_updated_at"`
 
-	Available bool
+	// State is where the bike is in its lifecycle. Only active bikes are offered to customers
+	State State `db:"state"`
+	// StateReason explains the latest state change, e.g. the issue which took the bike out of service
+	StateReason    *string      `db:"state_reason"`
+	StateChangedAt sql.NullTime `db:"state_changed_at"`
 
 	StationID   *uuid.UUID `db:"station_id"`
 	StationName *string    `db:"station_name"`
 
-	// DisplayName is a user-friendly name for the bike type (e.g., "Bergamont Cargoville LJ")
-	DisplayName *string `db:"display_name"`
-	// ImageURL is a URL to an image of the bike
-	ImageURL *string `db:"image_url"`
+	// ModelID is the bike's model. The model's details are joined onto the bike below and are nil
+	// when the bike has no model.
+	ModelID          uuid.NullUUID `db:"model_id"`
+	ModelName        *string       `db:"model_name"`
+	ImageURL         *string       `db:"image_url"`
+	Capacity         sql.NullInt32 `db:"capacity"`
+	CargoType        *CargoType    `db:"cargo_type"`
+	ModelDescription *string       `db:"model_description"`
+	// BatteryModel identifies the battery fitted to the bike's model, and so which voltage curve applies
+	BatteryModel *string `db:"battery_model"`
 }
@@ -0,0 +1,56 @@
+package bike
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CargoType is the kind of cargo bike a model is.
+type CargoType string
+
+const (
+	// CargoBox bikes carry a box in front of the rider.
+	CargoBox CargoType = "box"
+	// CargoLongtail bikes carry their load on an extended rear rack.
+	CargoLongtail CargoType = "longtail"
+	// CargoTrike bikes have two wheels at the front and a box between them.
+	CargoTrike CargoType = "trike"
+)
+
+// Valid reports whether t is a known cargo type.
+func (t CargoType) Valid() bool {
+	switch t {
+	case CargoBox, CargoLongtail, CargoTrike:
+		return true
+	}
+	return false
+}
+
+// Model is a make and model of bike in the fleet. Bikes of the same model share its description,
+// battery and, optionally, its own tariff.
+type Model struct {
+	ID uuid.UUID `db:"id"`
+	// Name is a user-friendly name for the model (e.g., "Bergamont Cargoville LJ")
+	Name string `db:"name"`
+	// ImageURL is a URL to an image of the model
+	ImageURL *string `db:"image_url"`
+	// Capacity is the most the bike can carry in kg
+	Capacity    sql.NullInt32 `db:"capacity"`
+	CargoType   CargoType     `db:"cargo_type"`
+	Description string        `db:"description"`
+	// BatteryModel identifies the battery fitted to bikes of this model, and so which voltage curve applies
+	BatteryModel *string `db:"battery_model"`
+	// UnlockFee and PerMinute override the standard tariff for rides on bikes of this model.
+	// Amounts are in cents.
+	UnlockFee sql.NullInt32 `db:"unlock_fee"`
+	PerMinute sql.NullInt32 `db:"per_minute"`
+	CreatedAt time.Time     `db:"created_at"`
+}
+
+// ModelFilter restricts a search for bikes to a model or to a cargo type. Zero values match any bike.
+type ModelFilter struct {
+	ModelID   uuid.NullUUID
+	CargoType CargoType
+}
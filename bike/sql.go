@@ -4,13 +4,44 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/dberr"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
 )
 
 var (
 	ErrNotFound     = errors.New("not found")
 	ErrNotAvailable = errors.New("bike not available")
+	// ErrLabelTaken is returned when another bike already has the label.
+	ErrLabelTaken = errors.New("label already in use")
+	// ErrIMEITaken is returned when another bike already has the IMEI.
+	ErrIMEITaken = errors.New("imei already in use")
+	// ErrUnknownStation is returned when a bike is assigned to a station which doesn't exist.
+	ErrUnknownStation = errors.New("unknown station")
+	ErrRetired        = errors.New("bike retired")
+	// ErrInvalidTransition is returned when a bike can't move from its current state to the one requested.
+	ErrInvalidTransition = errors.New("invalid state transition")
+	// ErrUnknownModel is returned when a bike is given a model which doesn't exist.
+	ErrUnknownModel = errors.New("unknown model")
+	// ErrModelNotFound is returned when a model doesn't exist.
+	ErrModelNotFound = errors.New("model not found")
+	// ErrModelNameTaken is returned when another model already has the name.
+	ErrModelNameTaken = errors.New("model name already in use")
+	// ErrModelInUse is returned when deleting a model which bikes still belong to.
+	ErrModelInUse = errors.New("model in use")
+)
+
+// Names of the constraints which keep bike labels and IMEIs unique, and model names unique.
+const (
+	labelIndex     = "bikes_label"
+	imeiIndex      = "bikes_imei"
+	modelNameIndex = "bike_models_name"
+	// modelForeignKey is the constraint which ties bikes to their model.
+	modelForeignKey = "bikes_model"
 )
 
 type Repository struct {
@@ -21,14 +52,6 @@ func NewRepository(db *sqlx.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) GetBikes(ctx context.Context) ([]Bike, error) {
-	var bikes []Bike
-	err := r.db.SelectContext(ctx, &bikes, getBikes)
-	return bikes, err
-}
-
-const getBikes = `SELECT * FROM bikes`
-
 func (r *Repository) GetBike(ctx context.Context, label string) (Bike, error) {
 	var bike Bike
 
@@ -40,11 +63,15 @@ func (r *Repository) GetBike(ctx context.Context, label string) (Bike, error) {
 	return bike, err
 }
 
-const getBike = `SELECT b.*, COALESCE((bk.start_time >= NOW() AND bk.end_time <= NOW()), true) AS available, s.name as station_name
-					FROM bikes b
-					LEFT OUTER JOIN bookings bk ON b.id = bk.bike_id
-					LEFT OUTER JOIN stations s ON b.station_id = s.id
-					WHERE b.label = $1`
+const getBike = `
+SELECT b.*, s.name AS station_name,
+       m.name AS model_name, m.image_url, m.capacity, m.cargo_type, m.description AS model_description,
+       m.battery_model
+FROM bikes b
+LEFT JOIN stations s ON b.station_id = s.id
+LEFT JOIN bike_models m ON m.id = b.model_id
+WHERE b.label = $1
+`
 
 // BikeWithStation represents a bike with its station info for availability queries.
 type BikeWithStation struct {
@@ -52,27 +79,339 @@ type BikeWithStation struct {
 	StationName string `db:"station_name"`
 }
 
-// GetBikesWithStations fetches all bikes with their station info.
-func (r *Repository) GetBikesWithStations(ctx context.Context, stationID *string) ([]BikeWithStation, error) {
+// GetBikesWithStations fetches all active bikes matching the filter with their station info.
+func (r *Repository) GetBikesWithStations(ctx context.Context, stationID *string,
+	filter ModelFilter) ([]BikeWithStation, error) {
 	var bikes []BikeWithStation
 	var err error
 	if stationID != nil {
-		err = r.db.SelectContext(ctx, &bikes, getBikesWithStationsByStation, *stationID)
+		err = r.db.SelectContext(ctx, &bikes, getBikesWithStationsByStation, filter.ModelID, filter.CargoType,
+			*stationID)
 	} else {
-		err = r.db.SelectContext(ctx, &bikes, getBikesWithStations)
+		err = r.db.SelectContext(ctx, &bikes, getBikesWithStations, filter.ModelID, filter.CargoType)
 	}
 	return bikes, err
 }
 
 const getBikesWithStations = `
-SELECT b.*, COALESCE(s.name, '') as station_name
+SELECT b.*, COALESCE(s.name, '') AS station_name,
+       m.name AS model_name, m.image_url, m.capacity, m.cargo_type, m.description AS model_description,
+       m.battery_model
 FROM bikes b
 LEFT JOIN stations s ON b.station_id = s.id
+LEFT JOIN bike_models m ON m.id = b.model_id
+WHERE b.state = 'active'
+  AND ($1::uuid IS NULL OR b.model_id = $1)
+  AND ($2 = '' OR m.cargo_type = $2)
 `
 
 const getBikesWithStationsByStation = `
-SELECT b.*, COALESCE(s.name, '') as station_name
+SELECT b.*, COALESCE(s.name, '') AS station_name,
+       m.name AS model_name, m.image_url, m.capacity, m.cargo_type, m.description AS model_description,
+       m.battery_model
+FROM bikes b
+LEFT JOIN stations s ON b.station_id = s.id
+LEFT JOIN bike_models m ON m.id = b.model_id
+WHERE b.station_id = $3
+  AND b.state = 'active'
+  AND ($1::uuid IS NULL OR b.model_id = $1)
+  AND ($2 = '' OR m.cargo_type = $2)
+`
+
+// ListBikes fetches every bike in the fleet for administration, optionally including retired bikes.
+func (r *Repository) ListBikes(ctx context.Context, includeRetired bool) ([]Bike, error) {
+	var bikes []Bike
+	err := r.db.SelectContext(ctx, &bikes, listBikesQuery, includeRetired)
+	return bikes, err
+}
+
+const listBikesQuery = `
+SELECT b.*, s.name AS station_name,
+       m.name AS model_name, m.image_url, m.capacity, m.cargo_type, m.description AS model_description,
+       m.battery_model
 FROM bikes b
 LEFT JOIN stations s ON b.station_id = s.id
-WHERE b.station_id = $1
+LEFT JOIN bike_models m ON m.id = b.model_id
+WHERE $1 OR b.state != 'retired'
+ORDER BY b.label
 `
+
+// GetBikeByID fetches a bike, including retired bikes, by its ID.
+func (r *Repository) GetBikeByID(ctx context.Context, id uuid.UUID) (Bike, error) {
+	var bike Bike
+	err := r.db.GetContext(ctx, &bike, getBikeByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return bike, ErrNotFound
+	}
+	return bike, err
+}
+
+const getBikeByIDQuery = `
+SELECT b.*, s.name AS station_name,
+       m.name AS model_name, m.image_url, m.capacity, m.cargo_type, m.description AS model_description,
+       m.battery_model
+FROM bikes b
+LEFT JOIN stations s ON b.station_id = s.id
+LEFT JOIN bike_models m ON m.id = b.model_id
+WHERE b.id = $1
+`
+
+// Create adds a bike to the fleet.
+func (r *Repository) Create(ctx context.Context, b *Bike) error {
+	_, err := r.db.NamedExecContext(ctx, createBikeQuery, b)
+	if err != nil {
+		return writeError(err)
+	}
+	*b, err = r.GetBikeByID(ctx, b.ID)
+	return err
+}
+
+const createBikeQuery = `
+INSERT INTO bikes (id, label, imei, location, station_id, model_id)
+VALUES (:id, :label, :imei, :location, :station_id, :model_id)
+`
+
+// Update saves changes to a bike's details. Retired bikes can't be changed, and ErrNotFound is
+// returned if the bike has been deleted. The bike's state is changed with SetState.
+func (r *Repository) Update(ctx context.Context, b *Bike) error {
+	res, err := r.db.NamedExecContext(ctx, updateBikeQuery, b)
+	if err != nil {
+		return writeError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	saved, err := r.GetBikeByID(ctx, b.ID)
+	if err != nil {
+		return err
+	}
+	// The bike exists, so it wasn't updated because it is retired
+	if n == 0 {
+		return ErrRetired
+	}
+	*b = saved
+	return nil
+}
+
+const updateBikeQuery = `
+UPDATE bikes
+SET label = :label,
+    imei = :imei,
+    location = :location,
+    station_id = :station_id,
+    model_id = :model_id
+WHERE id = :id
+  AND state != 'retired'
+`
+
+// NearbyBike is a bike and its distance in metres from the point searched around.
+type NearbyBike struct {
+	BikeWithStation
+	Distance float64 `db:"distance"`
+}
+
+// GetNearby fetches the active bikes within radius metres of center which match the filter, nearest
+// first, leaving out the bikes at the hidden stations.
+func (r *Repository) GetNearby(ctx context.Context, center geo.Point, radius float64, filter ModelFilter,
+	hiddenStations []uuid.UUID, limit int) ([]NearbyBike, error) {
+	var bikes []NearbyBike
+	err := r.db.SelectContext(ctx, &bikes, getNearbyQuery, center, radius, filter.ModelID, filter.CargoType,
+		hiddenStations, limit)
+	return bikes, err
+}
+
+// getNearbyQuery finds the bikes using the location index, and measures distances on the spheroid.
+const getNearbyQuery = `
+SELECT b.*,
+       COALESCE(s.name, '') AS station_name,
+       m.name AS model_name, m.image_url, m.capacity, m.cargo_type, m.description AS model_description,
+       m.battery_model,
+       ST_Distance(b.location, $1::geography) AS distance
+FROM bikes b
+LEFT JOIN stations s ON b.station_id = s.id
+LEFT JOIN bike_models m ON m.id = b.model_id
+WHERE ST_DWithin(b.location, $1::geography, $2)
+  AND b.state = 'active'
+  AND ($3::uuid IS NULL OR b.model_id = $3)
+  AND ($4 = '' OR m.cargo_type = $4)
+  AND (b.station_id IS NULL OR b.station_id <> ALL($5::uuid[]))
+ORDER BY distance
+LIMIT $6
+`
+
+// SetState moves a bike to a new lifecycle state and records the change in the bike's history.
+// ErrInvalidTransition is returned if the bike can't move to the state from its current one.
+func (r *Repository) SetState(ctx context.Context, id uuid.UUID, to State, reason, changedBy string) (Bike, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Bike{}, err
+	}
+	defer tx.Rollback()
+
+	var from State
+	err = tx.GetContext(ctx, &from, getStateForUpdateQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Bike{}, ErrNotFound
+	}
+	if err != nil {
+		return Bike{}, err
+	}
+	if !from.CanTransition(to) {
+		return Bike{}, ErrInvalidTransition
+	}
+
+	nullReason := sql.NullString{String: reason, Valid: reason != ""}
+	if _, err := tx.ExecContext(ctx, setStateQuery, id, to, nullReason); err != nil {
+		return Bike{}, err
+	}
+	if _, err := tx.ExecContext(ctx, recordStateChangeQuery, uuid.New(), id, from, to, nullReason, changedBy); err != nil {
+		return Bike{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Bike{}, err
+	}
+
+	return r.GetBikeByID(ctx, id)
+}
+
+const getStateForUpdateQuery = `SELECT state FROM bikes WHERE id = $1 FOR UPDATE`
+
+const setStateQuery = `
+UPDATE bikes SET state = $2, state_reason = $3, state_changed_at = now() WHERE id = $1
+`
+
+const recordStateChangeQuery = `
+INSERT INTO bike_state_changes (id, bike_id, from_state, to_state, reason, changed_by, changed_at)
+VALUES ($1, $2, $3, $4, $5, $6, now())
+`
+
+// GetStateHistory fetches a bike's state changes, most recent first.
+func (r *Repository) GetStateHistory(ctx context.Context, id uuid.UUID) ([]StateChange, error) {
+	var changes []StateChange
+	err := r.db.SelectContext(ctx, &changes, getStateHistoryQuery, id)
+	return changes, err
+}
+
+const getStateHistoryQuery = `
+SELECT * FROM bike_state_changes WHERE bike_id = $1 ORDER BY changed_at DESC
+`
+
+// GetStateChangesBetween fetches the state changes of every bike made in [from, to), in the order
+// they were made.
+func (r *Repository) GetStateChangesBetween(ctx context.Context, from, to time.Time) ([]StateChange, error) {
+	var changes []StateChange
+	err := r.db.SelectContext(ctx, &changes, getStateChangesBetweenQuery, from, to)
+	return changes, err
+}
+
+const getStateChangesBetweenQuery = `
+SELECT * FROM bike_state_changes
+WHERE changed_at >= $1 AND changed_at < $2
+ORDER BY changed_at, id
+`
+
+// writeError maps constraint violations from inserting or updating a bike onto domain errors.
+func writeError(err error) error {
+	if constraint, ok := dberr.UniqueViolation(err); ok {
+		switch constraint {
+		case labelIndex:
+			return ErrLabelTaken
+		case imeiIndex:
+			return ErrIMEITaken
+		}
+	}
+	if constraint, ok := dberr.ForeignKeyViolation(err); ok {
+		if constraint == modelForeignKey {
+			return ErrUnknownModel
+		}
+		return ErrUnknownStation
+	}
+	return err
+}
+
+// ListModels fetches the bike model catalog.
+func (r *Repository) ListModels(ctx context.Context) ([]Model, error) {
+	var models []Model
+	err := r.db.SelectContext(ctx, &models, listModelsQuery)
+	return models, err
+}
+
+const listModelsQuery = `SELECT * FROM bike_models ORDER BY name`
+
+// GetModel fetches a bike model by its ID.
+func (r *Repository) GetModel(ctx context.Context, id uuid.UUID) (Model, error) {
+	var m Model
+	err := r.db.GetContext(ctx, &m, getModelQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return m, ErrModelNotFound
+	}
+	return m, err
+}
+
+const getModelQuery = `SELECT * FROM bike_models WHERE id = $1`
+
+// CreateModel adds a model to the catalog.
+func (r *Repository) CreateModel(ctx context.Context, m *Model) error {
+	err := r.db.GetContext(ctx, m, createModelQuery, m.ID, m.Name, m.ImageURL, m.Capacity, m.CargoType,
+		m.Description, m.BatteryModel, m.UnlockFee, m.PerMinute)
+	return modelWriteError(err)
+}
+
+const createModelQuery = `
+INSERT INTO bike_models (id, name, image_url, capacity, cargo_type, description, battery_model, unlock_fee,
+                         per_minute, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+RETURNING *
+`
+
+// UpdateModel saves changes to a model. The changes apply to every bike of the model.
+func (r *Repository) UpdateModel(ctx context.Context, m *Model) error {
+	err := r.db.GetContext(ctx, m, updateModelQuery, m.ID, m.Name, m.ImageURL, m.Capacity, m.CargoType,
+		m.Description, m.BatteryModel, m.UnlockFee, m.PerMinute)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrModelNotFound
+	}
+	return modelWriteError(err)
+}
+
+const updateModelQuery = `
+UPDATE bike_models
+SET name = $2,
+    image_url = $3,
+    capacity = $4,
+    cargo_type = $5,
+    description = $6,
+    battery_model = $7,
+    unlock_fee = $8,
+    per_minute = $9
+WHERE id = $1
+RETURNING *
+`
+
+// DeleteModel removes a model from the catalog. ErrModelInUse is returned while any bike, including
+// a retired one, is of the model.
+func (r *Repository) DeleteModel(ctx context.Context, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx, deleteModelQuery, id)
+	if _, ok := dberr.ForeignKeyViolation(err); ok {
+		return ErrModelInUse
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrModelNotFound
+	}
+	return nil
+}
+
+const deleteModelQuery = `DELETE FROM bike_models WHERE id = $1`
+
+func modelWriteError(err error) error {
+	if constraint, ok := dberr.UniqueViolation(err); ok && constraint == modelNameIndex {
+		return ErrModelNameTaken
+	}
+	return err
+}
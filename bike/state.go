@@ -0,0 +1,62 @@
+package bike
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// State is where a bike is in its lifecycle.
+type State string
+
+const (
+	// StateActive bikes are on the road and can be booked and ridden.
+	StateActive State = "active"
+	// StateMaintenance bikes are in the workshop.
+	StateMaintenance State = "maintenance"
+	// StateOutOfService bikes have been taken off the road, e.g. after a severe issue was reported,
+	// and are waiting to be looked at.
+	StateOutOfService State = "out_of_service"
+	// StateLost bikes can't be found.
+	StateLost State = "lost"
+	// StateRetired bikes have been permanently withdrawn from the fleet. Retired bikes are kept
+	// rather than deleted because rides and bookings refer to them.
+	StateRetired State = "retired"
+)
+
+// transitions lists the states a bike may move to from each state.
+var transitions = map[State][]State{
+	StateActive:       {StateMaintenance, StateOutOfService, StateLost, StateRetired},
+	StateMaintenance:  {StateActive, StateOutOfService, StateRetired},
+	StateOutOfService: {StateActive, StateMaintenance, StateLost, StateRetired},
+	StateLost:         {StateActive, StateMaintenance, StateRetired},
+	StateRetired:      {},
+}
+
+// Valid reports whether s is a known state.
+func (s State) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransition reports whether a bike in state s may be moved to state to.
+func (s State) CanTransition(to State) bool {
+	return slices.Contains(transitions[s], to)
+}
+
+// StateChange records a bike moving from one state to another.
+type StateChange struct {
+	ID        uuid.UUID      `db:"id"`
+	BikeID    uuid.UUID      `db:"bike_id"`
+	FromState State          `db:"from_state"`
+	ToState   State          `db:"to_state"`
+	Reason    sql.NullString `db:"reason"`
+	// ChangedBy is the Auth0 ID of the admin who made the change, or "system" for automatic changes
+	ChangedBy string    `db:"changed_by"`
+	ChangedAt time.Time `db:"changed_at"`
+}
+
+// ChangedBySystem marks state changes made automatically rather than by an admin.
+const ChangedBySystem = "system"
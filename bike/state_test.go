@@ -0,0 +1,51 @@
+package bike
+
+import "testing"
+
+func TestStateCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to State
+		want     bool
+	}{
+		{StateActive, StateMaintenance, true},
+		{StateActive, StateOutOfService, true},
+		{StateActive, StateLost, true},
+		{StateActive, StateRetired, true},
+		{StateActive, StateActive, false},
+
+		{StateMaintenance, StateActive, true},
+		{StateMaintenance, StateOutOfService, true},
+		{StateMaintenance, StateRetired, true},
+		{StateMaintenance, StateLost, false},
+		{StateMaintenance, StateMaintenance, false},
+
+		{StateOutOfService, StateActive, true},
+		{StateOutOfService, StateMaintenance, true},
+		{StateOutOfService, StateLost, true},
+		{StateOutOfService, StateRetired, true},
+		{StateOutOfService, StateOutOfService, false},
+
+		{StateLost, StateActive, true},
+		{StateLost, StateMaintenance, true},
+		{StateLost, StateRetired, true},
+		{StateLost, StateOutOfService, false},
+		{StateLost, StateLost, false},
+
+		{StateRetired, StateActive, false},
+		{StateRetired, StateMaintenance, false},
+		{StateRetired, StateOutOfService, false},
+		{StateRetired, StateLost, false},
+		{StateRetired, StateRetired, false},
+
+		{"unknown", StateActive, false},
+		{StateActive, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransition(tt.to); got != tt.want {
+				t.Errorf("%q.CanTransition(%q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
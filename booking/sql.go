@@ -62,8 +62,9 @@ func (r *Repository) GetByUserID(ctx context.Context, userID uuid.UUID, status *
 	return filtered, nil
 }
 
-const getByUserIDQuery = `SELECT bk.*, bikes.label as bike_label, bikes.display_name as bike_name FROM bookings bk JOIN bikes 
-    ON bk.bike_id = bikes.id WHERE user_id = $1 ORDER BY start_time ASC`
+const getByUserIDQuery = `SELECT bk.*, bikes.label as bike_label, bike_models.name as bike_name FROM bookings bk JOIN bikes 
+    ON bk.bike_id = bikes.id LEFT JOIN bike_models ON bike_models.id = bikes.model_id WHERE user_id = $1
+    ORDER BY start_time ASC`
 
 // GetCurrentByUserID fetches the currently active booking for a user.
 func (r *Repository) GetCurrentByUserID(ctx context.Context, userID string) (*Booking, error) {
@@ -245,10 +246,45 @@ func (r *Repository) GetNextBookingByOtherUser(ctx context.Context, bikeLabel st
 
 const getNextBookingByOtherUserQuery = `
 SELECT bk.* FROM bookings bk
-JOIN bikes ON bikes.label = $1
-WHERE user_id != $2
+JOIN bikes ON bikes.id = bk.bike_id
+WHERE bikes.label = $1
+  AND user_id != $2
   AND cancelled_at IS NULL
   AND start_time > $3
 ORDER BY start_time ASC
 LIMIT 1
 `
+
+// GetUpcomingBookingsForBike fetches the non-cancelled bookings for a bike which haven't finished by
+// the given time, soonest first.
+func (r *Repository) GetUpcomingBookingsForBike(ctx context.Context, bikeID uuid.UUID, after time.Time) ([]Booking, error) {
+	var bookings []Booking
+	err := r.db.SelectContext(ctx, &bookings, getUpcomingBookingsForBikeQuery, bikeID, after)
+	return bookings, err
+}
+
+const getUpcomingBookingsForBikeQuery = `
+SELECT bk.*, b.label AS bike_label FROM bookings bk
+JOIN bikes b ON b.id = bk.bike_id
+WHERE bk.bike_id = $1
+  AND bk.cancelled_at IS NULL
+  AND bk.end_time > $2
+ORDER BY bk.start_time ASC
+`
+
+// GetUpcomingBookingsForBikes fetches the non-cancelled bookings for any of the bikes which haven't
+// finished by the given time, soonest first.
+func (r *Repository) GetUpcomingBookingsForBikes(ctx context.Context, bikeIDs []uuid.UUID, after time.Time) ([]Booking, error) {
+	var bookings []Booking
+	err := r.db.SelectContext(ctx, &bookings, getUpcomingBookingsForBikesQuery, bikeIDs, after)
+	return bookings, err
+}
+
+const getUpcomingBookingsForBikesQuery = `
+SELECT bk.*, b.label AS bike_label FROM bookings bk
+JOIN bikes b ON b.id = bk.bike_id
+WHERE bk.bike_id = ANY($1)
+  AND bk.cancelled_at IS NULL
+  AND bk.end_time > $2
+ORDER BY bk.start_time ASC
+`
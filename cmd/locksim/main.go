@@ -0,0 +1,213 @@
+// Command locksim simulates smart locks so the ride flow can be exercised without hardware.
+// Each simulated lock connects to the lock gateway, answers commands and periodically reports its
+// status, and its position while unlocked.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kong"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/devicetoken"
+	"github.com/semanticallynull/bookingengine-backend/internal/lockgw"
+)
+
+var cli = struct {
+	GatewayAddr string        `name:"gateway-addr" env:"LOCK_GATEWAY_ADDR" default:"localhost:5050"`
+	DeviceKey   string        `name:"device-key" env:"DEVICE_KEY" required:""`
+	IMEI        []string      `name:"imei" help:"IMEI of a lock to simulate. May be repeated." required:""`
+	Lat         float64       `name:"lat" default:"53.3498"`
+	Lng         float64       `name:"lng" default:"-6.2603"`
+	Interval    time.Duration `name:"interval" default:"10s" help:"How often to report status and position."`
+	AckDelay    time.Duration `name:"ack-delay" help:"Delay before acknowledging commands."`
+	RejectAll   bool          `name:"reject-all" help:"Reject every command, to test failure handling."`
+}{}
+
+func main() {
+	kong.Parse(&cli)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	var wg sync.WaitGroup
+	for _, imei := range cli.IMEI {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := &simLock{
+				imei:    imei,
+				lat:     cli.Lat,
+				lng:     cli.Lng,
+				voltage: 412,
+				locked:  true,
+				logger:  logger.With("imei", imei),
+			}
+			l.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+type simLock struct {
+	imei    string
+	lat     float64
+	lng     float64
+	voltage int
+	locked  bool
+	logger  *slog.Logger
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// run keeps the lock connected to the gateway, reconnecting after failures.
+func (l *simLock) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := l.session(ctx)
+		if err != nil && ctx.Err() == nil {
+			l.logger.Warn("connection lost, reconnecting", "error", err)
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+func (l *simLock) session(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", cli.GatewayAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	l.mu.Lock()
+	l.conn = conn
+	l.mu.Unlock()
+	r := bufio.NewReader(conn)
+
+	if err := l.write(lockgw.LoginMessage(l.imei, devicetoken.Derive(cli.DeviceKey, l.imei))); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if m, err := lockgw.ParseMessage(line); err != nil || m.String() != lockgw.LoginResult(true).String() {
+		return errors.New("login rejected")
+	}
+	l.logger.Info("logged in to gateway")
+
+	done := make(chan struct{})
+	defer close(done)
+	go l.report(done)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		m, err := lockgw.ParseMessage(line)
+		if err != nil {
+			continue
+		}
+		seq, cmd, args, err := lockgw.ParseCommand(m)
+		if err != nil {
+			l.logger.Warn("unexpected message", "message", line)
+			continue
+		}
+		go l.handle(seq, cmd, args)
+	}
+}
+
+func (l *simLock) handle(seq uint64, cmd lockgw.Command, args []string) {
+	l.logger.Info("received command", "command", cmd, "args", args)
+	time.Sleep(cli.AckDelay)
+
+	if cli.RejectAll {
+		_ = l.write(lockgw.NackMessage(seq, "simulated failure"))
+		return
+	}
+
+	l.mu.Lock()
+	var reply lockgw.Message
+	switch cmd {
+	case lockgw.Unlock:
+		l.locked = false
+		reply = lockgw.AckMessage(seq)
+	case lockgw.Lock:
+		l.locked = true
+		reply = lockgw.AckMessage(seq)
+	case lockgw.Locate:
+		reply = lockgw.AckMessage(seq, fmt.Sprintf("%f", l.lat), fmt.Sprintf("%f", l.lng))
+	case lockgw.Alarm:
+		l.logger.Info("beep beep")
+		reply = lockgw.AckMessage(seq)
+	default:
+		reply = lockgw.NackMessage(seq, "unknown command")
+	}
+	l.mu.Unlock()
+
+	if err := l.write(reply); err != nil {
+		l.logger.Warn("failed to send reply", "error", err)
+	}
+}
+
+// report periodically sends the lock's status, and its position when it is being ridden.
+func (l *simLock) report(done <-chan struct{}) {
+	ticker := time.NewTicker(min(cli.Interval, lockgw.HeartbeatInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			l.mu.Lock()
+			msgs := []lockgw.Message{lockgw.StatusMessage(now, l.voltage, l.locked, -70-rand.IntN(30))}
+			if !l.locked {
+				// Wander roughly 20m in a random direction and drain the battery a little
+				l.lat += (rand.Float64() - 0.5) * 0.0004
+				l.lng += (rand.Float64() - 0.5) * 0.0006
+				l.voltage = max(340, l.voltage-1)
+				msgs = append(msgs, lockgw.PositionMessage(now, l.lat, l.lng))
+			}
+			l.mu.Unlock()
+
+			for _, m := range msgs {
+				if err := l.write(m); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (l *simLock) write(m lockgw.Message) error {
+	l.mu.Lock()
+	conn := l.conn
+	l.mu.Unlock()
+	if conn == nil {
+		return errors.New("not connected")
+	}
+	_, err := conn.Write([]byte(m.String()))
+	return err
+}
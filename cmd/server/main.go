@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 
@@ -19,9 +21,25 @@ import (
 	"github.com/semanticallynull/bookingengine-backend/booking"
 	"github.com/semanticallynull/bookingengine-backend/customer"
 	"github.com/semanticallynull/bookingengine-backend/internal/auth0"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+	"github.com/semanticallynull/bookingengine-backend/internal/billing"
+	"github.com/semanticallynull/bookingengine-backend/internal/blob"
+	"github.com/semanticallynull/bookingengine-backend/internal/gbfs"
+	"github.com/semanticallynull/bookingengine-backend/internal/gdpr"
+	"github.com/semanticallynull/bookingengine-backend/internal/jobs"
+	"github.com/semanticallynull/bookingengine-backend/internal/label"
+	"github.com/semanticallynull/bookingengine-backend/internal/lockgw"
+	"github.com/semanticallynull/bookingengine-backend/internal/mds"
+	"github.com/semanticallynull/bookingengine-backend/internal/notify"
 	"github.com/semanticallynull/bookingengine-backend/internal/o11y"
+	"github.com/semanticallynull/bookingengine-backend/internal/rebalance"
+	"github.com/semanticallynull/bookingengine-backend/issue"
+	"github.com/semanticallynull/bookingengine-backend/rating"
+	"github.com/semanticallynull/bookingengine-backend/report"
 	"github.com/semanticallynull/bookingengine-backend/ride"
 	"github.com/semanticallynull/bookingengine-backend/station"
+	"github.com/semanticallynull/bookingengine-backend/telemetry"
+	"github.com/semanticallynull/bookingengine-backend/track"
 )
 
 var cli = struct {
@@ -36,6 +54,49 @@ var cli = struct {
 
 	StripePK string `name:"stripe-pk" env:"STRIPE_PK"`
 	StripeSK string `name:"stripe-sk" env:"STRIPE_SK"`
+
+	DeviceKey       string        `name:"device-key" env:"DEVICE_KEY" help:"Key used to derive each lock's device token. The lock gateway and device endpoints are off if it isn't set."` //nolint:lll
+	LockGatewayAddr string        `name:"lock-gateway-addr" env:"LOCK_GATEWAY_ADDR" default:":5050"`
+	LockAckTimeout  time.Duration `name:"lock-ack-timeout" env:"LOCK_ACK_TIMEOUT" default:"10s"`
+
+	MaxRideDuration        time.Duration `name:"max-ride-duration" env:"MAX_RIDE_DURATION" default:"12h"`
+	LockedIdleDuration     time.Duration `name:"locked-idle-duration" env:"LOCKED_IDLE_DURATION" default:"30m"`
+	StationaryRadius       float64       `name:"stationary-radius" env:"STATIONARY_RADIUS" default:"50"`
+	AbandonedRideMaxCharge int           `name:"abandoned-ride-max-minutes" env:"ABANDONED_RIDE_MAX_MINUTES" default:"120"` //nolint:lll
+	AbandonedRideInterval  time.Duration `name:"abandoned-ride-interval" env:"ABANDONED_RIDE_INTERVAL" default:"5m"`
+	ChargeRetryInterval    time.Duration `name:"charge-retry-interval" env:"CHARGE_RETRY_INTERVAL" default:"15m"`
+
+	OpsWebhookURL string `name:"ops-webhook-url" env:"OPS_WEBHOOK_URL"`
+	SMTPAddr      string `name:"smtp-addr" env:"SMTP_ADDR" help:"Mail server customers are emailed through, e.g. smtp.example.com:587. Customer notifications are only logged if it isn't set."` //nolint:lll
+	SMTPUsername  string `name:"smtp-username" env:"SMTP_USERNAME"`
+	SMTPPassword  string `name:"smtp-password" env:"SMTP_PASSWORD"`
+	SMTPFrom      string `name:"smtp-from" env:"SMTP_FROM" help:"Address customer emails are sent from."`
+	BlobDir       string `name:"blob-dir" env:"BLOB_DIR" default:"data/blobs" help:"Directory uploaded files are stored in."`
+
+	ExportKey      string        `name:"export-key" env:"EXPORT_KEY" help:"Secret customer export download links are signed with. Links stop working on restart if it isn't set."` //nolint:lll
+	ExportInterval time.Duration `name:"export-interval" env:"EXPORT_INTERVAL" default:"1m"`
+
+	LabelPrefix   string `name:"label-prefix" env:"LABEL_PREFIX" help:"Prefix of bike labels, e.g. CARGO-."`
+	LabelDigits   int    `name:"label-digits" env:"LABEL_DIGITS" help:"Digits in bike labels before the check digit. 0 disables label validation."`         //nolint:lll
+	LabelLinkBase string `name:"label-link-base" env:"LABEL_LINK_BASE" default:"bikeshare://bikes/" help:"Deep link the label is appended to in QR codes."` //nolint:lll
+
+	GBFSKey          string  `name:"gbfs-key" env:"GBFS_KEY" help:"Secret published vehicle IDs are derived from. GBFS feeds are only published if it is set."`           //nolint:lll
+	GBFSBaseURL      string  `name:"gbfs-base-url" env:"GBFS_BASE_URL" help:"Public URL of the GBFS feeds, e.g. https://api.example.com/gbfs. Required to publish them."` //nolint:lll
+	GBFSSystemID     string  `name:"gbfs-system-id" env:"GBFS_SYSTEM_ID" default:"bikeshare"`
+	GBFSName         string  `name:"gbfs-name" env:"GBFS_NAME" default:"Cargo Bike Share"`
+	GBFSOperator     string  `name:"gbfs-operator" env:"GBFS_OPERATOR"`
+	GBFSURL          string  `name:"gbfs-url" env:"GBFS_URL" help:"Website of the system."`
+	GBFSEmail        string  `name:"gbfs-email" env:"GBFS_EMAIL" help:"Contact for problems with the feeds. Required to publish them."` //nolint:lll
+	GBFSLanguage     string  `name:"gbfs-language" env:"GBFS_LANGUAGE" default:"en"`
+	GBFSTimezone     string  `name:"gbfs-timezone" env:"GBFS_TIMEZONE" default:"UTC" help:"Time zone station opening hours are in."`      //nolint:lll
+	GBFSOpeningHours string  `name:"gbfs-opening-hours" env:"GBFS_OPENING_HOURS" default:"24/7" help:"OpenStreetMap opening_hours."`      //nolint:lll
+	GBFSMaxRange     float64 `name:"gbfs-max-range" env:"GBFS_MAX_RANGE" default:"50000" help:"Range of a fully charged bike in metres."` //nolint:lll
+
+	MDSToken        string `name:"mds-token" env:"MDS_TOKEN" help:"Bearer token the regulator calls the MDS provider API with. The API is off if it isn't set."` //nolint:lll
+	MDSProviderID   string `name:"mds-provider-id" env:"MDS_PROVIDER_ID" help:"Provider ID assigned to us by MDS."`
+	MDSProviderName string `name:"mds-provider-name" env:"MDS_PROVIDER_NAME" default:"Cargo Bike Share"`
+
+	BatteryCurves string `name:"battery-curves" env:"BATTERY_CURVES" help:"JSON file of voltage curves per battery model."` //nolint:lll
 }{}
 
 func main() {
@@ -65,6 +126,18 @@ func run() error {
 	cr := customer.NewRepository(db)
 	rr := ride.NewRepository(db)
 	bkr := booking.NewRepository(db)
+	tr := track.NewRepository(db)
+	telr := telemetry.NewRepository(db)
+	ir := issue.NewRepository(db)
+	ratr := rating.NewRepository(db)
+	rptr := report.NewRepository(db)
+	avail := availability.New(rr, bkr)
+	rebalancer := rebalance.New(sr, br, rr, bkr)
+
+	batteryCurves, err := bike.LoadBatteryCurves(cli.BatteryCurves)
+	if err != nil {
+		return err
+	}
 
 	obs, cleanup, err := o11y.Setup(ctx)
 	defer cleanup()
@@ -74,8 +147,135 @@ func run() error {
 
 	auth0Client := auth0.NewHTTPClient(cli.Auth0Domain)
 
-	a := api.New(br, sr, cr, rr, bkr, auth0Client, obs, cli.Auth0Domain, cli.Audience, cli.MetricsUsername, cli.MetricsPassword,
-		cli.StripePK, cli.StripeSK)
+	// Without a device key anyone could log in as a lock, so the gateway isn't started and every
+	// lock is reported as not connected
+	gw := lockgw.New(cli.DeviceKey, cli.LockAckTimeout, tr, telr, obs.Logger)
+	if cli.DeviceKey != "" {
+		go func() {
+			if err := gw.ListenAndServe(ctx, cli.LockGatewayAddr); err != nil {
+				log.Fatalf("failed to start lock gateway: %v", err)
+			}
+		}()
+	} else {
+		obs.Logger.WarnContext(ctx, "no device key set, the lock gateway is off and bikes can't be unlocked")
+	}
+
+	biller := billing.NewBiller(rr)
+	var notifier notify.Notifier = notify.NewLogNotifier(obs.Logger, cli.OpsWebhookURL)
+	if cli.SMTPAddr != "" {
+		notifier = notify.NewEmailNotifier(notify.SMTPConfig{
+			Addr:     cli.SMTPAddr,
+			Username: cli.SMTPUsername,
+			Password: cli.SMTPPassword,
+			From:     cli.SMTPFrom,
+		}, func(ctx context.Context, customerID uuid.UUID) (string, error) {
+			cust, err := cr.GetCustomer(ctx, customerID)
+			if err != nil {
+				return "", err
+			}
+			return cust.Email.String, nil
+		}, notifier)
+	} else {
+		obs.Logger.WarnContext(ctx, "no SMTP server set, customer notifications will only be logged")
+	}
+
+	abandoned := jobs.NewAbandonedRides(rr, cr, biller, notifier, ride.AbandonPolicy{
+		MaxDuration:      cli.MaxRideDuration,
+		LockedIdle:       cli.LockedIdleDuration,
+		StationaryRadius: cli.StationaryRadius,
+		MaxBilledMinutes: cli.AbandonedRideMaxCharge,
+	}, obs.Logger)
+	uncharged := jobs.NewUnchargedRides(rr, cr, biller, obs.Logger)
+
+	blobs := blob.NewFileSystem(cli.BlobDir)
+
+	collector := gdpr.New(cr, bkr, rr, tr, sr, ratr, ir)
+	exports := jobs.NewCustomerExports(cr, collector, blobs, obs.Logger)
+	exportKey := []byte(cli.ExportKey)
+	if len(exportKey) == 0 {
+		exportKey = make([]byte, 32)
+		if _, err := rand.Read(exportKey); err != nil {
+			return fmt.Errorf("failed to generate export key: %w", err)
+		}
+		obs.Logger.WarnContext(ctx, "no export key set, customer export links will stop working on restart")
+	}
+
+	var feeds *gbfs.Publisher
+	if cli.GBFSKey != "" {
+		tz, err := time.LoadLocation(cli.GBFSTimezone)
+		if err != nil {
+			return fmt.Errorf("invalid GBFS time zone: %w", err)
+		}
+		// The feeds link to each other by URL, and 3.0 requires a contact for them
+		if cli.GBFSBaseURL == "" || cli.GBFSEmail == "" {
+			return errors.New("--gbfs-base-url and --gbfs-email are required to publish GBFS feeds")
+		}
+		feeds = gbfs.New(gbfs.Config{
+			BaseURL:      cli.GBFSBaseURL,
+			SystemID:     cli.GBFSSystemID,
+			Name:         cli.GBFSName,
+			Operator:     cli.GBFSOperator,
+			URL:          cli.GBFSURL,
+			Email:        cli.GBFSEmail,
+			Language:     cli.GBFSLanguage,
+			Timezone:     tz,
+			OpeningHours: cli.GBFSOpeningHours,
+			Key:          []byte(cli.GBFSKey),
+			MaxRange:     cli.GBFSMaxRange,
+		}, sr, br, rr, avail, batteryCurves)
+	}
+
+	var provider *mds.Provider
+	if cli.MDSToken != "" {
+		providerID, err := uuid.Parse(cli.MDSProviderID)
+		if err != nil {
+			return fmt.Errorf("invalid MDS provider ID: %w", err)
+		}
+		provider = mds.New(mds.Config{ProviderID: providerID, ProviderName: cli.MDSProviderName}, rr, br, tr,
+			batteryCurves)
+	}
+
+	a := api.New(api.Deps{
+		Bikes:         br,
+		Stations:      sr,
+		Customers:     cr,
+		Rides:         rr,
+		Bookings:      bkr,
+		Tracks:        tr,
+		Telemetry:     telr,
+		Issues:        ir,
+		Ratings:       ratr,
+		Reports:       rptr,
+		Availability:  avail,
+		Rebalancer:    rebalancer,
+		Feeds:         feeds,
+		MDS:           provider,
+		Collector:     collector,
+		Auth0:         auth0Client,
+		Locks:         gw,
+		Biller:        biller,
+		Notifier:      notifier,
+		Blobs:         blobs,
+		Observability: obs,
+		BatteryCurves: batteryCurves,
+	}, api.Config{
+		Auth0Domain:     cli.Auth0Domain,
+		Audience:        cli.Audience,
+		MetricsUsername: cli.MetricsUsername,
+		MetricsPassword: cli.MetricsPassword,
+		StripePK:        cli.StripePK,
+		StripeSK:        cli.StripeSK,
+		DeviceKey:       cli.DeviceKey,
+		RegulatorToken:  cli.MDSToken,
+		ExportKey:       exportKey,
+		LabelFormat:     label.Format{Prefix: cli.LabelPrefix, Digits: cli.LabelDigits},
+		LabelLinkBase:   cli.LabelLinkBase,
+	})
+
+	// Started after the API, which configures the Stripe client the jobs charge rides with
+	go abandoned.Run(ctx, cli.AbandonedRideInterval)
+	go uncharged.Run(ctx, cli.ChargeRetryInterval)
+	go exports.Run(ctx, cli.ExportInterval)
 
 	serv := http.Server{
 		Addr:    fmt.Sprintf(":%d", cli.Port),
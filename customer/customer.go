@@ -14,4 +14,7 @@ type Customer struct {
 	Email     sql.NullString `db:"email"`
 	Name      sql.NullString `db:"name"`
 	CreatedAt time.Time      `db:"created_at"`
+	// VerifiedEmail is the address Auth0 has verified the customer owns. Unlike Email, which the
+	// customer can change freely, it can be trusted to admit them to private stations by domain
+	VerifiedEmail sql.NullString `db:"verified_email"`
 }
@@ -0,0 +1,155 @@
+package customer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrExportNotFound is returned for an export which doesn't exist or belongs to another customer.
+var ErrExportNotFound = errors.New("export not found")
+
+// ExportStatus is where an export of a customer's personal data is in its lifecycle.
+type ExportStatus string
+
+const (
+	// ExportPending exports are waiting to be generated.
+	ExportPending ExportStatus = "pending"
+	// ExportReady exports can be downloaded until they expire.
+	ExportReady ExportStatus = "ready"
+	// ExportFailed exports couldn't be generated. The customer can ask for another.
+	ExportFailed ExportStatus = "failed"
+	// ExportExpired exports have been deleted from storage.
+	ExportExpired ExportStatus = "expired"
+)
+
+// Export is an archive of a customer's personal data which is generated in the background.
+type Export struct {
+	ID         uuid.UUID    `db:"id"`
+	CustomerID uuid.UUID    `db:"customer_id"`
+	Status     ExportStatus `db:"status"`
+	// BlobKey is where the archive is stored once it is ready
+	BlobKey     sql.NullString `db:"blob_key"`
+	Error       sql.NullString `db:"error"`
+	CreatedAt   time.Time      `db:"created_at"`
+	CompletedAt sql.NullTime   `db:"completed_at"`
+	// ExpiresAt is when a ready export stops being available to download
+	ExpiresAt sql.NullTime `db:"expires_at"`
+}
+
+// CreateExport asks for an export of the customer's data. If one is already pending, or is ready
+// and hasn't expired, it is returned instead.
+func (r *Repository) CreateExport(ctx context.Context, customerID uuid.UUID) (Export, error) {
+	var e Export
+	err := r.db.GetContext(ctx, &e, getOpenExportQuery, customerID)
+	if err == nil {
+		return e, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Export{}, err
+	}
+
+	err = r.db.GetContext(ctx, &e, createExportQuery, uuid.New(), customerID)
+	return e, err
+}
+
+const getOpenExportQuery = `
+SELECT * FROM customer_exports
+WHERE customer_id = $1
+  AND (status = 'pending' OR (status = 'ready' AND expires_at > now()))
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+const createExportQuery = `
+INSERT INTO customer_exports (id, customer_id, status, created_at)
+VALUES ($1, $2, 'pending', now())
+RETURNING *
+`
+
+// GetExport fetches one of the customer's exports.
+func (r *Repository) GetExport(ctx context.Context, customerID, id uuid.UUID) (Export, error) {
+	var e Export
+	err := r.db.GetContext(ctx, &e, getExportQuery, id, customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Export{}, ErrExportNotFound
+	}
+	return e, err
+}
+
+const getExportQuery = `SELECT * FROM customer_exports WHERE id = $1 AND customer_id = $2`
+
+// GetExportByID fetches an export whoever it belongs to, e.g. for a signed download link.
+func (r *Repository) GetExportByID(ctx context.Context, id uuid.UUID) (Export, error) {
+	var e Export
+	err := r.db.GetContext(ctx, &e, getExportByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Export{}, ErrExportNotFound
+	}
+	return e, err
+}
+
+const getExportByIDQuery = `SELECT * FROM customer_exports WHERE id = $1`
+
+// GetPendingExports fetches the exports waiting to be generated, oldest first.
+func (r *Repository) GetPendingExports(ctx context.Context) ([]Export, error) {
+	var exports []Export
+	err := r.db.SelectContext(ctx, &exports, getPendingExportsQuery)
+	return exports, err
+}
+
+const getPendingExportsQuery = `SELECT * FROM customer_exports WHERE status = 'pending' ORDER BY created_at`
+
+// CompleteExport records that the export has been stored at blobKey and can be downloaded until
+// expiresAt.
+func (r *Repository) CompleteExport(ctx context.Context, id uuid.UUID, blobKey string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, completeExportQuery, id, blobKey, expiresAt)
+	return err
+}
+
+const completeExportQuery = `
+UPDATE customer_exports
+SET status = 'ready', blob_key = $2, completed_at = now(), expires_at = $3
+WHERE id = $1
+`
+
+// FailExport records that the export couldn't be generated.
+func (r *Repository) FailExport(ctx context.Context, id uuid.UUID, reason string) error {
+	_, err := r.db.ExecContext(ctx, failExportQuery, id, reason)
+	return err
+}
+
+const failExportQuery = `
+UPDATE customer_exports SET status = 'failed', error = $2, completed_at = now() WHERE id = $1
+`
+
+// GetExpiredExports fetches the ready exports which expired before t.
+func (r *Repository) GetExpiredExports(ctx context.Context, t time.Time) ([]Export, error) {
+	var exports []Export
+	err := r.db.SelectContext(ctx, &exports, getExpiredExportsQuery, t)
+	return exports, err
+}
+
+const getExpiredExportsQuery = `SELECT * FROM customer_exports WHERE status = 'ready' AND expires_at <= $1`
+
+// ExpireExport records that the export's archive has been deleted.
+func (r *Repository) ExpireExport(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, expireExportQuery, id)
+	return err
+}
+
+const expireExportQuery = `UPDATE customer_exports SET status = 'expired', blob_key = NULL WHERE id = $1`
+
+// CountActivity counts the customer's bookings and rides, which make up most of an export.
+func (r *Repository) CountActivity(ctx context.Context, customerID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, countActivityQuery, customerID)
+	return n, err
+}
+
+const countActivityQuery = `
+SELECT (SELECT count(*) FROM bookings WHERE user_id = $1) + (SELECT count(*) FROM rides WHERE customer_id = $1)
+`
@@ -37,6 +37,22 @@ func (r *Repository) GetCustomerByAuth0ID(auth0ID string) (*Customer, error) {
 
 const getCustomerByAuth0IDQuery = "SELECT * FROM customers WHERE auth0_id = $1"
 
+// GetCustomer fetches a customer by their internal ID.
+func (r *Repository) GetCustomer(ctx context.Context, id uuid.UUID) (*Customer, error) {
+	var customer Customer
+	err := r.db.GetContext(ctx, &customer, getCustomerQuery, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+	return &customer, nil
+}
+
+const getCustomerQuery = "SELECT * FROM customers WHERE id = $1"
+
 func (r *Repository) CreateCustomer(auth0ID string) (*Customer, error) {
 	var customer Customer
 	err := r.db.Get(&customer, createCustomerQuery, uuid.New(), auth0ID)
@@ -80,3 +96,11 @@ func (r *Repository) UpdateProfile(ctx context.Context, auth0ID, email, name str
 }
 
 const updateProfileQuery = `UPDATE customers SET email = NULLIF($1, ''), name = NULLIF($2, '') WHERE auth0_id = $3`
+
+// SetVerifiedEmail records the address Auth0 has verified the customer owns. An empty email clears it.
+func (r *Repository) SetVerifiedEmail(ctx context.Context, auth0ID, email string) error {
+	_, err := r.db.ExecContext(ctx, setVerifiedEmailQuery, email, auth0ID)
+	return err
+}
+
+const setVerifiedEmailQuery = `UPDATE customers SET verified_email = NULLIF(lower($1), '') WHERE auth0_id = $2`
@@ -0,0 +1,154 @@
+// Package availability decides whether bikes can be taken out now, taking into account their
+// lifecycle state, rides in progress and bookings.
+package availability
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/booking"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+)
+
+// BookingBuffer is the time kept clear before another customer's booking starts, so the bike is
+// back at its station in time.
+const BookingBuffer = time.Hour
+
+// Reason explains why a bike isn't available.
+type Reason string
+
+const (
+	// ReasonOutOfService bikes aren't active, e.g. they are in the workshop or retired.
+	ReasonOutOfService Reason = "out_of_service"
+	// ReasonInUse bikes have a ride in progress.
+	ReasonInUse Reason = "in_use"
+	// ReasonBooked bikes are booked by another customer now.
+	ReasonBooked Reason = "booked"
+	// ReasonBookingSoon bikes are booked by another customer within BookingBuffer.
+	ReasonBookingSoon Reason = "booking_soon"
+)
+
+// Result is the availability of a bike to a customer at a point in time.
+type Result struct {
+	Available bool
+	// Reason explains why the bike isn't available. It is empty for available bikes.
+	Reason Reason
+	// Until is when an available bike has to be back, because another customer's booking starts
+	// BookingBuffer later. It is nil when the bike isn't booked by anyone else.
+	Until *time.Time
+	// From is when an unavailable bike is expected to be free again, if that is known.
+	From *time.Time
+	// Booking is the customer's own booking which covers the time, if any.
+	Booking *booking.Booking
+	// NextBooking is the next booking of the bike by another customer, if any.
+	NextBooking *booking.Booking
+	// Rider is the customer riding the bike, if it is in use.
+	Rider uuid.NullUUID
+}
+
+// Service checks bike availability.
+type Service struct {
+	rr  *ride.Repository
+	bkr *booking.Repository
+}
+
+func New(rr *ride.Repository, bkr *booking.Repository) *Service {
+	return &Service{rr: rr, bkr: bkr}
+}
+
+// Check decides whether a bike is available to the customer at the given time. customerID may be
+// uuid.Nil when the customer isn't known, in which case every booking is someone else's.
+func (s *Service) Check(ctx context.Context, b bike.Bike, customerID uuid.UUID, at time.Time) (Result, error) {
+	results, err := s.CheckAll(ctx, []bike.Bike{b}, customerID, at)
+	if err != nil {
+		return Result{}, err
+	}
+	return results[b.ID], nil
+}
+
+// CheckAll decides whether each of the bikes is available to the customer at the given time. The
+// results are keyed by bike ID.
+func (s *Service) CheckAll(ctx context.Context, bikes []bike.Bike, customerID uuid.UUID,
+	at time.Time) (map[uuid.UUID]Result, error) {
+	ids := make([]uuid.UUID, 0, len(bikes))
+	for _, b := range bikes {
+		ids = append(ids, b.ID)
+	}
+
+	rides, err := s.rr.GetActiveRidesForBikes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	activeRides := make(map[uuid.UUID]ride.Ride, len(rides))
+	for _, r := range rides {
+		activeRides[r.BikeID] = r
+	}
+
+	bookings, err := s.bkr.GetUpcomingBookingsForBikes(ctx, ids, at)
+	if err != nil {
+		return nil, err
+	}
+	bikeBookings := make(map[uuid.UUID][]booking.Booking)
+	for _, bk := range bookings {
+		bikeBookings[bk.BikeID] = append(bikeBookings[bk.BikeID], bk)
+	}
+
+	results := make(map[uuid.UUID]Result, len(bikes))
+	for _, b := range bikes {
+		var active *ride.Ride
+		if r, ok := activeRides[b.ID]; ok {
+			active = &r
+		}
+		results[b.ID] = Decide(b, active, bikeBookings[b.ID], customerID, at)
+	}
+	return results, nil
+}
+
+// Decide works out whether a bike is available to the customer at the given time from its state,
+// the ride in progress on it, if any, and its bookings which haven't finished, soonest first.
+func Decide(b bike.Bike, active *ride.Ride, bookings []booking.Booking, customerID uuid.UUID, at time.Time) Result {
+	if b.State != bike.StateActive {
+		return Result{Reason: ReasonOutOfService}
+	}
+
+	var res Result
+	if active != nil {
+		res.Reason = ReasonInUse
+		res.Rider = uuid.NullUUID{UUID: active.CustomerID, Valid: true}
+	}
+
+	for _, bk := range bookings {
+		if bk.UserID == customerID {
+			if !bk.StartTime.After(at) && res.Booking == nil {
+				res.Booking = &bk
+			}
+			continue
+		}
+		if !bk.StartTime.After(at) {
+			if res.Reason == "" {
+				res.Reason = ReasonBooked
+				res.From = &bk.EndTime
+			}
+			res.NextBooking = &bk
+			return res
+		}
+		if res.NextBooking == nil {
+			res.NextBooking = &bk
+		}
+	}
+
+	if res.NextBooking != nil {
+		until := res.NextBooking.StartTime.Add(-BookingBuffer)
+		res.Until = &until
+		if !until.After(at) && res.Reason == "" {
+			res.Reason = ReasonBookingSoon
+			res.From = &res.NextBooking.EndTime
+		}
+	}
+
+	res.Available = res.Reason == ""
+	return res
+}
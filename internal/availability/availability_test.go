@@ -0,0 +1,183 @@
+package availability
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/booking"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+)
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	customer := uuid.New()
+	other := uuid.New()
+
+	active := bike.Bike{ID: uuid.New(), State: bike.StateActive}
+	bookingAt := func(user uuid.UUID, start, end time.Duration) booking.Booking {
+		return booking.Booking{ID: uuid.New(), BikeID: active.ID, UserID: user,
+			StartTime: now.Add(start), EndTime: now.Add(end)}
+	}
+	ownNow := bookingAt(customer, -time.Hour, time.Hour)
+	otherNow := bookingAt(other, -time.Hour, 2*time.Hour)
+	otherSoon := bookingAt(other, 30*time.Minute, 3*time.Hour)
+	otherLater := bookingAt(other, 4*time.Hour, 6*time.Hour)
+	riding := &ride.Ride{ID: uuid.New(), BikeID: active.ID, CustomerID: other, StartedAt: now.Add(-time.Minute)}
+
+	tests := []struct {
+		name        string
+		bike        bike.Bike
+		active      *ride.Ride
+		bookings    []booking.Booking
+		customer    uuid.UUID
+		available   bool
+		reason      Reason
+		until       *time.Time
+		from        *time.Time
+		booking     *booking.Booking
+		nextBooking *booking.Booking
+		rider       bool
+	}{
+		{
+			name:      "free bike",
+			bike:      active,
+			customer:  customer,
+			available: true,
+		},
+		{
+			name:     "not active",
+			bike:     bike.Bike{ID: active.ID, State: bike.StateMaintenance},
+			customer: customer,
+			reason:   ReasonOutOfService,
+		},
+		{
+			name:     "out of service even when booked by the customer",
+			bike:     bike.Bike{ID: active.ID, State: bike.StateOutOfService},
+			bookings: []booking.Booking{ownNow},
+			customer: customer,
+			reason:   ReasonOutOfService,
+		},
+		{
+			name:     "ride in progress",
+			bike:     active,
+			active:   riding,
+			customer: customer,
+			reason:   ReasonInUse,
+			rider:    true,
+		},
+		{
+			name:      "customer's own booking",
+			bike:      active,
+			bookings:  []booking.Booking{ownNow},
+			customer:  customer,
+			available: true,
+			booking:   &ownNow,
+		},
+		{
+			name:        "booked by someone else",
+			bike:        active,
+			bookings:    []booking.Booking{otherNow},
+			customer:    customer,
+			reason:      ReasonBooked,
+			from:        &otherNow.EndTime,
+			nextBooking: &otherNow,
+		},
+		{
+			name:        "booked by someone else while in use",
+			bike:        active,
+			active:      riding,
+			bookings:    []booking.Booking{otherNow},
+			customer:    customer,
+			reason:      ReasonInUse,
+			nextBooking: &otherNow,
+			rider:       true,
+		},
+		{
+			name:        "someone else's booking within the buffer",
+			bike:        active,
+			bookings:    []booking.Booking{otherSoon},
+			customer:    customer,
+			reason:      ReasonBookingSoon,
+			until:       timePtr(otherSoon.StartTime.Add(-BookingBuffer)),
+			from:        &otherSoon.EndTime,
+			nextBooking: &otherSoon,
+		},
+		{
+			name:        "someone else's booking after the buffer",
+			bike:        active,
+			bookings:    []booking.Booking{otherLater},
+			customer:    customer,
+			available:   true,
+			until:       timePtr(otherLater.StartTime.Add(-BookingBuffer)),
+			nextBooking: &otherLater,
+		},
+		{
+			name:        "own booking followed by someone else's",
+			bike:        active,
+			bookings:    []booking.Booking{ownNow, otherLater},
+			customer:    customer,
+			available:   true,
+			until:       timePtr(otherLater.StartTime.Add(-BookingBuffer)),
+			booking:     &ownNow,
+			nextBooking: &otherLater,
+		},
+		{
+			name:        "unknown customer treats every booking as someone else's",
+			bike:        active,
+			bookings:    []booking.Booking{ownNow},
+			customer:    uuid.Nil,
+			reason:      ReasonBooked,
+			from:        &ownNow.EndTime,
+			nextBooking: &ownNow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Decide(tt.bike, tt.active, tt.bookings, tt.customer, now)
+
+			if res.Available != tt.available {
+				t.Errorf("Available = %v, want %v", res.Available, tt.available)
+			}
+			if res.Reason != tt.reason {
+				t.Errorf("Reason = %q, want %q", res.Reason, tt.reason)
+			}
+			if !equalTime(res.Until, tt.until) {
+				t.Errorf("Until = %v, want %v", res.Until, tt.until)
+			}
+			if !equalTime(res.From, tt.from) {
+				t.Errorf("From = %v, want %v", res.From, tt.from)
+			}
+			if !equalBooking(res.Booking, tt.booking) {
+				t.Errorf("Booking = %v, want %v", res.Booking, tt.booking)
+			}
+			if !equalBooking(res.NextBooking, tt.nextBooking) {
+				t.Errorf("NextBooking = %v, want %v", res.NextBooking, tt.nextBooking)
+			}
+			if res.Rider.Valid != tt.rider || (tt.rider && res.Rider.UUID != tt.active.CustomerID) {
+				t.Errorf("Rider = %v, want the rider: %v", res.Rider, tt.rider)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func equalBooking(a, b *booking.Booking) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID
+}
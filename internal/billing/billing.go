@@ -0,0 +1,139 @@
+// Package billing charges customers for their rides.
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/stripe/stripe-go/v84"
+	"github.com/stripe/stripe-go/v84/invoice"
+
+	"github.com/semanticallynull/bookingengine-backend/ride"
+)
+
+// vatRate is the reduced rate of VAT, in percent, included in ride prices.
+const vatRate = 13.5
+
+// Biller invoices customers for rides through Stripe.
+type Biller struct {
+	rr *ride.Repository
+}
+
+func NewBiller(rr *ride.Repository) *Biller {
+	return &Biller{rr: rr}
+}
+
+// ChargeRide invoices the customer for a ride and pays the invoice with their default payment
+// method. Each ride is only invoiced once, so it is safe to call again for a ride which has
+// already been charged. If the invoice can't be finalized the ride is left to be charged by the
+// next call, which finishes the same invoice.
+func (b *Biller) ChargeRide(ctx context.Context, stripeCustomerID string, s ride.Summary) error {
+	if s.Price() == 0 {
+		return nil
+	}
+
+	invoiceID, claimed, err := b.rr.ClaimCharge(ctx, s.RideID)
+	if err != nil {
+		return fmt.Errorf("failed to claim charge: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	in, err := b.finalizeInvoice(ctx, stripeCustomerID, invoiceID, s)
+	if err != nil {
+		if rerr := b.rr.ReleaseCharge(context.WithoutCancel(ctx), s.RideID); rerr != nil {
+			return errors.Join(err, fmt.Errorf("failed to release charge: %w", rerr))
+		}
+		return err
+	}
+
+	// Stripe retries the payment of a finalized invoice itself, so the ride stays claimed if it fails
+	if in.Status == stripe.InvoiceStatusOpen {
+		_, err = invoice.Pay(in.ID, &stripe.InvoicePayParams{Params: stripe.Params{Context: ctx}})
+		if err != nil {
+			return fmt.Errorf("failed to pay invoice: %w", err)
+		}
+	}
+	return nil
+}
+
+// finalizeInvoice takes the ride's invoice as far as being finalized. Steps an earlier attempt got
+// past are skipped, and creating the invoice is keyed on the ride so it is only created once.
+func (b *Biller) finalizeInvoice(ctx context.Context, stripeCustomerID, invoiceID string,
+	s ride.Summary) (*stripe.Invoice, error) {
+	var in *stripe.Invoice
+	var err error
+	if invoiceID != "" {
+		in, err = invoice.Get(invoiceID, &stripe.InvoiceParams{Params: stripe.Params{Context: ctx}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get invoice: %w", err)
+		}
+	} else {
+		in, err = invoice.New(&stripe.InvoiceParams{
+			Params: stripe.Params{
+				Context:        ctx,
+				IdempotencyKey: stripe.String("ride-invoice-" + s.RideID.String()),
+			},
+			Customer: stripe.String(stripeCustomerID),
+			Metadata: map[string]string{"ride_id": s.RideID.String()},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create invoice: %w", err)
+		}
+		if err := b.rr.SetInvoice(ctx, s.RideID, in.ID); err != nil {
+			return nil, fmt.Errorf("failed to record invoice: %w", err)
+		}
+	}
+
+	if in.Status == stripe.InvoiceStatusDraft && in.Subtotal == 0 {
+		var lines []*stripe.InvoiceAddLinesLineParams
+		if s.UnlockFee > 0 {
+			lines = append(lines, invoiceLine(s.UnlockFee, "Ride Unlock"))
+		}
+		if s.TimeCharge > 0 {
+			lines = append(lines, invoiceLine(s.TimeCharge, fmt.Sprintf("Ride - %d minutes", s.BilledMinutes)))
+		}
+
+		in, err = invoice.AddLines(in.ID, &stripe.InvoiceAddLinesParams{
+			Params: stripe.Params{Context: ctx},
+			Lines:  lines,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add lines to invoice: %w", err)
+		}
+	}
+
+	if in.Status == stripe.InvoiceStatusDraft {
+		in, err = invoice.FinalizeInvoice(in.ID, &stripe.InvoiceFinalizeInvoiceParams{
+			Params: stripe.Params{Context: ctx},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to finalize invoice: %w", err)
+		}
+	}
+	return in, nil
+}
+
+// invoiceLine builds an invoice line for a VAT inclusive amount in cents.
+func invoiceLine(amount int, description string) *stripe.InvoiceAddLinesLineParams {
+	tax := int64(math.Round(float64(amount) * vatRate / (100 + vatRate)))
+	return &stripe.InvoiceAddLinesLineParams{
+		Amount:      stripe.Int64(int64(amount)),
+		Description: stripe.String(description),
+		TaxAmounts: []*stripe.InvoiceAddLinesLineTaxAmountParams{
+			{
+				Amount:        stripe.Int64(tax),
+				TaxableAmount: stripe.Int64(int64(amount) - tax),
+				TaxRateData: &stripe.InvoiceAddLinesLineTaxAmountTaxRateDataParams{
+					Percentage:  stripe.Float64(vatRate),
+					Description: stripe.String("VAT - Reduced Rate"),
+					DisplayName: stripe.String("VAT - Reduced Rate (13.5%)"),
+					Inclusive:   stripe.Bool(true),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,33 @@
+// Package dberr helps map Postgres errors onto domain errors.
+package dberr
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// UniqueViolation reports whether err is a unique constraint violation, and if so which constraint
+// or unique index was violated.
+func UniqueViolation(err error) (string, bool) {
+	return violation(err, uniqueViolation)
+}
+
+// ForeignKeyViolation reports whether err is a foreign key violation, and if so which constraint
+// was violated.
+func ForeignKeyViolation(err error) (string, bool) {
+	return violation(err, foreignKeyViolation)
+}
+
+func violation(err error, code string) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == code {
+		return pgErr.ConstraintName, true
+	}
+	return "", false
+}
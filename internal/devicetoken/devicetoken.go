@@ -0,0 +1,22 @@
+// Package devicetoken derives the credentials locks authenticate with, over HTTP and to the lock
+// gateway.
+package devicetoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Derive derives the token a device with the given IMEI authenticates with. Tokens are provisioned
+// onto the locks, so each device only ever knows its own credential.
+func Derive(key, imei string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(imei))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Valid reports whether token is the token of the device with the given IMEI.
+func Valid(key, imei, token string) bool {
+	return hmac.Equal([]byte(token), []byte(Derive(key, imei)))
+}
@@ -0,0 +1,285 @@
+// Package gbfs publishes the system's stations and bikes as a General Bikeshare Feed Specification
+// (https://gbfs.org) feed, in versions 2.3 and 3.0, for journey planners and the city.
+//
+// Only public stations, and the bikes which aren't at a private station, are published. Vehicle IDs
+// are derived from the bike and its last ride, so they change after every trip as the specification
+// requires and can't be used to follow a bike from trip to trip.
+package gbfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+// Version is a version of the specification.
+type Version string
+
+const (
+	V2 Version = "2.3"
+	V3 Version = "3.0"
+)
+
+// Versions are the versions published, oldest first.
+var Versions = []Version{V2, V3}
+
+// Valid reports whether v is a published version.
+func (v Version) Valid() bool {
+	return v == V2 || v == V3
+}
+
+// Feed names.
+const (
+	FeedDiscovery          = "gbfs"
+	FeedVersions           = "gbfs_versions"
+	FeedSystemInformation  = "system_information"
+	FeedVehicleTypes       = "vehicle_types"
+	FeedStationInformation = "station_information"
+	FeedStationStatus      = "station_status"
+	// FeedFreeBikeStatus is the 2.3 name of FeedVehicleStatus.
+	FeedFreeBikeStatus = "free_bike_status"
+	FeedVehicleStatus  = "vehicle_status"
+)
+
+// Feeds lists the feeds published in each version, as listed in the discovery feed.
+func (v Version) Feeds() []string {
+	vehicles := FeedVehicleStatus
+	if v == V2 {
+		vehicles = FeedFreeBikeStatus
+	}
+	return []string{FeedVersions, FeedSystemInformation, FeedVehicleTypes, FeedStationInformation,
+		FeedStationStatus, vehicles}
+}
+
+const (
+	// staticTTL is how long, in seconds, clients can cache feeds which rarely change.
+	staticTTL = 3600
+	// statusTTL is how long, in seconds, clients can cache the station and vehicle status feeds.
+	statusTTL = 60
+)
+
+// ErrUnknownFeed is returned for a feed which isn't published in the version asked for.
+var ErrUnknownFeed = errors.New("unknown feed")
+
+// Config describes the system in the feed.
+type Config struct {
+	// BaseURL is where the feeds are served from. Each feed is at BaseURL/<version>/<feed>.json.
+	BaseURL  string
+	SystemID string
+	Name     string
+	Operator string
+	URL      string
+	Email    string
+	// Language is the IETF language tag of the feed's text, e.g. "en".
+	Language string
+	// Timezone is the system's time zone, which station opening hours are in.
+	Timezone *time.Location
+	// OpeningHours are the system's hours in OpenStreetMap opening_hours format. They are only
+	// published in 3.0, and default to "24/7".
+	OpeningHours string
+	// Key is the secret vehicle IDs are derived with.
+	Key []byte
+	// MaxRange is how far, in metres, a bike can go on a full battery.
+	MaxRange float64
+}
+
+// Stations fetches the stations to publish and their occupancy.
+type Stations interface {
+	GetStations() ([]station.Station, error)
+	GetHiddenStations(ctx context.Context, customerID uuid.UUID) (map[uuid.UUID]bool, error)
+	GetOccupancy(ctx context.Context, stationIDs []uuid.UUID, customerID uuid.UUID, now,
+		soon time.Time) (map[uuid.UUID]station.Occupancy, error)
+}
+
+// Bikes fetches the bikes to publish and their models.
+type Bikes interface {
+	ListModels(ctx context.Context) ([]bike.Model, error)
+	GetBikesWithStations(ctx context.Context, stationID *string, filter bike.ModelFilter) ([]bike.BikeWithStation, error)
+}
+
+// Rides fetches each bike's last ride, which its vehicle ID is derived from.
+type Rides interface {
+	GetLastRides(ctx context.Context) (map[uuid.UUID]ride.Ride, error)
+}
+
+// Availability decides which bikes are out on a ride or booked.
+type Availability interface {
+	CheckAll(ctx context.Context, bikes []bike.Bike, customerID uuid.UUID,
+		at time.Time) (map[uuid.UUID]availability.Result, error)
+}
+
+// Publisher builds the feeds from the current state of the system.
+type Publisher struct {
+	cfg    Config
+	sr     Stations
+	br     Bikes
+	rr     Rides
+	avail  Availability
+	curves bike.BatteryCurves
+}
+
+func New(cfg Config, sr Stations, br Bikes, rr Rides, avail Availability, curves bike.BatteryCurves) *Publisher {
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.Timezone == nil {
+		cfg.Timezone = time.UTC
+	}
+	if cfg.OpeningHours == "" {
+		cfg.OpeningHours = "24/7"
+	}
+	return &Publisher{cfg: cfg, sr: sr, br: br, rr: rr, avail: avail, curves: curves}
+}
+
+// Response is a feed in the specification's envelope.
+type Response struct {
+	LastUpdated timestamp `json:"last_updated"`
+	TTL         int       `json:"ttl"`
+	Version     Version   `json:"version"`
+	Data        any       `json:"data"`
+}
+
+// Feed builds the named feed as of now. ErrUnknownFeed is returned if the version doesn't publish it.
+func (p *Publisher) Feed(ctx context.Context, v Version, name string, now time.Time) (Response, error) {
+	var (
+		data any
+		ttl  = statusTTL
+		err  error
+	)
+	switch {
+	case name == FeedDiscovery:
+		data, ttl = p.discovery(v), staticTTL
+	case name == FeedVersions:
+		data, ttl = p.versions(), staticTTL
+	case name == FeedSystemInformation:
+		data, ttl = p.systemInformation(v), staticTTL
+	case name == FeedVehicleTypes:
+		data, err = p.vehicleTypes(ctx, v)
+		ttl = staticTTL
+	case name == FeedStationInformation:
+		data, err = p.stationInformation(v)
+		ttl = staticTTL
+	case name == FeedStationStatus:
+		data, err = p.stationStatus(ctx, v, now)
+	case name == FeedFreeBikeStatus && v == V2, name == FeedVehicleStatus && v == V3:
+		data, err = p.vehicleStatus(ctx, v, now)
+	default:
+		return Response{}, ErrUnknownFeed
+	}
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{LastUpdated: v.timestamp(now), TTL: ttl, Version: v, Data: data}, nil
+}
+
+func (p *Publisher) url(v Version, feed string) string {
+	return p.cfg.BaseURL + "/" + string(v) + "/" + feed + ".json"
+}
+
+type feedURL struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func (p *Publisher) discovery(v Version) any {
+	feeds := make([]feedURL, 0, len(v.Feeds()))
+	for _, f := range v.Feeds() {
+		feeds = append(feeds, feedURL{Name: f, URL: p.url(v, f)})
+	}
+	if v == V2 {
+		// 2.3 lists the feeds per language
+		return map[string]any{p.cfg.Language: map[string]any{"feeds": feeds}}
+	}
+	return map[string]any{"feeds": feeds}
+}
+
+type versionURL struct {
+	Version Version `json:"version"`
+	URL     string  `json:"url"`
+}
+
+func (p *Publisher) versions() any {
+	versions := make([]versionURL, 0, len(Versions))
+	for _, v := range Versions {
+		versions = append(versions, versionURL{Version: v, URL: p.url(v, FeedDiscovery)})
+	}
+	return map[string]any{"versions": versions}
+}
+
+type systemInformation struct {
+	SystemID string `json:"system_id"`
+	// Language is the 2.3 form of Languages
+	Language         string   `json:"language,omitempty"`
+	Languages        []string `json:"languages,omitempty"`
+	Name             text     `json:"name"`
+	Operator         *text    `json:"operator,omitempty"`
+	URL              string   `json:"url,omitempty"`
+	FeedContactEmail string   `json:"feed_contact_email,omitempty"`
+	Timezone         string   `json:"timezone"`
+	// OpeningHours is only in 3.0
+	OpeningHours string `json:"opening_hours,omitempty"`
+}
+
+func (p *Publisher) systemInformation(v Version) any {
+	info := systemInformation{
+		SystemID:         p.cfg.SystemID,
+		Name:             p.text(v, p.cfg.Name),
+		URL:              p.cfg.URL,
+		FeedContactEmail: p.cfg.Email,
+		Timezone:         p.cfg.Timezone.String(),
+	}
+	if v == V2 {
+		info.Language = p.cfg.Language
+	} else {
+		info.Languages = []string{p.cfg.Language}
+		info.OpeningHours = p.cfg.OpeningHours
+	}
+	if p.cfg.Operator != "" {
+		op := p.text(v, p.cfg.Operator)
+		info.Operator = &op
+	}
+	return info
+}
+
+// timestamp is a time in the form the version uses: POSIX seconds in 2.3 and RFC 3339 in 3.0.
+type timestamp struct {
+	t       time.Time
+	rfc3339 bool
+}
+
+func (v Version) timestamp(t time.Time) timestamp {
+	return timestamp{t: t, rfc3339: v != V2}
+}
+
+func (t timestamp) MarshalJSON() ([]byte, error) {
+	if t.rfc3339 {
+		return json.Marshal(t.t.UTC().Format(time.RFC3339))
+	}
+	return json.Marshal(t.t.Unix())
+}
+
+// text is human-readable text in the form the version uses: a plain string in 2.3 and a list of
+// translations in 3.0.
+type text struct {
+	s         string
+	language  string
+	localized bool
+}
+
+func (p *Publisher) text(v Version, s string) text {
+	return text{s: s, language: p.cfg.Language, localized: v != V2}
+}
+
+func (t text) MarshalJSON() ([]byte, error) {
+	if !t.localized {
+		return json.Marshal(t.s)
+	}
+	return json.Marshal([]map[string]string{{"text": t.s, "language": t.language}})
+}
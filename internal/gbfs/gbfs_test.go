@@ -0,0 +1,313 @@
+package gbfs
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+var testConfig = Config{
+	BaseURL:  "https://api.example.com/gbfs/",
+	SystemID: "bikeshare",
+	Name:     "Cargo Bike Share",
+	Operator: "Example Operator",
+	URL:      "https://example.com",
+	Email:    "gbfs@example.com",
+	Language: "en",
+	Key:      []byte("test key"),
+	MaxRange: 50000,
+}
+
+// checkFeed validates a feed against the schema in testdata/schemas for its version.
+func checkFeed(t *testing.T, v Version, name string, resp Response) {
+	t.Helper()
+
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	schema, err := c.Compile(filepath.Join("testdata", "schemas", "v"+string(v), name+".json"))
+	if err != nil {
+		t.Fatalf("compile %s %s schema: %v", v, name, err)
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal %s %s: %v", v, name, err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unmarshal %s %s: %v", v, name, err)
+	}
+	if err := schema.Validate(doc); err != nil {
+		t.Errorf("%s %s doesn't match the schema: %v\n%s", v, name, err, b)
+	}
+}
+
+func TestSystemFeedsMatchSchemas(t *testing.T) {
+	now := time.Now()
+	minimal := testConfig
+	minimal.Operator, minimal.URL, minimal.OpeningHours = "", "", ""
+
+	for _, cfg := range []Config{testConfig, minimal} {
+		p := New(cfg, nil, nil, nil, nil, nil)
+		for _, v := range Versions {
+			for _, name := range []string{FeedDiscovery, FeedVersions, FeedSystemInformation} {
+				resp, err := p.Feed(context.Background(), v, name, now)
+				if err != nil {
+					t.Fatalf("Feed(%s, %s) error = %v", v, name, err)
+				}
+				checkFeed(t, v, name, resp)
+			}
+		}
+	}
+}
+
+func TestFeedsMatchSchemas(t *testing.T) {
+	f := newTestFleet()
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, v := range Versions {
+		for _, name := range append([]string{FeedDiscovery}, v.Feeds()...) {
+			resp, err := f.p.Feed(ctx, v, name, now)
+			if err != nil {
+				t.Fatalf("Feed(%s, %s) error = %v", v, name, err)
+			}
+			checkFeed(t, v, name, resp)
+		}
+	}
+
+	// The vehicle status feeds have their version's name
+	if _, err := f.p.Feed(ctx, V2, FeedVehicleStatus, now); err != ErrUnknownFeed {
+		t.Errorf("Feed(2.3, vehicle_status) error = %v, want %v", err, ErrUnknownFeed)
+	}
+	if _, err := f.p.Feed(ctx, V3, FeedFreeBikeStatus, now); err != ErrUnknownFeed {
+		t.Errorf("Feed(3.0, free_bike_status) error = %v, want %v", err, ErrUnknownFeed)
+	}
+}
+
+func TestVehicleID(t *testing.T) {
+	key := []byte("test key")
+	bikeID := uuid.New()
+	firstRide := uuid.New()
+	secondRide := uuid.New()
+
+	id := VehicleID(key, bikeID, uuid.Nil)
+	if !regexp.MustCompile(`^[a-z2-7]{16}$`).MatchString(id) {
+		t.Errorf("VehicleID() = %q, want 16 lower case base32 characters", id)
+	}
+	if again := VehicleID(key, bikeID, uuid.Nil); again != id {
+		t.Errorf("VehicleID() = %q then %q, want it to be stable between rides", id, again)
+	}
+
+	tests := []struct {
+		name     string
+		key      []byte
+		bikeID   uuid.UUID
+		lastRide uuid.UUID
+	}{
+		{"after the first ride", key, bikeID, firstRide},
+		{"after the second ride", key, bikeID, secondRide},
+		{"another bike", key, uuid.New(), uuid.Nil},
+		{"another key", []byte("other key"), bikeID, uuid.Nil},
+	}
+	seen := map[string]string{id: "never ridden"}
+	for _, tt := range tests {
+		got := VehicleID(tt.key, tt.bikeID, tt.lastRide)
+		if other, ok := seen[got]; ok {
+			t.Errorf("VehicleID() %s = %q, the same as %s", tt.name, got, other)
+		}
+		seen[got] = tt.name
+	}
+}
+
+func TestVehicleIDChangesAfterRide(t *testing.T) {
+	f := newTestFleet()
+
+	before := f.publishedIDs(t)
+	id, ok := before[f.loose.Location]
+	if !ok {
+		t.Fatalf("bike %s isn't published", f.loose.Label)
+	}
+
+	f.riding[f.loose.ID] = true
+	if _, ok := f.publishedIDs(t)[f.loose.Location]; ok {
+		t.Errorf("bike %s is published while it is being ridden", f.loose.Label)
+	}
+	delete(f.riding, f.loose.ID)
+	f.lastRides[f.loose.ID] = ride.Ride{ID: uuid.New(), BikeID: f.loose.ID}
+
+	after := f.publishedIDs(t)
+	if after[f.loose.Location] == id {
+		t.Errorf("vehicle ID of %s is still %q after a ride", f.loose.Label, id)
+	}
+	if after[f.atStation.Location] != before[f.atStation.Location] {
+		t.Errorf("vehicle ID of %s changed without a ride", f.atStation.Label)
+	}
+}
+
+// fakeSystem holds the stations, bikes and rides the feeds are built from.
+type fakeSystem struct {
+	stations  []station.Station
+	models    []bike.Model
+	bikes     []bike.BikeWithStation
+	riding    map[uuid.UUID]bool
+	lastRides map[uuid.UUID]ride.Ride
+}
+
+func (s *fakeSystem) GetStations() ([]station.Station, error) {
+	return s.stations, nil
+}
+
+func (s *fakeSystem) GetHiddenStations(context.Context, uuid.UUID) (map[uuid.UUID]bool, error) {
+	hidden := make(map[uuid.UUID]bool)
+	for _, st := range s.stations {
+		if st.Type == station.Private {
+			hidden[st.ID] = true
+		}
+	}
+	return hidden, nil
+}
+
+func (s *fakeSystem) GetOccupancy(_ context.Context, stationIDs []uuid.UUID, _ uuid.UUID, _,
+	_ time.Time) (map[uuid.UUID]station.Occupancy, error) {
+	occupancy := make(map[uuid.UUID]station.Occupancy, len(stationIDs))
+	for _, id := range stationIDs {
+		o := station.Occupancy{StationID: id}
+		for _, b := range s.bikes {
+			switch {
+			case b.StationID == nil || *b.StationID != id:
+			case s.riding[b.ID]:
+				o.RidingBikes++
+			default:
+				o.AvailableBikes++
+			}
+		}
+		for _, st := range s.stations {
+			if st.ID == id && st.Capacity.Valid {
+				o.FreeSpaces = sql.NullInt32{Int32: st.Capacity.Int32 - int32(o.AvailableBikes), Valid: true}
+			}
+		}
+		occupancy[id] = o
+	}
+	return occupancy, nil
+}
+
+func (s *fakeSystem) ListModels(context.Context) ([]bike.Model, error) {
+	return s.models, nil
+}
+
+func (s *fakeSystem) GetBikesWithStations(context.Context, *string, bike.ModelFilter) ([]bike.BikeWithStation, error) {
+	return s.bikes, nil
+}
+
+func (s *fakeSystem) GetLastRides(context.Context) (map[uuid.UUID]ride.Ride, error) {
+	return s.lastRides, nil
+}
+
+func (s *fakeSystem) CheckAll(_ context.Context, bikes []bike.Bike, _ uuid.UUID,
+	_ time.Time) (map[uuid.UUID]availability.Result, error) {
+	results := make(map[uuid.UUID]availability.Result, len(bikes))
+	for _, b := range bikes {
+		if s.riding[b.ID] {
+			results[b.ID] = availability.Result{Reason: availability.ReasonInUse}
+		} else {
+			results[b.ID] = availability.Result{Available: true}
+		}
+	}
+	return results, nil
+}
+
+// testFleet is a system with a public and a private station, and bikes at them and away from them.
+type testFleet struct {
+	*fakeSystem
+	p *Publisher
+
+	// atStation is a bike of a model, with a battery reading, at the public station
+	atStation bike.Bike
+	// loose is a bike without a model away from the stations
+	loose bike.Bike
+}
+
+func newTestFleet() testFleet {
+	now := time.Now()
+
+	public := station.Station{ID: uuid.New(), Name: "Public", Address: "1 Main Street",
+		Location: geo.Point{Lat: 53.35, Lng: -6.26}, Type: station.Public,
+		Capacity: sql.NullInt32{Int32: 10, Valid: true}, ReturnRadius: 50}
+	unlimited := station.Station{ID: uuid.New(), Name: "Unlimited", Location: geo.Point{Lat: 53.36, Lng: -6.26},
+		Type: station.Public, ReturnRadius: 50}
+	private := station.Station{ID: uuid.New(), Name: "Private", Location: geo.Point{Lat: 53.34, Lng: -6.26},
+		Type: station.Private, ReturnRadius: 50}
+
+	image := "https://example.com/trike.png"
+	trike := bike.Model{ID: uuid.New(), Name: "Trike", ImageURL: &image, CargoType: bike.CargoTrike,
+		Capacity: sql.NullInt32{Int32: 100, Valid: true}}
+
+	f := testFleet{fakeSystem: &fakeSystem{
+		stations:  []station.Station{public, unlimited, private},
+		models:    []bike.Model{trike},
+		riding:    make(map[uuid.UUID]bool),
+		lastRides: make(map[uuid.UUID]ride.Ride),
+	}}
+	f.atStation = bike.Bike{ID: uuid.New(), Label: "CARGO-1", IMEI: "1", Location: public.Location,
+		StationID: &public.ID, ModelID: uuid.NullUUID{UUID: trike.ID, Valid: true}, State: bike.StateActive,
+		BatteryVoltage: sql.NullInt32{Int32: 380, Valid: true}, TelemetryUpdatedAt: sql.NullTime{Time: now, Valid: true}}
+	f.loose = bike.Bike{ID: uuid.New(), Label: "CARGO-2", IMEI: "2", Location: geo.Point{Lat: 53.40, Lng: -6.20},
+		State: bike.StateActive}
+	privateBike := bike.Bike{ID: uuid.New(), Label: "CARGO-3", IMEI: "3", Location: private.Location,
+		StationID: &private.ID, State: bike.StateActive}
+	f.bikes = []bike.BikeWithStation{
+		{Bike: f.atStation, StationName: public.Name},
+		{Bike: f.loose},
+		{Bike: privateBike, StationName: private.Name},
+	}
+
+	f.p = New(testConfig, f.fakeSystem, f.fakeSystem, f.fakeSystem, f.fakeSystem, bike.BatteryCurves{})
+	return f
+}
+
+// publishedIDs returns the vehicle IDs in the 3.0 vehicle status feed, keyed by the vehicle's
+// location, and checks the 2.3 feed publishes the same IDs.
+func (f testFleet) publishedIDs(t *testing.T) map[geo.Point]string {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+
+	v3, err := f.p.Feed(ctx, V3, FeedVehicleStatus, now)
+	if err != nil {
+		t.Fatalf("Feed(3.0, vehicle_status) error = %v", err)
+	}
+	v2, err := f.p.Feed(ctx, V2, FeedFreeBikeStatus, now)
+	if err != nil {
+		t.Fatalf("Feed(2.3, free_bike_status) error = %v", err)
+	}
+
+	ids := make(map[geo.Point]string)
+	for _, vh := range v3.Data.(map[string]any)["vehicles"].([]vehicle) {
+		ids[geo.Point{Lat: vh.Lat, Lng: vh.Lon}] = vh.VehicleID
+	}
+	bikes := v2.Data.(map[string]any)["bikes"].([]vehicle)
+	if len(bikes) != len(ids) {
+		t.Errorf("2.3 publishes %d bikes, 3.0 publishes %d", len(bikes), len(ids))
+	}
+	for _, vh := range bikes {
+		if id := ids[geo.Point{Lat: vh.Lat, Lng: vh.Lon}]; id != vh.BikeID {
+			t.Errorf("2.3 bike_id = %q, 3.0 vehicle_id = %q", vh.BikeID, id)
+		}
+	}
+	return ids
+}
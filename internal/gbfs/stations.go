@@ -0,0 +1,146 @@
+package gbfs
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+// publicStations fetches the stations which are published, which are the active public stations.
+func (p *Publisher) publicStations() ([]station.Station, error) {
+	stations, err := p.sr.GetStations()
+	if err != nil {
+		return nil, err
+	}
+
+	public := make([]station.Station, 0, len(stations))
+	for _, s := range stations {
+		if s.Type == station.Public {
+			public = append(public, s)
+		}
+	}
+	return public, nil
+}
+
+type stationInformation struct {
+	StationID string  `json:"station_id"`
+	Name      text    `json:"name"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	Address   string  `json:"address,omitempty"`
+	// IsVirtualStation is always true: bikes are returned anywhere within the station's return radius
+	// rather than to a dock
+	IsVirtualStation bool   `json:"is_virtual_station"`
+	Capacity         *int32 `json:"capacity,omitempty"`
+}
+
+func (p *Publisher) stationInformation(v Version) (any, error) {
+	stations, err := p.publicStations()
+	if err != nil {
+		return nil, err
+	}
+
+	info := make([]stationInformation, 0, len(stations))
+	for _, s := range stations {
+		si := stationInformation{
+			StationID:        s.ID.String(),
+			Name:             p.text(v, s.Name),
+			Lat:              s.Location.Lat,
+			Lon:              s.Location.Lng,
+			Address:          s.Address,
+			IsVirtualStation: true,
+		}
+		if s.Capacity.Valid {
+			si.Capacity = &s.Capacity.Int32
+		}
+		info = append(info, si)
+	}
+	return map[string]any{"stations": info}, nil
+}
+
+type vehicleTypeCount struct {
+	VehicleTypeID string `json:"vehicle_type_id"`
+	Count         int    `json:"count"`
+}
+
+type stationStatus struct {
+	StationID string `json:"station_id"`
+	// NumBikesAvailable is the 2.3 name of NumVehiclesAvailable
+	NumBikesAvailable     *int               `json:"num_bikes_available,omitempty"`
+	NumVehiclesAvailable  *int               `json:"num_vehicles_available,omitempty"`
+	VehicleTypesAvailable []vehicleTypeCount `json:"vehicle_types_available"`
+	// NumDocksAvailable is omitted for stations without a capacity
+	NumDocksAvailable *int32    `json:"num_docks_available,omitempty"`
+	IsInstalled       bool      `json:"is_installed"`
+	IsRenting         bool      `json:"is_renting"`
+	IsReturning       bool      `json:"is_returning"`
+	LastReported      timestamp `json:"last_reported"`
+}
+
+func (p *Publisher) stationStatus(ctx context.Context, v Version, now time.Time) (any, error) {
+	stations, err := p.publicStations()
+	if err != nil {
+		return nil, err
+	}
+
+	fleet, err := p.fleet(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[uuid.UUID]map[string]int)
+	for _, b := range fleet {
+		if b.StationID == nil || !b.avail.Available {
+			continue
+		}
+		if available[*b.StationID] == nil {
+			available[*b.StationID] = make(map[string]int)
+		}
+		available[*b.StationID][vehicleTypeID(b.Bike)]++
+	}
+
+	ids := make([]uuid.UUID, 0, len(stations))
+	for _, s := range stations {
+		ids = append(ids, s.ID)
+	}
+	occupancy, err := p.sr.GetOccupancy(ctx, ids, uuid.Nil, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	local := now.In(p.cfg.Timezone)
+	statuses := make([]stationStatus, 0, len(stations))
+	for _, s := range stations {
+		open := s.OpeningSchedule.OpenAt(local)
+		st := stationStatus{
+			StationID:             s.ID.String(),
+			VehicleTypesAvailable: make([]vehicleTypeCount, 0, len(available[s.ID])),
+			IsInstalled:           true,
+			IsRenting:             open,
+			IsReturning:           open,
+			LastReported:          v.timestamp(now),
+		}
+		var count int
+		for typeID, n := range available[s.ID] {
+			st.VehicleTypesAvailable = append(st.VehicleTypesAvailable,
+				vehicleTypeCount{VehicleTypeID: typeID, Count: n})
+			count += n
+		}
+		sort.Slice(st.VehicleTypesAvailable, func(i, j int) bool {
+			return st.VehicleTypesAvailable[i].VehicleTypeID < st.VehicleTypesAvailable[j].VehicleTypeID
+		})
+		if v == V2 {
+			st.NumBikesAvailable = &count
+		} else {
+			st.NumVehiclesAvailable = &count
+		}
+		if free := occupancy[s.ID].FreeSpaces; free.Valid {
+			st.NumDocksAvailable = &free.Int32
+		}
+		statuses = append(statuses, st)
+	}
+	return map[string]any{"stations": statuses}, nil
+}
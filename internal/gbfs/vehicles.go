@@ -0,0 +1,223 @@
+package gbfs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/availability"
+)
+
+// DefaultVehicleType is the vehicle type of bikes which don't have a model.
+const DefaultVehicleType = "cargo"
+
+const (
+	formFactorCargoBicycle   = "cargo_bicycle"
+	propulsionElectricAssist = "electric_assist"
+	// vehicleIDLength is the length of published vehicle IDs, which keeps 80 bits of the HMAC.
+	vehicleIDLength = 16
+)
+
+var vehicleIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// VehicleID derives the published ID of a bike from the bike and its last ride, so it changes after
+// every trip. lastRide is uuid.Nil for a bike which has never been ridden.
+func VehicleID(key []byte, bikeID, lastRide uuid.UUID) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(bikeID[:])
+	mac.Write(lastRide[:])
+	return strings.ToLower(vehicleIDEncoding.EncodeToString(mac.Sum(nil)))[:vehicleIDLength]
+}
+
+func vehicleTypeID(b bike.Bike) string {
+	if !b.ModelID.Valid {
+		return DefaultVehicleType
+	}
+	return b.ModelID.UUID.String()
+}
+
+type vehicleType struct {
+	VehicleTypeID  string  `json:"vehicle_type_id"`
+	FormFactor     string  `json:"form_factor"`
+	PropulsionType string  `json:"propulsion_type"`
+	MaxRangeMeters float64 `json:"max_range_meters"`
+	Name           text    `json:"name"`
+	WheelCount     int     `json:"wheel_count"`
+	// CargoLoadCapacity is the most the bike can carry in kg. It is only in 3.0
+	CargoLoadCapacity *int32 `json:"cargo_load_capacity,omitempty"`
+	// VehicleImage is only in 3.0
+	VehicleImage *string `json:"vehicle_image,omitempty"`
+}
+
+func (p *Publisher) vehicleTypes(ctx context.Context, v Version) (any, error) {
+	models, err := p.br.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]vehicleType, 0, len(models)+1)
+	types = append(types, vehicleType{
+		VehicleTypeID:  DefaultVehicleType,
+		FormFactor:     formFactorCargoBicycle,
+		PropulsionType: propulsionElectricAssist,
+		MaxRangeMeters: p.cfg.MaxRange,
+		Name:           p.text(v, "Cargo bike"),
+		WheelCount:     2,
+	})
+	for _, m := range models {
+		t := vehicleType{
+			VehicleTypeID:  m.ID.String(),
+			FormFactor:     formFactorCargoBicycle,
+			PropulsionType: propulsionElectricAssist,
+			MaxRangeMeters: p.cfg.MaxRange,
+			Name:           p.text(v, m.Name),
+			WheelCount:     2,
+		}
+		if m.CargoType == bike.CargoTrike {
+			t.WheelCount = 3
+		}
+		if v != V2 {
+			if m.Capacity.Valid {
+				t.CargoLoadCapacity = &m.Capacity.Int32
+			}
+			t.VehicleImage = m.ImageURL
+		}
+		types = append(types, t)
+	}
+	return map[string]any{"vehicle_types": types}, nil
+}
+
+// fleetBike is a published bike with its availability to any customer.
+type fleetBike struct {
+	bike.Bike
+	avail availability.Result
+}
+
+// rented reports whether the bike is out on a ride, in which case it isn't published.
+func (b fleetBike) rented() bool {
+	return b.avail.Reason == availability.ReasonInUse
+}
+
+// reserved reports whether the bike is booked now or is about to be.
+func (b fleetBike) reserved() bool {
+	return b.avail.Reason == availability.ReasonBooked || b.avail.Reason == availability.ReasonBookingSoon
+}
+
+// fleet fetches the active bikes which aren't at a private station, with their availability at now.
+func (p *Publisher) fleet(ctx context.Context, now time.Time) ([]fleetBike, error) {
+	bikes, err := p.br.GetBikesWithStations(ctx, nil, bike.ModelFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a customer every private station is hidden
+	private, err := p.sr.GetHiddenStations(ctx, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]bike.Bike, 0, len(bikes))
+	for _, b := range bikes {
+		if b.StationID != nil && private[*b.StationID] {
+			continue
+		}
+		plain = append(plain, b.Bike)
+	}
+
+	avail, err := p.avail.CheckAll(ctx, plain, uuid.Nil, now)
+	if err != nil {
+		return nil, err
+	}
+
+	fleet := make([]fleetBike, 0, len(plain))
+	for _, b := range plain {
+		fleet = append(fleet, fleetBike{Bike: b, avail: avail[b.ID]})
+	}
+	return fleet, nil
+}
+
+type vehicle struct {
+	// BikeID is the 2.3 name of VehicleID
+	BikeID             string    `json:"bike_id,omitempty"`
+	VehicleID          string    `json:"vehicle_id,omitempty"`
+	Lat                float64   `json:"lat"`
+	Lon                float64   `json:"lon"`
+	IsReserved         bool      `json:"is_reserved"`
+	IsDisabled         bool      `json:"is_disabled"`
+	VehicleTypeID      string    `json:"vehicle_type_id"`
+	LastReported       timestamp `json:"last_reported"`
+	CurrentRangeMeters float64   `json:"current_range_meters"`
+	// CurrentFuelPercent is the battery's state of charge between 0 and 1, if it is known
+	CurrentFuelPercent *float64 `json:"current_fuel_percent,omitempty"`
+	StationID          string   `json:"station_id,omitempty"`
+}
+
+// vehicleStatus lists the bikes which aren't out on a ride.
+func (p *Publisher) vehicleStatus(ctx context.Context, v Version, now time.Time) (any, error) {
+	fleet, err := p.fleet(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	lastRides, err := p.rr.GetLastRides(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	vehicles := make([]vehicle, 0, len(fleet))
+	for _, b := range fleet {
+		if b.rented() {
+			continue
+		}
+
+		vh := vehicle{
+			Lat:           b.Location.Lat,
+			Lon:           b.Location.Lng,
+			IsReserved:    b.reserved(),
+			VehicleTypeID: vehicleTypeID(b.Bike),
+			LastReported:  v.timestamp(lastReported(b.Bike, now)),
+		}
+		id := VehicleID(p.cfg.Key, b.ID, lastRides[b.ID].ID)
+		if v == V2 {
+			vh.BikeID = id
+		} else {
+			vh.VehicleID = id
+		}
+		// Bikes without a battery reading are published with no range rather than a guess
+		if b.BatteryVoltage.Valid {
+			charge := float64(p.curves.For(b.BatteryModel).Percentage(int(b.BatteryVoltage.Int32))) / 100
+			vh.CurrentFuelPercent = &charge
+			vh.CurrentRangeMeters = charge * p.cfg.MaxRange
+		}
+		if b.StationID != nil {
+			vh.StationID = b.StationID.String()
+		}
+		vehicles = append(vehicles, vh)
+	}
+
+	if v == V2 {
+		return map[string]any{"bikes": vehicles}, nil
+	}
+	return map[string]any{"vehicles": vehicles}, nil
+}
+
+// lastReported is when the bike's lock last reported in, or now if it never has.
+func lastReported(b bike.Bike, now time.Time) time.Time {
+	var last time.Time
+	if b.LocationUpdatedAt.Valid {
+		last = b.LocationUpdatedAt.Time
+	}
+	if b.TelemetryUpdatedAt.Valid && b.TelemetryUpdatedAt.Time.After(last) {
+		last = b.TelemetryUpdatedAt.Time
+	}
+	if last.IsZero() {
+		return now
+	}
+	return last
+}
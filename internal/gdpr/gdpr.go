@@ -0,0 +1,323 @@
+// Package gdpr collects the personal data we hold about a customer into an archive they can
+// download, for the GDPR right of access.
+//
+// Small archives are built while the customer waits. Larger ones are built in the background, stored
+// in blob storage and downloaded through a signed link which expires.
+package gdpr
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/booking"
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/issue"
+	"github.com/semanticallynull/bookingengine-backend/rating"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+	"github.com/semanticallynull/bookingengine-backend/station"
+	"github.com/semanticallynull/bookingengine-backend/track"
+)
+
+const (
+	// MaxSyncRecords is the most bookings and rides a customer can have for their archive to be built
+	// while they wait.
+	MaxSyncRecords = 200
+	// ExportTTL is how long an archive built in the background can be downloaded for.
+	ExportTTL = 7 * 24 * time.Hour
+
+	currency = "EUR"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrLinkExpired      = errors.New("link expired")
+)
+
+// Archive is everything we hold about a customer.
+//
+// Customers can't set notification preferences yet, and the notifications sent to them aren't kept,
+// so there are none to include.
+type Archive struct {
+	GeneratedAt time.Time    `json:"generatedAt"`
+	Customer    Customer     `json:"customer"`
+	Bookings    []Booking    `json:"bookings"`
+	Rides       []Ride       `json:"rides"`
+	Payments    Payments     `json:"payments"`
+	Memberships []Membership `json:"stationMemberships"`
+	Ratings     []Rating     `json:"ratings"`
+	Issues      []Issue      `json:"issues"`
+}
+
+type Customer struct {
+	ID        uuid.UUID `json:"id"`
+	Auth0ID   string    `json:"auth0Id"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// VerifiedEmail is the address Auth0 has verified, which private stations admit customers by
+	VerifiedEmail string `json:"verifiedEmail,omitempty"`
+}
+
+type Booking struct {
+	ID          uuid.UUID             `json:"id"`
+	BikeLabel   string                `json:"bikeLabel"`
+	StartTime   time.Time             `json:"startTime"`
+	EndTime     time.Time             `json:"endTime"`
+	Status      booking.BookingStatus `json:"status"`
+	CancelledAt *time.Time            `json:"cancelledAt,omitempty"`
+	CreatedAt   time.Time             `json:"createdAt"`
+}
+
+type Ride struct {
+	ID        uuid.UUID  `json:"id"`
+	BikeLabel string     `json:"bikeLabel"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	// BilledMinutes, UnlockFee and TimeCharge are set once the ride has ended. Amounts are in cents
+	BilledMinutes *int32          `json:"billedMinutes,omitempty"`
+	UnlockFee     *int32          `json:"unlockFee,omitempty"`
+	TimeCharge    *int32          `json:"timeCharge,omitempty"`
+	EndReason     *ride.EndReason `json:"endReason,omitempty"`
+	BookingID     *uuid.UUID      `json:"bookingId,omitempty"`
+	// Track is the positions the bike reported during the ride
+	Track []Position `json:"track"`
+}
+
+type Position struct {
+	RecordedAt time.Time `json:"recordedAt"`
+	Lat        float64   `json:"latitude"`
+	Lng        float64   `json:"longitude"`
+}
+
+// Payments are references to the customer's payment records, which are held by Stripe.
+type Payments struct {
+	StripeCustomerID string   `json:"stripeCustomerId,omitempty"`
+	Charges          []Charge `json:"charges"`
+}
+
+// Charge is a ride the customer was invoiced for.
+type Charge struct {
+	RideID          uuid.UUID `json:"rideId"`
+	StripeInvoiceID string    `json:"stripeInvoiceId,omitempty"`
+	ChargedAt       time.Time `json:"chargedAt"`
+	// Amount is in cents
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type Membership struct {
+	StationID   uuid.UUID `json:"stationId"`
+	StationName string    `json:"stationName"`
+	InviteCode  string    `json:"inviteCode,omitempty"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+type Rating struct {
+	RideID    uuid.UUID      `json:"rideId"`
+	Score     int            `json:"score"`
+	Tags      rating.TagList `json:"tags"`
+	Comment   string         `json:"comment,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type Issue struct {
+	ID          uuid.UUID      `json:"id"`
+	RideID      *uuid.UUID     `json:"rideId,omitempty"`
+	Category    issue.Category `json:"category"`
+	Description string         `json:"description"`
+	Status      issue.Status   `json:"status"`
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+// Collector gathers a customer's data from the repositories.
+type Collector struct {
+	cr   *customer.Repository
+	bkr  *booking.Repository
+	rr   *ride.Repository
+	tr   *track.Repository
+	sr   *station.Repository
+	ratr *rating.Repository
+	ir   *issue.Repository
+}
+
+func New(cr *customer.Repository, bkr *booking.Repository, rr *ride.Repository, tr *track.Repository,
+	sr *station.Repository, ratr *rating.Repository, ir *issue.Repository) *Collector {
+	return &Collector{cr: cr, bkr: bkr, rr: rr, tr: tr, sr: sr, ratr: ratr, ir: ir}
+}
+
+// Collect gathers everything we hold about the customer as of now.
+func (c *Collector) Collect(ctx context.Context, customerID uuid.UUID, now time.Time) (Archive, error) {
+	cust, err := c.cr.GetCustomer(ctx, customerID)
+	if err != nil {
+		return Archive{}, err
+	}
+	a := Archive{
+		GeneratedAt: now,
+		Customer: Customer{
+			ID:            cust.ID,
+			Auth0ID:       cust.Auth0ID,
+			Email:         cust.Email.String,
+			Name:          cust.Name.String,
+			CreatedAt:     cust.CreatedAt,
+			VerifiedEmail: cust.VerifiedEmail.String,
+		},
+		Payments: Payments{StripeCustomerID: cust.StripeID.String, Charges: []Charge{}},
+	}
+
+	bookings, err := c.bkr.GetByUserID(ctx, customerID, nil)
+	if err != nil {
+		return Archive{}, err
+	}
+	a.Bookings = make([]Booking, 0, len(bookings))
+	for _, b := range bookings {
+		bk := Booking{
+			ID:        b.ID,
+			BikeLabel: b.BikeLabel,
+			StartTime: b.StartTime,
+			EndTime:   b.EndTime,
+			Status:    b.StatusAt(now),
+			CreatedAt: b.CreatedAt,
+		}
+		if b.CancelledAt.Valid {
+			bk.CancelledAt = &b.CancelledAt.Time
+		}
+		a.Bookings = append(a.Bookings, bk)
+	}
+
+	if err := c.collectRides(ctx, customerID, &a); err != nil {
+		return Archive{}, err
+	}
+
+	memberships, err := c.sr.GetMemberships(ctx, customerID)
+	if err != nil {
+		return Archive{}, err
+	}
+	a.Memberships = make([]Membership, 0, len(memberships))
+	for _, m := range memberships {
+		a.Memberships = append(a.Memberships, Membership{
+			StationID:   m.StationID,
+			StationName: m.StationName.String,
+			InviteCode:  m.InviteCode.String,
+			JoinedAt:    m.CreatedAt,
+		})
+	}
+
+	ratings, err := c.ratr.GetByCustomer(ctx, customerID)
+	if err != nil {
+		return Archive{}, err
+	}
+	a.Ratings = make([]Rating, 0, len(ratings))
+	for _, r := range ratings {
+		a.Ratings = append(a.Ratings, Rating{
+			RideID:    r.RideID,
+			Score:     r.Score,
+			Tags:      r.Tags,
+			Comment:   r.Comment,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+
+	issues, err := c.ir.GetByCustomer(ctx, customerID)
+	if err != nil {
+		return Archive{}, err
+	}
+	a.Issues = make([]Issue, 0, len(issues))
+	for _, i := range issues {
+		is := Issue{
+			ID:          i.ID,
+			Category:    i.Category,
+			Description: i.Description,
+			Status:      i.Status,
+			CreatedAt:   i.CreatedAt,
+		}
+		if i.RideID.Valid {
+			is.RideID = &i.RideID.UUID
+		}
+		a.Issues = append(a.Issues, is)
+	}
+	return a, nil
+}
+
+// collectRides adds the customer's rides, with their tracks, and the charges for them to the archive.
+func (c *Collector) collectRides(ctx context.Context, customerID uuid.UUID, a *Archive) error {
+	rides, err := c.rr.GetHistory(ctx, customerID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uuid.UUID, 0, len(rides))
+	for _, r := range rides {
+		ids = append(ids, r.ID)
+	}
+	fixes, err := c.tr.GetFixesForRides(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	a.Rides = make([]Ride, 0, len(rides))
+	for _, r := range rides {
+		rd := Ride{
+			ID:        r.ID,
+			BikeLabel: r.BikeLabel,
+			StartedAt: r.StartedAt,
+			EndReason: r.EndReason,
+			Track:     make([]Position, 0, len(fixes[r.ID])),
+		}
+		if r.EndedAt.Valid {
+			rd.EndedAt = &r.EndedAt.Time
+		}
+		if r.BilledMinutes.Valid {
+			rd.BilledMinutes = &r.BilledMinutes.Int32
+		}
+		if r.UnlockFee.Valid {
+			rd.UnlockFee = &r.UnlockFee.Int32
+		}
+		if r.TimeCharge.Valid {
+			rd.TimeCharge = &r.TimeCharge.Int32
+		}
+		if r.BookingID.Valid {
+			rd.BookingID = &r.BookingID.UUID
+		}
+		for _, f := range fixes[r.ID] {
+			rd.Track = append(rd.Track, Position{RecordedAt: f.RecordedAt, Lat: f.Location.Lat, Lng: f.Location.Lng})
+		}
+		a.Rides = append(a.Rides, rd)
+
+		if r.ChargeCreatedAt.Valid {
+			a.Payments.Charges = append(a.Payments.Charges, Charge{
+				RideID:          r.ID,
+				StripeInvoiceID: r.StripeInvoiceID.String,
+				ChargedAt:       r.ChargeCreatedAt.Time,
+				Amount:          int(r.UnlockFee.Int32 + r.TimeCharge.Int32),
+				Currency:        currency,
+			})
+		}
+	}
+	return nil
+}
+
+// Sign signs a link to download the export which is valid until expires.
+func Sign(key []byte, exportID uuid.UUID, expires time.Time) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(exportID[:])
+	mac.Write([]byte(strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signed download link at now.
+func Verify(key []byte, exportID uuid.UUID, expires time.Time, signature string, now time.Time) error {
+	if !hmac.Equal([]byte(signature), []byte(Sign(key, exportID, expires))) {
+		return ErrInvalidSignature
+	}
+	if !now.Before(expires) {
+		return ErrLinkExpired
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package gdpr
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ContentType is the media type of archives.
+const ContentType = "application/zip"
+
+// WriteZip writes the archive as a zip file. data.json holds everything, and the bookings, rides,
+// ride tracks and charges are repeated as CSV files for spreadsheets.
+func (a Archive) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("data.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(a); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name   string
+		header []string
+		rows   [][]string
+	}{
+		{"bookings.csv", []string{"id", "bike", "start_time", "end_time", "status", "cancelled_at", "created_at"},
+			a.bookingRows()},
+		{"rides.csv", []string{"id", "bike", "started_at", "ended_at", "billed_minutes", "unlock_fee", "time_charge",
+			"end_reason", "booking_id"}, a.rideRows()},
+		{"tracks.csv", []string{"ride_id", "recorded_at", "latitude", "longitude"}, a.trackRows()},
+		{"charges.csv", []string{"ride_id", "stripe_invoice_id", "charged_at", "amount", "currency"}, a.chargeRows()},
+	}
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(f)
+		if err := cw.Write(file.header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(file.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (a Archive) bookingRows() [][]string {
+	rows := make([][]string, 0, len(a.Bookings))
+	for _, b := range a.Bookings {
+		rows = append(rows, []string{
+			b.ID.String(), b.BikeLabel, formatTime(&b.StartTime), formatTime(&b.EndTime), string(b.Status),
+			formatTime(b.CancelledAt), formatTime(&b.CreatedAt),
+		})
+	}
+	return rows
+}
+
+func (a Archive) rideRows() [][]string {
+	rows := make([][]string, 0, len(a.Rides))
+	for _, r := range a.Rides {
+		row := []string{
+			r.ID.String(), r.BikeLabel, formatTime(&r.StartedAt), formatTime(r.EndedAt),
+			formatInt32(r.BilledMinutes), formatInt32(r.UnlockFee), formatInt32(r.TimeCharge), "", "",
+		}
+		if r.EndReason != nil {
+			row[7] = string(*r.EndReason)
+		}
+		if r.BookingID != nil {
+			row[8] = r.BookingID.String()
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (a Archive) trackRows() [][]string {
+	var rows [][]string
+	for _, r := range a.Rides {
+		for _, p := range r.Track {
+			rows = append(rows, []string{
+				r.ID.String(), formatTime(&p.RecordedAt),
+				strconv.FormatFloat(p.Lat, 'f', 6, 64), strconv.FormatFloat(p.Lng, 'f', 6, 64),
+			})
+		}
+	}
+	return rows
+}
+
+func (a Archive) chargeRows() [][]string {
+	rows := make([][]string, 0, len(a.Payments.Charges))
+	for _, c := range a.Payments.Charges {
+		rows = append(rows, []string{
+			c.RideID.String(), c.StripeInvoiceID, formatTime(&c.ChargedAt), strconv.Itoa(c.Amount), c.Currency,
+		})
+	}
+	return rows
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatInt32(i *int32) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(int(*i))
+}
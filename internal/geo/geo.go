@@ -0,0 +1,153 @@
+// Package geo contains small geographic helpers shared by the location aware packages.
+package geo
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// earthRadius is the mean radius of the earth in metres.
+const earthRadius = 6371008.8
+
+// Point is a WGS84 coordinate. Points are stored in geography(Point, 4326) columns, see Value and
+// Scan.
+type Point struct {
+	Lat float64 `json:"latitude"`
+	Lng float64 `json:"longitude"`
+}
+
+// ErrInvalidPoint is returned when a coordinate can't be parsed or is out of range.
+var ErrInvalidPoint = errors.New("invalid coordinate")
+
+// ParsePoint parses a "lat,lng" pair, as used in query strings.
+func ParsePoint(s string) (Point, error) {
+	latStr, lngStr, ok := strings.Cut(s, ",")
+	if !ok {
+		return Point{}, ErrInvalidPoint
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil {
+		return Point{}, ErrInvalidPoint
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(lngStr), 64)
+	if err != nil {
+		return Point{}, ErrInvalidPoint
+	}
+	p := Point{Lat: lat, Lng: lng}
+	if !p.Valid() {
+		return Point{}, ErrInvalidPoint
+	}
+	return p, nil
+}
+
+// Valid reports whether the point is a real coordinate.
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// Distance returns the great-circle distance between a and b in metres using the haversine formula.
+func Distance(a, b Point) float64 {
+	lat1 := radians(a.Lat)
+	lat2 := radians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := radians(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Simplify reduces the number of points in a path using the Ramer-Douglas-Peucker algorithm.
+// Points closer than tolerance metres to the simplified line are dropped.
+func Simplify(points []Point, tolerance float64) []Point {
+	if len(points) < 3 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0] = true
+	keep[len(points)-1] = true
+	simplify(points, keep, 0, len(points)-1, tolerance)
+
+	simplified := make([]Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+func simplify(points []Point, keep []bool, first, last int, tolerance float64) {
+	if last-first < 2 {
+		return
+	}
+
+	maxDist := 0.0
+	index := first
+	for i := first + 1; i < last; i++ {
+		d := crossTrackDistance(points[i], points[first], points[last])
+		if d > maxDist {
+			maxDist = d
+			index = i
+		}
+	}
+
+	if maxDist > tolerance {
+		keep[index] = true
+		simplify(points, keep, first, index, tolerance)
+		simplify(points, keep, index, last, tolerance)
+	}
+}
+
+// crossTrackDistance approximates the distance in metres from p to the segment a-b using an
+// equirectangular projection, which is accurate enough over the length of a ride.
+func crossTrackDistance(p, a, b Point) float64 {
+	cosLat := math.Cos(radians(a.Lat))
+	project := func(q Point) (float64, float64) {
+		return radians(q.Lng-a.Lng) * cosLat * earthRadius, radians(q.Lat-a.Lat) * earthRadius
+	}
+
+	px, py := project(p)
+	bx, by := project(b)
+
+	segLen := bx*bx + by*by
+	if segLen == 0 {
+		return math.Hypot(px, py)
+	}
+
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/segLen))
+	return math.Hypot(px-t*bx, py-t*by)
+}
+
+// EncodePolyline encodes a path using the Google encoded polyline algorithm format with
+// five decimal places of precision.
+func EncodePolyline(points []Point) string {
+	var sb strings.Builder
+	var prevLat, prevLng int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * 1e5))
+		lng := int64(math.Round(p.Lng * 1e5))
+		encodeValue(&sb, lat-prevLat)
+		encodeValue(&sb, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return sb.String()
+}
+
+func encodeValue(sb *strings.Builder, v int64) {
+	v <<= 1
+	if v < 0 {
+		v = ^v
+	}
+	for v >= 0x20 {
+		sb.WriteByte(byte((0x20 | (v & 0x1f)) + 63))
+		v >>= 5
+	}
+	sb.WriteByte(byte(v + 63))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,67 @@
+package geo
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	a := Point{Lat: 53.3500, Lng: -6.2600}
+	b := Point{Lat: 53.3510, Lng: -6.2600}
+	c := Point{Lat: 53.3520, Lng: -6.2600}
+	corner := Point{Lat: 53.3520, Lng: -6.2580}
+	// wiggle is about 3m east of the line from a to c
+	wiggle := Point{Lat: 53.3510, Lng: -6.25995}
+
+	tests := []struct {
+		name   string
+		points []Point
+		want   []Point
+	}{
+		{"empty", nil, nil},
+		{"one point", []Point{a}, []Point{a}},
+		{"two points", []Point{a, c}, []Point{a, c}},
+		{"straight line", []Point{a, b, c}, []Point{a, c}},
+		{"wiggle within tolerance", []Point{a, wiggle, c}, []Point{a, c}},
+		{"corner", []Point{a, b, c, corner}, []Point{a, c, corner}},
+		{"back on itself", []Point{a, c, b}, []Point{a, c, b}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Simplify(tt.points, 10); !slices.Equal(got, tt.want) {
+				t.Errorf("Simplify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodePolyline(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+		want   string
+	}{
+		{"empty", nil, ""},
+		{"origin", []Point{{Lat: 0, Lng: 0}}, "??"},
+		{
+			// The example from Google's description of the format
+			name:   "example",
+			points: []Point{{Lat: 38.5, Lng: -120.2}, {Lat: 40.7, Lng: -120.95}, {Lat: 43.252, Lng: -126.453}},
+			want:   "_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+		},
+		{
+			name:   "rounded to five decimal places",
+			points: []Point{{Lat: 38.500004, Lng: -120.199996}},
+			want:   "_p~iF~ps|U",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodePolyline(tt.points); got != tt.want {
+				t.Errorf("EncodePolyline() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package geo
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// srid is the spatial reference of WGS84 coordinates, which geography columns use.
+const srid = 4326
+
+// ewkbSRIDFlag is set in the geometry type of EWKB values which include an SRID.
+const ewkbSRIDFlag = 0x20000000
+
+// wkbPoint is the WKB geometry type of a point.
+const wkbPoint = 1
+
+// Value stores the point in a geography(Point, 4326) column as EWKT. Note that WKT puts the
+// longitude first.
+func (p Point) Value() (driver.Value, error) {
+	return fmt.Sprintf("SRID=%d;POINT(%v %v)", srid, p.Lng, p.Lat), nil
+}
+
+// Scan reads a point from a geography column, which Postgres returns as hex encoded EWKB.
+func (p *Point) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into geo.Point", src)
+	}
+
+	// Values in the text format are hex encoded, those in the binary format are raw EWKB, which
+	// always starts with a byte order marker of 0 or 1
+	if len(b) > 0 && b[0] != 0 && b[0] != 1 {
+		decoded := make([]byte, hex.DecodedLen(len(b)))
+		if _, err := hex.Decode(decoded, b); err != nil {
+			return fmt.Errorf("invalid geography value: %w", err)
+		}
+		b = decoded
+	}
+
+	lng, lat, err := parseEWKBPoint(b)
+	if err != nil {
+		return err
+	}
+	*p = Point{Lat: lat, Lng: lng}
+	return nil
+}
+
+// parseEWKBPoint decodes the X (longitude) and Y (latitude) of an EWKB point.
+func parseEWKBPoint(b []byte) (float64, float64, error) {
+	if len(b) < 5 {
+		return 0, 0, errors.New("geography value too short")
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if b[0] == 1 {
+		order = binary.LittleEndian
+	}
+	typ := order.Uint32(b[1:5])
+	b = b[5:]
+
+	if typ&ewkbSRIDFlag != 0 {
+		if len(b) < 4 {
+			return 0, 0, errors.New("geography value too short")
+		}
+		b = b[4:]
+	}
+	if typ&0xff != wkbPoint {
+		return 0, 0, fmt.Errorf("geography value is not a point (type %d)", typ&0xff)
+	}
+	if len(b) < 16 {
+		return 0, 0, errors.New("geography value too short")
+	}
+
+	x := math.Float64frombits(order.Uint64(b[0:8]))
+	y := math.Float64frombits(order.Uint64(b[8:16]))
+	return x, y, nil
+}
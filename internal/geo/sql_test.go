@@ -0,0 +1,175 @@
+package geo
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// byteOrder is implemented by binary.LittleEndian and binary.BigEndian.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// ewkb encodes a geometry of type typ with the coordinates x and y, as Postgres does.
+func ewkb(order byteOrder, withSRID bool, typ uint32, x, y float64) []byte {
+	b := []byte{0}
+	if order == binary.LittleEndian {
+		b[0] = 1
+	}
+	if withSRID {
+		typ |= ewkbSRIDFlag
+	}
+	b = order.AppendUint32(b, typ)
+	if withSRID {
+		b = order.AppendUint32(b, srid)
+	}
+	b = order.AppendUint64(b, math.Float64bits(x))
+	b = order.AppendUint64(b, math.Float64bits(y))
+	return b
+}
+
+func TestPointValue(t *testing.T) {
+	tests := []struct {
+		p    Point
+		want string
+	}{
+		{Point{Lat: 53.3498, Lng: -6.2603}, "SRID=4326;POINT(-6.2603 53.3498)"},
+		{Point{Lat: 0, Lng: 0}, "SRID=4326;POINT(0 0)"},
+		{Point{Lat: -90, Lng: 180}, "SRID=4326;POINT(180 -90)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			v, err := tt.p.Value()
+			if err != nil {
+				t.Fatalf("Value() error = %v", err)
+			}
+			if v != tt.want {
+				t.Errorf("Value() = %v, want %v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointRoundTrip(t *testing.T) {
+	points := []Point{
+		{Lat: 53.3498, Lng: -6.2603},
+		{Lat: -33.8688, Lng: 151.2093},
+		{Lat: 0, Lng: 0},
+	}
+	encodings := []struct {
+		name     string
+		order    byteOrder
+		withSRID bool
+		hex      bool
+	}{
+		{"little endian", binary.LittleEndian, false, false},
+		{"little endian with SRID", binary.LittleEndian, true, false},
+		{"big endian", binary.BigEndian, false, false},
+		{"big endian with SRID", binary.BigEndian, true, false},
+		{"hex little endian", binary.LittleEndian, false, true},
+		{"hex little endian with SRID", binary.LittleEndian, true, true},
+		{"hex big endian", binary.BigEndian, false, true},
+		{"hex big endian with SRID", binary.BigEndian, true, true},
+	}
+
+	for _, p := range points {
+		// Postgres parses the EWKT which Value stores and returns it as EWKB
+		v, err := p.Value()
+		if err != nil {
+			t.Fatalf("Value() error = %v", err)
+		}
+		var x, y float64
+		if _, err := fmt.Sscanf(v.(string), "SRID=4326;POINT(%g %g)", &x, &y); err != nil {
+			t.Fatalf("Value() = %v, not EWKT: %v", v, err)
+		}
+
+		for _, e := range encodings {
+			t.Run(fmt.Sprintf("%s/%v", e.name, p), func(t *testing.T) {
+				b := ewkb(e.order, e.withSRID, wkbPoint, x, y)
+
+				var src any = b
+				if e.hex {
+					src = strings.ToUpper(hex.EncodeToString(b))
+				}
+
+				var got Point
+				if err := got.Scan(src); err != nil {
+					t.Fatalf("Scan() error = %v", err)
+				}
+				if got != p {
+					t.Errorf("Scan() = %v, want %v", got, p)
+				}
+			})
+		}
+	}
+}
+
+func TestPointScanInvalid(t *testing.T) {
+	point := ewkb(binary.LittleEndian, true, wkbPoint, -6.2603, 53.3498)
+	lineString := ewkb(binary.LittleEndian, true, 2, -6.2603, 53.3498)
+
+	tests := []struct {
+		name string
+		src  any
+	}{
+		{"nil", nil},
+		{"wrong type", 42},
+		{"empty", []byte{}},
+		{"byte order only", []byte{1}},
+		{"no SRID", point[:7]},
+		{"no coordinates", point[:9]},
+		{"one coordinate", point[:17]},
+		{"short hex", hex.EncodeToString(point[:17])},
+		{"not a point", lineString},
+		{"not a point, hex", hex.EncodeToString(lineString)},
+		{"invalid hex", "0101zz"},
+		{"odd length hex", hex.EncodeToString(point)[1:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Point{Lat: 1, Lng: 2}
+			if err := p.Scan(tt.src); err == nil {
+				t.Errorf("Scan(%v) = nil, want an error", tt.src)
+			}
+			if p != (Point{Lat: 1, Lng: 2}) {
+				t.Errorf("Scan(%v) changed the point to %v", tt.src, p)
+			}
+		})
+	}
+}
+
+func TestParseEWKBPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       []byte
+		x, y    float64
+		wantErr bool
+	}{
+		{"little endian", ewkb(binary.LittleEndian, false, wkbPoint, 1.5, -2.5), 1.5, -2.5, false},
+		{"big endian", ewkb(binary.BigEndian, false, wkbPoint, 1.5, -2.5), 1.5, -2.5, false},
+		{"little endian with SRID", ewkb(binary.LittleEndian, true, wkbPoint, 1.5, -2.5), 1.5, -2.5, false},
+		{"big endian with SRID", ewkb(binary.BigEndian, true, wkbPoint, 1.5, -2.5), 1.5, -2.5, false},
+		{"polygon", ewkb(binary.LittleEndian, false, 3, 1.5, -2.5), 0, 0, true},
+		{"too short", []byte{0, 0, 0, 0}, 0, 0, true},
+		{"truncated", ewkb(binary.BigEndian, false, wkbPoint, 1.5, -2.5)[:20], 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y, err := parseEWKBPoint(tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEWKBPoint() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if x != tt.x || y != tt.y {
+				t.Errorf("parseEWKBPoint() = %v, %v, want %v, %v", x, y, tt.x, tt.y)
+			}
+		})
+	}
+}
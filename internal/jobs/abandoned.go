@@ -0,0 +1,105 @@
+// Package jobs contains background jobs which run alongside the API server.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/billing"
+	"github.com/semanticallynull/bookingengine-backend/internal/notify"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+)
+
+// AbandonedRides closes rides which customers have forgotten to end.
+type AbandonedRides struct {
+	rr       *ride.Repository
+	cr       *customer.Repository
+	biller   *billing.Biller
+	notifier notify.Notifier
+	policy   ride.AbandonPolicy
+	logger   *slog.Logger
+}
+
+func NewAbandonedRides(rr *ride.Repository, cr *customer.Repository, biller *billing.Biller, notifier notify.Notifier,
+	policy ride.AbandonPolicy, logger *slog.Logger) *AbandonedRides {
+	return &AbandonedRides{
+		rr:       rr,
+		cr:       cr,
+		biller:   biller,
+		notifier: notifier,
+		policy:   policy,
+		logger:   logger,
+	}
+}
+
+// Run checks for abandoned rides every interval until ctx is cancelled.
+func (j *AbandonedRides) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.RunOnce(ctx); err != nil {
+			j.logger.ErrorContext(ctx, "failed to close abandoned rides", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce closes every ride which is currently abandoned.
+func (j *AbandonedRides) RunOnce(ctx context.Context) error {
+	rides, err := j.rr.FindAbandoned(ctx, j.policy)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range rides {
+		if err := j.close(ctx, a); err != nil {
+			j.logger.ErrorContext(ctx, "failed to close abandoned ride", "rideId", a.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (j *AbandonedRides) close(ctx context.Context, a ride.Abandoned) error {
+	summary, err := j.rr.CloseAbandoned(ctx, a, j.policy)
+	if errors.Is(err, ride.ErrAlreadyEnded) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logger := j.logger.With("rideId", summary.RideID, "reason", summary.EndReason)
+	logger.InfoContext(ctx, "closed abandoned ride")
+
+	cust, err := j.cr.GetCustomer(ctx, summary.CustomerID)
+	if err != nil {
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+	if err := j.biller.ChargeRide(ctx, cust.StripeID.String, summary); err != nil {
+		logger.ErrorContext(ctx, "failed to charge abandoned ride", "error", err)
+	}
+
+	body := fmt.Sprintf("Your ride on bike %s, started at %s, was ended automatically at %s. "+
+		"You have been charged for %d minutes.", summary.BikeLabel, summary.StartedAt.Format(time.Kitchen),
+		summary.EndedAt.Format(time.Kitchen), summary.BilledMinutes)
+	if err := j.notifier.NotifyCustomer(ctx, summary.CustomerID, "Your ride was ended", body); err != nil {
+		logger.WarnContext(ctx, "failed to notify customer", "error", err)
+	}
+
+	body = fmt.Sprintf("Ride %s on bike %s was closed automatically (%s). Please check the bike.",
+		summary.RideID, summary.BikeLabel, summary.EndReason)
+	if err := j.notifier.NotifyOps(ctx, "Abandoned ride closed", body); err != nil {
+		logger.WarnContext(ctx, "failed to notify ops", "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/billing"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+)
+
+// chargeRetryDelay is how long after a ride ends it is left to the request which ended it to charge.
+const chargeRetryDelay = 10 * time.Minute
+
+// UnchargedRides charges rides whose charge failed when they ended.
+type UnchargedRides struct {
+	rr     *ride.Repository
+	cr     *customer.Repository
+	biller *billing.Biller
+	logger *slog.Logger
+}
+
+func NewUnchargedRides(rr *ride.Repository, cr *customer.Repository, biller *billing.Biller,
+	logger *slog.Logger) *UnchargedRides {
+	return &UnchargedRides{
+		rr:     rr,
+		cr:     cr,
+		biller: biller,
+		logger: logger,
+	}
+}
+
+// Run retries uncharged rides every interval until ctx is cancelled.
+func (j *UnchargedRides) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.RunOnce(ctx); err != nil {
+			j.logger.ErrorContext(ctx, "failed to charge uncharged rides", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce tries again to charge every ride which should have been charged by now.
+func (j *UnchargedRides) RunOnce(ctx context.Context) error {
+	rides, err := j.rr.GetUncharged(ctx, time.Now().Add(-chargeRetryDelay))
+	if err != nil {
+		return err
+	}
+
+	for _, s := range rides {
+		if err := j.charge(ctx, s); err != nil {
+			j.logger.ErrorContext(ctx, "failed to charge ride", "rideId", s.RideID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (j *UnchargedRides) charge(ctx context.Context, s ride.Summary) error {
+	cust, err := j.cr.GetCustomer(ctx, s.CustomerID)
+	if err != nil {
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+	return j.biller.ChargeRide(ctx, cust.StripeID.String, s)
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/blob"
+	"github.com/semanticallynull/bookingengine-backend/internal/gdpr"
+)
+
+// CustomerExports generates the exports of their personal data customers have asked for, and deletes
+// them once they expire.
+type CustomerExports struct {
+	cr        *customer.Repository
+	collector *gdpr.Collector
+	blobs     blob.Store
+	logger    *slog.Logger
+}
+
+func NewCustomerExports(cr *customer.Repository, collector *gdpr.Collector, blobs blob.Store,
+	logger *slog.Logger) *CustomerExports {
+	return &CustomerExports{
+		cr:        cr,
+		collector: collector,
+		blobs:     blobs,
+		logger:    logger,
+	}
+}
+
+// Run processes exports every interval until ctx is cancelled.
+func (j *CustomerExports) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.RunOnce(ctx); err != nil {
+			j.logger.ErrorContext(ctx, "failed to process customer exports", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce generates every pending export and deletes every expired one.
+func (j *CustomerExports) RunOnce(ctx context.Context) error {
+	pending, err := j.cr.GetPendingExports(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range pending {
+		if err := j.generate(ctx, e); err != nil {
+			j.logger.ErrorContext(ctx, "failed to generate customer export", "exportId", e.ID, "error", err)
+			if err := j.cr.FailExport(ctx, e.ID, err.Error()); err != nil {
+				j.logger.ErrorContext(ctx, "failed to record failed export", "exportId", e.ID, "error", err)
+			}
+		}
+	}
+
+	expired, err := j.cr.GetExpiredExports(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, e := range expired {
+		if err := j.expire(ctx, e); err != nil {
+			j.logger.ErrorContext(ctx, "failed to expire customer export", "exportId", e.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (j *CustomerExports) generate(ctx context.Context, e customer.Export) error {
+	now := time.Now()
+	archive, err := j.collector.Collect(ctx, e.CustomerID, now)
+	if err != nil {
+		return fmt.Errorf("failed to collect data: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := archive.WriteZip(&buf); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	key := fmt.Sprintf("exports/%s.zip", e.ID)
+	if err := j.blobs.Put(ctx, key, &buf); err != nil {
+		return fmt.Errorf("failed to store archive: %w", err)
+	}
+	if err := j.cr.CompleteExport(ctx, e.ID, key, now.Add(gdpr.ExportTTL)); err != nil {
+		return err
+	}
+
+	j.logger.InfoContext(ctx, "generated customer export", "exportId", e.ID)
+	return nil
+}
+
+func (j *CustomerExports) expire(ctx context.Context, e customer.Export) error {
+	if e.BlobKey.Valid {
+		if err := j.blobs.Delete(ctx, e.BlobKey.String); err != nil {
+			return err
+		}
+	}
+	return j.cr.ExpireExport(ctx, e.ID)
+}
@@ -0,0 +1,84 @@
+// Package label validates bike labels and renders them as printable stickers.
+package label
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidFormat is returned when a label doesn't have the configured prefix and number of digits.
+	ErrInvalidFormat = errors.New("label does not match the label format")
+	// ErrCheckDigit is returned when a label's check digit is wrong, which usually means it was mistyped.
+	ErrCheckDigit = errors.New("label check digit is wrong")
+)
+
+// Format describes the labels printed on bikes: a prefix followed by a number of Digits and a Luhn
+// check digit, e.g. "CARGO-00422" for the prefix "CARGO-", 4 digits and the number 42. A Format
+// with no digits accepts any label.
+type Format struct {
+	Prefix string
+	Digits int
+}
+
+// Enabled reports whether labels are checked against the format.
+func (f Format) Enabled() bool {
+	return f.Digits > 0
+}
+
+// Validate checks that a label matches the format and that its check digit is right.
+func (f Format) Validate(label string) error {
+	if !f.Enabled() {
+		return nil
+	}
+
+	digits, ok := strings.CutPrefix(label, f.Prefix)
+	if !ok || len(digits) != f.Digits+1 || !allDigits(digits) {
+		return ErrInvalidFormat
+	}
+	if CheckDigit(digits[:f.Digits]) != digits[f.Digits] {
+		return ErrCheckDigit
+	}
+	return nil
+}
+
+// Label builds the label for a bike number, including its check digit.
+func (f Format) Label(number int) (string, error) {
+	if !f.Enabled() {
+		return "", errors.New("no label format configured")
+	}
+	digits := fmt.Sprintf("%0*d", f.Digits, number)
+	if number < 0 || len(digits) != f.Digits {
+		return "", fmt.Errorf("bike number %d does not fit in %d digits", number, f.Digits)
+	}
+	return f.Prefix + digits + string(CheckDigit(digits)), nil
+}
+
+// CheckDigit calculates the Luhn check digit for a string of digits. The Luhn algorithm catches
+// every single digit mistake and most transpositions of adjacent digits.
+func CheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d, _ := strconv.Atoi(digits[i : i+1])
+		// Every other digit is doubled, starting from the one next to the check digit
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package label
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   byte
+	}{
+		{"", '0'},
+		{"0", '0'},
+		{"0000", '0'},
+		{"0042", '2'},
+		{"0001", '8'},
+		{"7992739871", '3'},
+		{"123456789", '7'},
+		{"9", '1'},
+		{"5", '9'},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.digits, func(t *testing.T) {
+			if got := CheckDigit(tt.digits); got != tt.want {
+				t.Errorf("CheckDigit(%q) = %q, want %q", tt.digits, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDigitCatchesMistakes(t *testing.T) {
+	const digits = "0042"
+	check := CheckDigit(digits)
+
+	for i := range len(digits) {
+		for d := byte('0'); d <= '9'; d++ {
+			if d == digits[i] {
+				continue
+			}
+			typo := digits[:i] + string(d) + digits[i+1:]
+			if CheckDigit(typo) == check {
+				t.Errorf("CheckDigit(%q) = CheckDigit(%q), the mistake isn't caught", typo, digits)
+			}
+		}
+	}
+}
+
+func TestFormatValidate(t *testing.T) {
+	f := Format{Prefix: "CARGO-", Digits: 4}
+
+	tests := []struct {
+		label string
+		want  error
+	}{
+		{"CARGO-00422", nil},
+		{"CARGO-00423", ErrCheckDigit},
+		{"CARGO-0042", ErrInvalidFormat},
+		{"CARGO-004222", ErrInvalidFormat},
+		{"BIKE-00422", ErrInvalidFormat},
+		{"CARGO-0a422", ErrInvalidFormat},
+		{"", ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			if err := f.Validate(tt.label); !errors.Is(err, tt.want) {
+				t.Errorf("Validate(%q) = %v, want %v", tt.label, err, tt.want)
+			}
+		})
+	}
+
+	if err := (Format{}).Validate("anything"); err != nil {
+		t.Errorf("Validate with no format = %v, want nil", err)
+	}
+}
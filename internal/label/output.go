@@ -0,0 +1,150 @@
+package label
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// pixelsPerMM is the resolution of PNG output, about 300dpi.
+const pixelsPerMM = 12
+
+// pointsPerMM converts millimetres to PDF points.
+const pointsPerMM = 72 / 25.4
+
+// courierWidth is the advance of every Courier glyph as a fraction of the font size.
+const courierWidth = 0.6
+
+func writeSVG(w io.Writer, p page) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%gmm" height="%gmm" viewBox="0 0 %g %g">`,
+		p.width, p.height, p.width, p.height)
+	fmt.Fprintf(bw, `<rect width="%g" height="%g" fill="#fff"/>`, p.width, p.height)
+	for _, r := range p.rects {
+		fmt.Fprintf(bw, `<rect x="%.3f" y="%.3f" width="%.3f" height="%.3f"/>`, r.x, r.y, r.w, r.h)
+	}
+	for _, t := range p.texts {
+		fmt.Fprintf(bw, `<text x="%.3f" y="%.3f" font-size="%g" font-family="monospace" text-anchor="middle">`,
+			t.x, t.y, t.size)
+		if err := xml.EscapeText(bw, []byte(t.s)); err != nil {
+			return err
+		}
+		bw.WriteString(`</text>`)
+	}
+	bw.WriteString(`</svg>`)
+	return bw.Flush()
+}
+
+func writePNG(w io.Writer, p page) error {
+	img := image.NewGray(image.Rect(0, 0, px(p.width), px(p.height)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for _, r := range p.rects {
+		draw.Draw(img, image.Rect(px(r.x), px(r.y), px(r.x+r.w), px(r.y+r.h)), image.Black, image.Point{}, draw.Src)
+	}
+
+	face := basicfont.Face7x13
+	for _, t := range p.texts {
+		// Draw the text at the font's native size, then scale it up so its ascent matches the size
+		line := image.NewGray(image.Rect(0, 0, face.Advance*len(t.s), face.Height))
+		draw.Draw(line, line.Bounds(), image.White, image.Point{}, draw.Src)
+		d := font.Drawer{Dst: line, Src: image.Black, Face: face, Dot: fixed.P(0, face.Ascent)}
+		d.DrawString(t.s)
+
+		scale := t.size * pixelsPerMM / float64(face.Ascent)
+		width := int(math.Round(float64(line.Bounds().Dx()) * scale))
+		height := int(math.Round(float64(line.Bounds().Dy()) * scale))
+		left := px(t.x) - width/2
+		top := px(t.y) - int(math.Round(float64(face.Ascent)*scale))
+		draw.NearestNeighbor.Scale(img, image.Rect(left, top, left+width, top+height), line, line.Bounds(),
+			draw.Src, nil)
+	}
+
+	return png.Encode(w, img)
+}
+
+func px(mm float64) int {
+	return int(math.Round(mm * pixelsPerMM))
+}
+
+// writePDF writes a minimal PDF with one page per laid out page, drawing the barcodes as vector
+// rectangles and the text in Courier, which every PDF reader has built in.
+func writePDF(w io.Writer, pages []page) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 to 3 are the catalog, page tree and font. Each page is followed by its content.
+	kids := make([]byte, 0, len(pages)*8)
+	for i := range pages {
+		kids = fmt.Appendf(kids, "%d 0 R ", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+
+	for i, p := range pages {
+		width, height := p.width*pointsPerMM, p.height*pointsPerMM
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", width, height, 5+2*i))
+
+		var content bytes.Buffer
+		content.WriteString("0 g\n")
+		for _, r := range p.rects {
+			fmt.Fprintf(&content, "%.3f %.3f %.3f %.3f re\n",
+				r.x*pointsPerMM, height-(r.y+r.h)*pointsPerMM, r.w*pointsPerMM, r.h*pointsPerMM)
+		}
+		content.WriteString("f\n")
+		for _, t := range p.texts {
+			size := t.size * pointsPerMM
+			x := t.x*pointsPerMM - courierWidth*size*float64(len(t.s))/2
+			fmt.Fprintf(&content, "BT /F1 %.2f Tf %.3f %.3f Td (%s) Tj ET\n", size, x, height-t.y*pointsPerMM,
+				pdfString(t.s))
+		}
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// pdfString escapes the characters which are special inside a PDF string literal.
+func pdfString(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			if r < 32 || r > 126 {
+				b.WriteByte('?')
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
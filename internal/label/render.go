@@ -0,0 +1,208 @@
+package label
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+)
+
+// OutputFormat is a file format stickers can be rendered in.
+type OutputFormat string
+
+const (
+	PNG OutputFormat = "png"
+	SVG OutputFormat = "svg"
+	PDF OutputFormat = "pdf"
+)
+
+// ContentType is the MIME type of the output format.
+func (f OutputFormat) ContentType() string {
+	switch f {
+	case PNG:
+		return "image/png"
+	case SVG:
+		return "image/svg+xml"
+	case PDF:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Valid reports whether f is a supported output format.
+func (f OutputFormat) Valid() bool {
+	return f == PNG || f == SVG || f == PDF
+}
+
+// Sticker is a label to print for a bike. The QR code encodes Link, the Code-128 barcode and the
+// text encode Label.
+type Sticker struct {
+	Label string
+	Link  string
+}
+
+// Sticker dimensions, in millimetres.
+const (
+	stickerWidth  = 50.0
+	stickerHeight = 70.0
+
+	qrX    = 7.0
+	qrY    = 5.0
+	qrSize = 36.0
+
+	barcodeX      = 5.0
+	barcodeY      = 45.0
+	barcodeWidth  = 40.0
+	barcodeHeight = 12.0
+
+	textY    = 65.0
+	textSize = 5.0
+)
+
+// Sheet dimensions, in millimetres. Sheets are A4 with sheetColumns by sheetRows stickers.
+const (
+	sheetWidth   = 210.0
+	sheetHeight  = 297.0
+	sheetColumns = 3
+	sheetRows    = 4
+
+	// SheetSize is the number of stickers on a sheet.
+	SheetSize = sheetColumns * sheetRows
+)
+
+// ErrTooManyStickers is returned for more stickers than fit on the single page of a PNG or SVG.
+var ErrTooManyStickers = errors.New("too many stickers for one page")
+
+// rect is a filled black rectangle, in millimetres from the top left of the page.
+type rect struct {
+	x, y, w, h float64
+}
+
+// text is a line of text centred on x with its baseline at y, in millimetres.
+type text struct {
+	x, y, size float64
+	s          string
+}
+
+// page is a laid out page of stickers.
+type page struct {
+	width, height float64
+	rects         []rect
+	texts         []text
+}
+
+// Render writes stickers in the output format. A single sticker is rendered on its own, several
+// stickers are laid out on A4 sheets. PNG and SVG output only has one page, so ErrTooManyStickers
+// is returned for more than a sheet of stickers.
+func Render(w io.Writer, stickers []Sticker, format OutputFormat) error {
+	if len(stickers) == 0 {
+		return fmt.Errorf("no stickers to render")
+	}
+	if format != PDF && len(stickers) > SheetSize {
+		return ErrTooManyStickers
+	}
+
+	var pages []page
+	var err error
+	if len(stickers) == 1 {
+		pages, err = layoutSingle(stickers[0])
+	} else {
+		pages, err = layoutSheets(stickers)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case PNG:
+		return writePNG(w, pages[0])
+	case SVG:
+		return writeSVG(w, pages[0])
+	case PDF:
+		return writePDF(w, pages)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+func layoutSingle(s Sticker) ([]page, error) {
+	p := page{width: stickerWidth, height: stickerHeight}
+	if err := p.addSticker(s, 0, 0); err != nil {
+		return nil, err
+	}
+	return []page{p}, nil
+}
+
+// layoutSheets lays stickers out in a grid on as many sheets as they need.
+func layoutSheets(stickers []Sticker) ([]page, error) {
+	marginX := (sheetWidth - sheetColumns*stickerWidth) / 2
+	marginY := (sheetHeight - sheetRows*stickerHeight) / 2
+
+	var pages []page
+	for i, s := range stickers {
+		if i%SheetSize == 0 {
+			pages = append(pages, page{width: sheetWidth, height: sheetHeight})
+		}
+		p := &pages[len(pages)-1]
+		n := i % SheetSize
+		x := marginX + float64(n%sheetColumns)*stickerWidth
+		y := marginY + float64(n/sheetColumns)*stickerHeight
+		if err := p.addSticker(s, x, y); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+// addSticker draws a sticker with its top left corner at x, y.
+func (p *page) addSticker(s Sticker, x, y float64) error {
+	qrCode, err := qr.Encode(s.Link, qr.M, qr.Auto)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code for %s: %w", s.Label, err)
+	}
+	modules := qrCode.Bounds().Dx()
+	size := qrSize / float64(modules)
+	for row := 0; row < modules; row++ {
+		p.addRuns(qrCode, row, x+qrX, y+qrY+float64(row)*size, size, size)
+	}
+
+	bars, err := code128.Encode(s.Label)
+	if err != nil {
+		return fmt.Errorf("failed to encode barcode for %s: %w", s.Label, err)
+	}
+	p.addRuns(bars, 0, x+barcodeX, y+barcodeY, barcodeWidth/float64(bars.Bounds().Dx()), barcodeHeight)
+
+	p.texts = append(p.texts, text{x: x + stickerWidth/2, y: y + textY, size: textSize, s: s.Label})
+	return nil
+}
+
+// addRuns adds a rectangle for each run of dark modules in a row of a barcode, which keeps the
+// output much smaller than drawing each module.
+func (p *page) addRuns(code barcode.Barcode, row int, x, y, moduleWidth, height float64) {
+	b := code.Bounds()
+	start := -1
+	for col := b.Min.X; col <= b.Max.X; col++ {
+		dark := col < b.Max.X && isDark(code.At(col, b.Min.Y+row))
+		switch {
+		case dark && start < 0:
+			start = col
+		case !dark && start >= 0:
+			p.rects = append(p.rects, rect{
+				x: x + float64(start-b.Min.X)*moduleWidth,
+				y: y,
+				w: float64(col-start) * moduleWidth,
+				h: height,
+			})
+			start = -1
+		}
+	}
+}
+
+func isDark(c color.Color) bool {
+	return color.GrayModel.Convert(c).(color.Gray).Y < 128
+}
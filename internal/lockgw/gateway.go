@@ -0,0 +1,314 @@
+package lockgw
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/devicetoken"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/telemetry"
+	"github.com/semanticallynull/bookingengine-backend/track"
+)
+
+var (
+	ErrNotConnected = errors.New("lock not connected")
+	ErrNoAck        = errors.New("lock did not acknowledge command")
+	ErrRejected     = errors.New("lock rejected command")
+)
+
+// loginTimeout is how long a new connection has to log in.
+const loginTimeout = 10 * time.Second
+
+// Client sends commands to locks.
+type Client interface {
+	// Send sends a command to the lock with the given IMEI and waits for it to be acknowledged.
+	// The values returned by the lock with its acknowledgement are returned.
+	Send(ctx context.Context, imei string, cmd Command, args ...string) ([]string, error)
+}
+
+// PositionRecorder stores positions reported by locks.
+type PositionRecorder interface {
+	Record(ctx context.Context, imei string, fixes []track.Fix) error
+}
+
+// TelemetryRecorder stores status reports from locks.
+type TelemetryRecorder interface {
+	Record(ctx context.Context, imei string, readings []telemetry.Reading) error
+}
+
+// Gateway accepts connections from locks and relays commands to them.
+type Gateway struct {
+	deviceKey  string
+	ackTimeout time.Duration
+	positions  PositionRecorder
+	status     TelemetryRecorder
+	logger     *slog.Logger
+
+	mu    sync.Mutex
+	conns map[string]*lockConn
+}
+
+func New(deviceKey string, ackTimeout time.Duration, positions PositionRecorder, status TelemetryRecorder,
+	logger *slog.Logger) *Gateway {
+	return &Gateway{
+		deviceKey:  deviceKey,
+		ackTimeout: ackTimeout,
+		positions:  positions,
+		status:     status,
+		logger:     logger,
+		conns:      make(map[string]*lockConn),
+	}
+}
+
+// ListenAndServe accepts lock connections on addr until ctx is cancelled. The gateway must have a
+// device key, as without one any client could log in as any lock.
+func (g *Gateway) ListenAndServe(ctx context.Context, addr string) error {
+	if g.deviceKey == "" {
+		return errors.New("lock gateway has no device key")
+	}
+	var lc net.ListenConfig
+	l, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go g.serve(ctx, c)
+	}
+}
+
+func (g *Gateway) Send(ctx context.Context, imei string, cmd Command, args ...string) ([]string, error) {
+	g.mu.Lock()
+	lc, ok := g.conns[imei]
+	g.mu.Unlock()
+	if !ok {
+		return nil, ErrNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.ackTimeout)
+	defer cancel()
+	return lc.send(ctx, cmd, args)
+}
+
+func (g *Gateway) serve(ctx context.Context, c net.Conn) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	imei, err := g.login(c, r)
+	if err != nil {
+		g.logger.WarnContext(ctx, "lock login failed", "remote", c.RemoteAddr().String(), "error", err)
+		return
+	}
+
+	lc := &lockConn{conn: c, pending: make(map[uint64]chan Message)}
+	g.mu.Lock()
+	if old, ok := g.conns[imei]; ok {
+		old.conn.Close()
+	}
+	g.conns[imei] = lc
+	g.mu.Unlock()
+
+	logger := g.logger.With("imei", imei)
+	logger.InfoContext(ctx, "lock connected", "remote", c.RemoteAddr().String())
+
+	defer func() {
+		g.mu.Lock()
+		if g.conns[imei] == lc {
+			delete(g.conns, imei)
+		}
+		g.mu.Unlock()
+		lc.closePending()
+		logger.InfoContext(ctx, "lock disconnected")
+	}()
+
+	for {
+		_ = c.SetReadDeadline(time.Now().Add(2 * HeartbeatInterval))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		m, err := ParseMessage(line)
+		if err != nil {
+			continue
+		}
+
+		switch m.Type() {
+		case msgAck:
+			lc.ack(m)
+		case msgPos:
+			g.recordPosition(ctx, logger, imei, m)
+		case msgStatus:
+			g.recordStatus(ctx, logger, imei, m)
+		case msgHB:
+		default:
+			logger.WarnContext(ctx, "unexpected message from lock", "type", m.Type())
+		}
+	}
+}
+
+func (g *Gateway) login(c net.Conn, r *bufio.Reader) (string, error) {
+	_ = c.SetReadDeadline(time.Now().Add(loginTimeout))
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	m, err := ParseMessage(line)
+	if err != nil {
+		return "", err
+	}
+	if m.Type() != msgLogin || len(m) != 3 {
+		return "", fmt.Errorf("%w: expected login", ErrMalformed)
+	}
+
+	imei, token := m[1], m[2]
+	ok := devicetoken.Valid(g.deviceKey, imei, token)
+	if _, err := c.Write([]byte(LoginResult(ok).String())); err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errors.New("invalid device token")
+	}
+	return imei, nil
+}
+
+func (g *Gateway) recordPosition(ctx context.Context, logger *slog.Logger, imei string, m Message) {
+	if len(m) < 4 {
+		return
+	}
+	at, err := parseUnix(m[1])
+	if err != nil {
+		return
+	}
+	lat, latErr := strconv.ParseFloat(m[2], 64)
+	lng, lngErr := strconv.ParseFloat(m[3], 64)
+	if latErr != nil || lngErr != nil {
+		return
+	}
+
+	fix := track.Fix{RecordedAt: at, Location: geo.Point{Lat: lat, Lng: lng}}
+	if len(m) > 4 {
+		if acc, err := strconv.ParseFloat(m[4], 64); err == nil {
+			fix.Accuracy = sql.NullFloat64{Float64: acc, Valid: true}
+		}
+	}
+	if err := g.positions.Record(ctx, imei, []track.Fix{fix}); err != nil {
+		logger.ErrorContext(ctx, "failed to record lock position", "error", err)
+	}
+}
+
+func (g *Gateway) recordStatus(ctx context.Context, logger *slog.Logger, imei string, m Message) {
+	if len(m) != 5 {
+		return
+	}
+	at, err := parseUnix(m[1])
+	if err != nil {
+		return
+	}
+
+	reading := telemetry.Reading{RecordedAt: at, LockState: telemetry.LockStateUnknown}
+	if v, err := strconv.ParseInt(m[2], 10, 32); err == nil {
+		reading.BatteryVoltage = sql.NullInt32{Int32: int32(v), Valid: true}
+	}
+	if state := telemetry.LockState(m[3]); state == telemetry.LockStateLocked || state == telemetry.LockStateUnlocked {
+		reading.LockState = state
+	}
+	if s, err := strconv.ParseInt(m[4], 10, 32); err == nil {
+		reading.SignalStrength = sql.NullInt32{Int32: int32(s), Valid: true}
+	}
+	if err := g.status.Record(ctx, imei, []telemetry.Reading{reading}); err != nil {
+		logger.ErrorContext(ctx, "failed to record lock status", "error", err)
+	}
+}
+
+// lockConn is a logged in connection from a lock.
+type lockConn struct {
+	conn net.Conn
+
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]chan Message
+}
+
+func (lc *lockConn) send(ctx context.Context, cmd Command, args []string) ([]string, error) {
+	lc.mu.Lock()
+	lc.seq++
+	seq := lc.seq
+	reply := make(chan Message, 1)
+	lc.pending[seq] = reply
+	_ = lc.conn.SetWriteDeadline(time.Now().Add(loginTimeout))
+	_, err := lc.conn.Write([]byte(CommandMessage(seq, cmd, args...).String()))
+	lc.mu.Unlock()
+
+	defer func() {
+		lc.mu.Lock()
+		delete(lc.pending, seq)
+		lc.mu.Unlock()
+	}()
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotConnected, err)
+	}
+
+	select {
+	case m, ok := <-reply:
+		if !ok {
+			return nil, ErrNotConnected
+		}
+		if m[2] != resultOK {
+			reason := ""
+			if len(m) > 3 {
+				reason = m[3]
+			}
+			return nil, fmt.Errorf("%w: %s", ErrRejected, reason)
+		}
+		return m[3:], nil
+	case <-ctx.Done():
+		return nil, ErrNoAck
+	}
+}
+
+func (lc *lockConn) ack(m Message) {
+	if len(m) < 3 {
+		return
+	}
+	seq, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if reply, ok := lc.pending[seq]; ok {
+		reply <- m
+		delete(lc.pending, seq)
+	}
+}
+
+func (lc *lockConn) closePending() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	for seq, reply := range lc.pending {
+		close(reply)
+		delete(lc.pending, seq)
+	}
+}
@@ -0,0 +1,143 @@
+// Package lockgw is a gateway to the smart locks fitted to the bikes.
+//
+// Locks hold a long-lived TCP connection to the gateway and speak a line based protocol. Each line
+// is a comma separated message terminated by "\n":
+//
+//	lock -> gateway  LOGIN,<imei>,<device token>
+//	gateway -> lock  LOGIN,OK | LOGIN,ERR
+//	gateway -> lock  CMD,<seq>,<UNLOCK|LOCK|LOCATE|ALARM>[,<arg>...]
+//	lock -> gateway  ACK,<seq>,OK[,<value>...] | ACK,<seq>,ERR,<reason>
+//	lock -> gateway  POS,<unix time>,<latitude>,<longitude>[,<accuracy>]
+//	lock -> gateway  STATUS,<unix time>,<battery voltage>,<locked|unlocked>,<signal strength>
+//	lock -> gateway  HB
+//
+// The device token is the same token the lock uses for the HTTP device endpoints. Locks send a
+// heartbeat at least every HeartbeatInterval and are disconnected if they go quiet for longer than
+// twice that.
+package lockgw
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeartbeatInterval is how often a lock must send a message to keep its connection open.
+const HeartbeatInterval = 30 * time.Second
+
+type Command string
+
+const (
+	Unlock Command = "UNLOCK"
+	Lock   Command = "LOCK"
+	Locate Command = "LOCATE"
+	Alarm  Command = "ALARM"
+)
+
+const (
+	msgLogin  = "LOGIN"
+	msgCmd    = "CMD"
+	msgAck    = "ACK"
+	msgPos    = "POS"
+	msgStatus = "STATUS"
+	msgHB     = "HB"
+
+	resultOK  = "OK"
+	resultErr = "ERR"
+)
+
+var ErrMalformed = errors.New("malformed message")
+
+// Message is a single protocol line split into its fields.
+type Message []string
+
+// ParseMessage splits a protocol line into its fields.
+func ParseMessage(line string) (Message, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, ErrMalformed
+	}
+	return strings.Split(line, ","), nil
+}
+
+// Type returns the message type, e.g. "ACK".
+func (m Message) Type() string {
+	return m[0]
+}
+
+func (m Message) String() string {
+	return strings.Join(m, ",") + "\n"
+}
+
+// LoginMessage builds the message a lock sends when it connects.
+func LoginMessage(imei, token string) Message {
+	return Message{msgLogin, imei, token}
+}
+
+// LoginResult builds the gateway's reply to a login.
+func LoginResult(ok bool) Message {
+	if ok {
+		return Message{msgLogin, resultOK}
+	}
+	return Message{msgLogin, resultErr}
+}
+
+// CommandMessage builds a command sent to a lock.
+func CommandMessage(seq uint64, cmd Command, args ...string) Message {
+	return append(Message{msgCmd, strconv.FormatUint(seq, 10), string(cmd)}, args...)
+}
+
+// AckMessage builds a lock's successful reply to a command.
+func AckMessage(seq uint64, values ...string) Message {
+	return append(Message{msgAck, strconv.FormatUint(seq, 10), resultOK}, values...)
+}
+
+// NackMessage builds a lock's reply to a command it could not carry out.
+func NackMessage(seq uint64, reason string) Message {
+	return Message{msgAck, strconv.FormatUint(seq, 10), resultErr, reason}
+}
+
+// PositionMessage builds a position report.
+func PositionMessage(at time.Time, lat, lng float64) Message {
+	return Message{msgPos, strconv.FormatInt(at.Unix(), 10), formatFloat(lat), formatFloat(lng)}
+}
+
+// StatusMessage builds a status report.
+func StatusMessage(at time.Time, voltage int, locked bool, signal int) Message {
+	state := "unlocked"
+	if locked {
+		state = "locked"
+	}
+	return Message{msgStatus, strconv.FormatInt(at.Unix(), 10), strconv.Itoa(voltage), state, strconv.Itoa(signal)}
+}
+
+// HeartbeatMessage builds a heartbeat.
+func HeartbeatMessage() Message {
+	return Message{msgHB}
+}
+
+// ParseCommand extracts the sequence number, command and arguments from a CMD message.
+func ParseCommand(m Message) (uint64, Command, []string, error) {
+	if len(m) < 3 || m.Type() != msgCmd {
+		return 0, "", nil, ErrMalformed
+	}
+	seq, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("%w: bad sequence number", ErrMalformed)
+	}
+	return seq, Command(m[2]), m[3:], nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
+
+func parseUnix(s string) (time.Time, error) {
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: bad timestamp", ErrMalformed)
+	}
+	return time.Unix(secs, 0), nil
+}
@@ -0,0 +1,149 @@
+// Package mds reports trips, vehicle status changes and the fleet to the city using the Mobility
+// Data Specification (https://github.com/openmobilityfoundation/mobility-data-specification)
+// provider API, version 1.2.
+//
+// Trips and status changes are published per hour, once the hour is over, and are paged.
+package mds
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+	"github.com/semanticallynull/bookingengine-backend/track"
+)
+
+const (
+	// Version is the version of the specification implemented.
+	Version = "1.2.0"
+	// ContentType is the media type of responses.
+	ContentType = "application/vnd.mds.provider+json;version=1.2"
+
+	// PageSize is the most records returned in a page.
+	PageSize = 1000
+
+	// hourFormat is the format of the end_time and event_time query parameters.
+	hourFormat = "2006-01-02T15"
+
+	vehicleTypeBicycle       = "bicycle"
+	propulsionElectricAssist = "electric_assist"
+	currency                 = "EUR"
+)
+
+var (
+	// ErrInvalidHour is returned for a time window which isn't an hour in YYYY-MM-DDTHH format.
+	ErrInvalidHour = errors.New("hour must be in YYYY-MM-DDTHH format")
+	// ErrNotProcessed is returned for an hour which isn't over yet.
+	ErrNotProcessed = errors.New("hour has not been processed yet")
+)
+
+// ParseHour parses an hour in YYYY-MM-DDTHH format, in UTC.
+func ParseHour(s string) (time.Time, error) {
+	t, err := time.Parse(hourFormat, s)
+	if err != nil {
+		return time.Time{}, ErrInvalidHour
+	}
+	return t, nil
+}
+
+// Config identifies us to the regulator.
+type Config struct {
+	// ProviderID is the ID the specification assigns us.
+	ProviderID   uuid.UUID
+	ProviderName string
+}
+
+// Provider builds the provider API's records.
+type Provider struct {
+	cfg    Config
+	rr     *ride.Repository
+	br     *bike.Repository
+	tr     *track.Repository
+	curves bike.BatteryCurves
+}
+
+func New(cfg Config, rr *ride.Repository, br *bike.Repository, tr *track.Repository,
+	curves bike.BatteryCurves) *Provider {
+	return &Provider{cfg: cfg, rr: rr, br: br, tr: tr, curves: curves}
+}
+
+// vehicle identifies a bike in a record.
+type vehicle struct {
+	ProviderID      uuid.UUID `json:"provider_id"`
+	ProviderName    string    `json:"provider_name"`
+	DeviceID        uuid.UUID `json:"device_id"`
+	VehicleID       string    `json:"vehicle_id"`
+	VehicleType     string    `json:"vehicle_type"`
+	PropulsionTypes []string  `json:"propulsion_types"`
+}
+
+func (p *Provider) vehicle(bikeID uuid.UUID, label string) vehicle {
+	return vehicle{
+		ProviderID:      p.cfg.ProviderID,
+		ProviderName:    p.cfg.ProviderName,
+		DeviceID:        bikeID,
+		VehicleID:       label,
+		VehicleType:     vehicleTypeBicycle,
+		PropulsionTypes: []string{propulsionElectricAssist},
+	}
+}
+
+// checkHour checks the hour starting at from is over, so its records are complete.
+func checkHour(from, now time.Time) error {
+	if from.Add(time.Hour).After(now) {
+		return ErrNotProcessed
+	}
+	return nil
+}
+
+// page returns the records on the 1-based page n, and whether there are more after it.
+func page[T any](records []T, n int) ([]T, bool) {
+	start := (n - 1) * PageSize
+	if start >= len(records) {
+		return []T{}, false
+	}
+	end := min(start+PageSize, len(records))
+	return records[start:end], end < len(records)
+}
+
+// Timestamp is a time in milliseconds since the epoch.
+type Timestamp int64
+
+func timestamp(t time.Time) Timestamp {
+	return Timestamp(t.UnixMilli())
+}
+
+// Feature is a GeoJSON point feature with the time the position was recorded.
+type Feature struct {
+	Type       string            `json:"type"`
+	Properties featureProperties `json:"properties"`
+	Geometry   geometry          `json:"geometry"`
+}
+
+type featureProperties struct {
+	Timestamp Timestamp `json:"timestamp"`
+}
+
+type geometry struct {
+	Type string `json:"type"`
+	// Coordinates are longitude then latitude
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func feature(p geo.Point, t time.Time) Feature {
+	return Feature{
+		Type:       "Feature",
+		Properties: featureProperties{Timestamp: timestamp(t)},
+		Geometry:   geometry{Type: "Point", Coordinates: [2]float64{p.Lng, p.Lat}},
+	}
+}
+
+// FeatureCollection is a GeoJSON feature collection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
@@ -0,0 +1,235 @@
+package mds
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/track"
+)
+
+// Vehicle states.
+const (
+	StateAvailable      = "available"
+	StateNonOperational = "non_operational"
+	StateOnTrip         = "on_trip"
+	StateRemoved        = "removed"
+	StateUnknown        = "unknown"
+)
+
+// Event types.
+const (
+	EventTripStart         = "trip_start"
+	EventTripEnd           = "trip_end"
+	EventProviderDropOff   = "provider_drop_off"
+	EventLocated           = "located"
+	EventMaintenance       = "maintenance"
+	EventMaintenancePickUp = "maintenance_pick_up"
+	EventMissing           = "missing"
+	EventDecommissioned    = "decommissioned"
+	EventUnspecified       = "unspecified"
+)
+
+// lifecycleEvent maps a bike moving to state to, from state from, onto the vehicle state and event
+// it is reported as.
+func lifecycleEvent(from, to bike.State) (string, string) {
+	switch to {
+	case bike.StateActive:
+		if from == bike.StateLost {
+			return StateAvailable, EventLocated
+		}
+		return StateAvailable, EventProviderDropOff
+	case bike.StateMaintenance:
+		return StateRemoved, EventMaintenancePickUp
+	case bike.StateOutOfService:
+		return StateNonOperational, EventMaintenance
+	case bike.StateLost:
+		return StateUnknown, EventMissing
+	case bike.StateRetired:
+		return StateRemoved, EventDecommissioned
+	}
+	return StateUnknown, EventUnspecified
+}
+
+// StatusChange is a change in a bike's state: the start or end of a ride, or a lifecycle change.
+type StatusChange struct {
+	vehicle
+	VehicleState  string    `json:"vehicle_state"`
+	EventTypes    []string  `json:"event_types"`
+	EventTime     Timestamp `json:"event_time"`
+	EventLocation Feature   `json:"event_location"`
+	// TripID is the ride started or ended, for trip events
+	TripID *uuid.UUID `json:"trip_id,omitempty"`
+
+	bikeID uuid.UUID
+	at     time.Time
+}
+
+// StatusChanges fetches page n of the status changes which happened in the hour starting at from.
+func (p *Provider) StatusChanges(ctx context.Context, from time.Time, n int, now time.Time) ([]StatusChange, bool,
+	error) {
+	if err := checkHour(from, now); err != nil {
+		return nil, false, err
+	}
+	to := from.Add(time.Hour)
+
+	started, err := p.rr.GetStartedBetween(ctx, from, to)
+	if err != nil {
+		return nil, false, err
+	}
+	ended, err := p.rr.GetEndedBetween(ctx, from, to)
+	if err != nil {
+		return nil, false, err
+	}
+	lifecycle, err := p.br.GetStateChangesBetween(ctx, from, to)
+	if err != nil {
+		return nil, false, err
+	}
+	bikes, err := p.bikes(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changes := make([]StatusChange, 0, len(started)+len(ended)+len(lifecycle))
+	for _, r := range started {
+		changes = append(changes, StatusChange{
+			vehicle:      p.vehicle(r.BikeID, r.BikeLabel),
+			VehicleState: StateOnTrip,
+			EventTypes:   []string{EventTripStart},
+			TripID:       &r.ID,
+			bikeID:       r.BikeID,
+			at:           r.StartedAt,
+		})
+	}
+	for _, r := range ended {
+		changes = append(changes, StatusChange{
+			vehicle:      p.vehicle(r.BikeID, r.BikeLabel),
+			VehicleState: StateAvailable,
+			EventTypes:   []string{EventTripEnd},
+			TripID:       &r.ID,
+			bikeID:       r.BikeID,
+			at:           r.EndedAt.Time,
+		})
+	}
+	for _, c := range lifecycle {
+		state, event := lifecycleEvent(c.FromState, c.ToState)
+		changes = append(changes, StatusChange{
+			vehicle:      p.vehicle(c.BikeID, bikes[c.BikeID].Label),
+			VehicleState: state,
+			EventTypes:   []string{event},
+			bikeID:       c.BikeID,
+			at:           c.ChangedAt,
+		})
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].at.Before(changes[j].at)
+	})
+
+	changes, more := page(changes, n)
+	for i := range changes {
+		c := &changes[i]
+		c.EventTime = timestamp(c.at)
+		// The event happened where the lock last reported it was. Bikes which have never reported a
+		// fix can only be placed where they are now.
+		f, err := p.tr.GetFixBefore(ctx, c.bikeID, c.at)
+		switch {
+		case errors.Is(err, track.ErrNoFix):
+			c.EventLocation = feature(bikes[c.bikeID].Location, c.at)
+		case err != nil:
+			return nil, false, err
+		default:
+			c.EventLocation = feature(f.Location, f.RecordedAt)
+		}
+	}
+	return changes, more, nil
+}
+
+// Vehicle is the current state of a bike.
+type Vehicle struct {
+	vehicle
+	LastEventTime     Timestamp `json:"last_event_time"`
+	LastState         string    `json:"last_state"`
+	LastEventTypes    []string  `json:"last_event_types"`
+	LastEventLocation Feature   `json:"last_event_location"`
+	CurrentLocation   Feature   `json:"current_location"`
+	// BatteryPct is the battery's state of charge between 0 and 1, if it is known
+	BatteryPct *float64 `json:"battery_pct,omitempty"`
+}
+
+// Vehicles fetches page n of the bikes which haven't been retired, with their current state.
+func (p *Provider) Vehicles(ctx context.Context, n int, now time.Time) ([]Vehicle, bool, error) {
+	fleet, err := p.br.ListBikes(ctx, false)
+	if err != nil {
+		return nil, false, err
+	}
+	fleet, more := page(fleet, n)
+
+	ids := make([]uuid.UUID, 0, len(fleet))
+	for _, b := range fleet {
+		ids = append(ids, b.ID)
+	}
+	active, err := p.rr.GetActiveRidesForBikes(ctx, ids)
+	if err != nil {
+		return nil, false, err
+	}
+	riding := make(map[uuid.UUID]time.Time, len(active))
+	for _, r := range active {
+		riding[r.BikeID] = r.StartedAt
+	}
+	lastRides, err := p.rr.GetLastRides(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	vehicles := make([]Vehicle, 0, len(fleet))
+	for _, b := range fleet {
+		state, event := lifecycleEvent("", b.State)
+		at := now
+		if b.StateChangedAt.Valid {
+			at = b.StateChangedAt.Time
+		}
+		if last, ok := lastRides[b.ID]; ok && last.EndedAt.Valid && last.EndedAt.Time.After(at) {
+			state, event, at = StateAvailable, EventTripEnd, last.EndedAt.Time
+		}
+		if started, ok := riding[b.ID]; ok {
+			state, event, at = StateOnTrip, EventTripStart, started
+		}
+
+		located := now
+		if b.LocationUpdatedAt.Valid {
+			located = b.LocationUpdatedAt.Time
+		}
+		v := Vehicle{
+			vehicle:           p.vehicle(b.ID, b.Label),
+			LastEventTime:     timestamp(at),
+			LastState:         state,
+			LastEventTypes:    []string{event},
+			LastEventLocation: feature(b.Location, located),
+			CurrentLocation:   feature(b.Location, located),
+		}
+		if b.BatteryVoltage.Valid {
+			pct := float64(p.curves.For(b.BatteryModel).Percentage(int(b.BatteryVoltage.Int32))) / 100
+			v.BatteryPct = &pct
+		}
+		vehicles = append(vehicles, v)
+	}
+	return vehicles, more, nil
+}
+
+// bikes fetches every bike, including retired bikes, keyed by ID.
+func (p *Provider) bikes(ctx context.Context) (map[uuid.UUID]bike.Bike, error) {
+	bikes, err := p.br.ListBikes(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]bike.Bike, len(bikes))
+	for _, b := range bikes {
+		byID[b.ID] = b
+	}
+	return byID, nil
+}
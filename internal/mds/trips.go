@@ -0,0 +1,76 @@
+package mds
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/track"
+)
+
+// Trip is a ride.
+type Trip struct {
+	vehicle
+	TripID uuid.UUID `json:"trip_id"`
+	// TripDuration is in seconds and TripDistance in metres
+	TripDuration int               `json:"trip_duration"`
+	TripDistance int               `json:"trip_distance"`
+	Route        FeatureCollection `json:"route"`
+	// Accuracy is the worst accuracy of the route's fixes, in metres
+	Accuracy  int       `json:"accuracy"`
+	StartTime Timestamp `json:"start_time"`
+	EndTime   Timestamp `json:"end_time"`
+	// ActualCost is what the customer was charged, in cents
+	ActualCost int    `json:"actual_cost"`
+	Currency   string `json:"currency"`
+}
+
+// Trips fetches page n of the rides which ended in the hour starting at from.
+func (p *Provider) Trips(ctx context.Context, from time.Time, n int, now time.Time) ([]Trip, bool, error) {
+	if err := checkHour(from, now); err != nil {
+		return nil, false, err
+	}
+
+	rides, err := p.rr.GetEndedBetween(ctx, from, from.Add(time.Hour))
+	if err != nil {
+		return nil, false, err
+	}
+	rides, more := page(rides, n)
+
+	ids := make([]uuid.UUID, 0, len(rides))
+	for _, r := range rides {
+		ids = append(ids, r.ID)
+	}
+	fixes, err := p.tr.GetFixesForRides(ctx, ids)
+	if err != nil {
+		return nil, false, err
+	}
+
+	trips := make([]Trip, 0, len(rides))
+	for _, r := range rides {
+		route := track.Clean(fixes[r.ID])
+		t := Trip{
+			vehicle:      p.vehicle(r.BikeID, r.BikeLabel),
+			TripID:       r.ID,
+			TripDuration: int(r.EndedAt.Time.Sub(r.StartedAt).Seconds()),
+			TripDistance: track.Summarise(fixes[r.ID]).DistanceMeters,
+			Route:        FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0, len(route))},
+			StartTime:    timestamp(r.StartedAt),
+			EndTime:      timestamp(r.EndedAt.Time),
+			ActualCost:   int(r.UnlockFee.Int32 + r.TimeCharge.Int32),
+			Currency:     currency,
+		}
+		var accuracy float64
+		for _, f := range route {
+			t.Route.Features = append(t.Route.Features, feature(f.Location, f.RecordedAt))
+			if f.Accuracy.Valid {
+				accuracy = math.Max(accuracy, f.Accuracy.Float64)
+			}
+		}
+		t.Accuracy = int(math.Ceil(accuracy))
+		trips = append(trips, t)
+	}
+	return trips, more, nil
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
+	"github.com/auth0/go-jwt-middleware/v2/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope is a middleware which only allows requests whose token was granted the scope. It
+// must run after EnsureValidToken.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScope(c, scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": "FORBIDDEN", "message": "Insufficient scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasScope reports whether the token in the Gin context was granted the scope.
+func HasScope(c *gin.Context, scope string) bool {
+	claims, ok := c.Request.Context().Value(jwtmiddleware.ContextKey{}).(*validator.ValidatedClaims)
+	if !ok {
+		return false
+	}
+	custom, ok := claims.CustomClaims.(*CustomClaims)
+	if !ok {
+		return false
+	}
+	return slices.Contains(strings.Fields(custom.Scope), scope)
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/devicetoken"
+)
+
+// StaticToken is a middleware that only allows requests carrying the given bearer token.
+// It is used for machine-to-machine endpoints which are not called with an Auth0 JWT.
+func StaticToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "Invalid token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// DeviceAuth is a middleware that checks the bearer token matches the device in the :imei path parameter.
+func DeviceAuth(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || !devicetoken.Valid(key, c.Param("imei"), provided) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": "UNAUTHORIZED", "message": "Invalid device token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoEmail is returned when a customer has no email address to notify.
+var ErrNoEmail = errors.New("customer has no email address")
+
+// EmailLookup finds the address to email a customer at. It returns an empty address if they don't
+// have one.
+type EmailLookup func(ctx context.Context, customerID uuid.UUID) (string, error)
+
+// SMTPConfig is the mail server customer notifications are sent through.
+type SMTPConfig struct {
+	// Addr is the server's host and port
+	Addr string
+	// Username and Password authenticate with the server if they are set
+	Username string
+	Password string
+	// From is the address notifications are sent from
+	From string
+}
+
+// EmailNotifier emails customers their notifications and passes operations alerts on to another
+// notifier.
+type EmailNotifier struct {
+	cfg    SMTPConfig
+	lookup EmailLookup
+	ops    Notifier
+}
+
+func NewEmailNotifier(cfg SMTPConfig, lookup EmailLookup, ops Notifier) *EmailNotifier {
+	return &EmailNotifier{cfg: cfg, lookup: lookup, ops: ops}
+}
+
+func (n *EmailNotifier) NotifyCustomer(ctx context.Context, customerID uuid.UUID, subject, body string) error {
+	to, err := n.lookup(ctx, customerID)
+	if err != nil {
+		return fmt.Errorf("failed to get customer's email: %w", err)
+	}
+	if to == "" {
+		return ErrNoEmail
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return fmt.Errorf("invalid email %q: %w", to, err)
+	}
+
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(n.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)
+	}
+	return smtp.SendMail(n.cfg.Addr, auth, n.cfg.From, []string{to}, message(n.cfg.From, to, subject, body,
+		time.Now()))
+}
+
+func (n *EmailNotifier) NotifyOps(ctx context.Context, subject, body string) error {
+	return n.ops.NotifyOps(ctx, subject, body)
+}
+
+// message builds a plain text email.
+func message(from, to, subject, body string, date time.Time) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + date.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,74 @@
+// Package notify sends notifications to customers and to the operations team.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notifier delivers notifications.
+type Notifier interface {
+	// NotifyCustomer tells a customer about something which happened to their account.
+	NotifyCustomer(ctx context.Context, customerID uuid.UUID, subject, body string) error
+	// NotifyOps alerts the operations team.
+	NotifyOps(ctx context.Context, subject, body string) error
+}
+
+// LogNotifier writes notifications to the log, and posts operations alerts to a chat webhook
+// (e.g. a Slack incoming webhook) when one is configured.
+type LogNotifier struct {
+	logger     *slog.Logger
+	webhookURL string
+	httpClient *http.Client
+}
+
+func NewLogNotifier(logger *slog.Logger, opsWebhookURL string) *LogNotifier {
+	return &LogNotifier{
+		logger:     logger,
+		webhookURL: opsWebhookURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (n *LogNotifier) NotifyCustomer(ctx context.Context, customerID uuid.UUID, subject, body string) error {
+	n.logger.InfoContext(ctx, "customer notification", "customerId", customerID, "subject", subject, "body", body)
+	return nil
+}
+
+func (n *LogNotifier) NotifyOps(ctx context.Context, subject, body string) error {
+	n.logger.InfoContext(ctx, "ops notification", "subject", subject, "body", body)
+	if n.webhookURL == "" {
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]string{"text": fmt.Sprintf("*%s*\n%s", subject, body)})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("ops webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
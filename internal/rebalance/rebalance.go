@@ -0,0 +1,267 @@
+// Package rebalance recommends moving bikes between stations so that stations have their target
+// number of bikes and booked bikes are back at their station before the booking starts.
+package rebalance
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/booking"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/ride"
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+const (
+	// PresenceRadius is how close, in metres, a bike has to be to a station to count as being at it,
+	// for stations without a return radius.
+	PresenceRadius = 75.0
+
+	// DefaultHorizon is how far ahead bookings are planned for if no horizon is given, and
+	// MaxHorizon the furthest ahead a plan can look.
+	DefaultHorizon = 12 * time.Hour
+	MaxHorizon     = 7 * 24 * time.Hour
+)
+
+// Reason explains why a move is recommended.
+type Reason string
+
+const (
+	// ReasonBooking moves take a booked bike back to its station before the booking starts.
+	ReasonBooking Reason = "booking"
+	// ReasonBelowTarget moves fill a station which has fewer bikes than its target.
+	ReasonBelowTarget Reason = "below_target"
+)
+
+// Move is a recommendation to take a bike to a station.
+type Move struct {
+	BikeID    uuid.UUID
+	BikeLabel string
+	// From is the station the bike is at. It is nil when the bike isn't at any station, in which case
+	// it has to be collected from Location.
+	From     *station.Station
+	Location geo.Point
+	To       station.Station
+	// By is when the bike needs to be at the station.
+	By     time.Time
+	Reason Reason
+	// Booking is the booking the bike is needed for, for ReasonBooking moves.
+	Booking uuid.NullUUID
+	// Distance is how far the bike has to be moved, in metres.
+	Distance float64
+}
+
+// Planner plans rebalancing from the current state of the fleet.
+type Planner struct {
+	sr  *station.Repository
+	br  *bike.Repository
+	rr  *ride.Repository
+	bkr *booking.Repository
+}
+
+func New(sr *station.Repository, br *bike.Repository, rr *ride.Repository, bkr *booking.Repository) *Planner {
+	return &Planner{sr: sr, br: br, rr: rr, bkr: bkr}
+}
+
+// Plan recommends moves for bookings which start within horizon of now, and to bring stations up to
+// their target.
+func (p *Planner) Plan(ctx context.Context, now time.Time, horizon time.Duration) ([]Move, error) {
+	stations, err := p.sr.GetStations()
+	if err != nil {
+		return nil, err
+	}
+
+	bikes, err := p.br.ListBikes(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(bikes))
+	for _, b := range bikes {
+		ids = append(ids, b.ID)
+	}
+
+	rides, err := p.rr.GetActiveRidesForBikes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	riding := make(map[uuid.UUID]bool, len(rides))
+	for _, r := range rides {
+		riding[r.BikeID] = true
+	}
+
+	// Only bikes which are parked and in service can be moved
+	movable := make([]bike.Bike, 0, len(bikes))
+	for _, b := range bikes {
+		if b.State == bike.StateActive && !riding[b.ID] {
+			movable = append(movable, b)
+		}
+	}
+
+	bookings, err := p.bkr.GetUpcomingBookingsForBikes(ctx, ids, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return Recommend(stations, movable, bookings, now, horizon), nil
+}
+
+// Recommend works out the moves needed given the stations, the bikes which can be moved and the
+// bookings which haven't finished, soonest first.
+//
+// A booked bike which isn't at its station is moved back there by the time the booking starts. Then,
+// while stations with a target have fewer bikes than it, the nearest spare bike is moved to them. A
+// spare bike is one which isn't booked within the horizon and is either not at a station or at a
+// station with more bikes than its target. Stations without a target are left alone.
+func Recommend(stations []station.Station, bikes []bike.Bike, bookings []booking.Booking, now time.Time,
+	horizon time.Duration) []Move {
+	byID := make(map[uuid.UUID]station.Station, len(stations))
+	for _, s := range stations {
+		byID[s.ID] = s
+	}
+
+	at := make(map[uuid.UUID]*station.Station, len(bikes))
+	for _, b := range bikes {
+		at[b.ID] = nearestStation(b.Location, stations)
+	}
+
+	// The number of bikes each station will have once the moves are made
+	count := make(map[uuid.UUID]int, len(stations))
+	for _, b := range bikes {
+		if s := at[b.ID]; s != nil {
+			count[s.ID]++
+		}
+	}
+
+	var moves []Move
+
+	nextBooking := make(map[uuid.UUID]booking.Booking)
+	for _, bk := range bookings {
+		if _, ok := nextBooking[bk.BikeID]; !ok && bk.StartTime.Before(now.Add(horizon)) {
+			nextBooking[bk.BikeID] = bk
+		}
+	}
+	for _, b := range bikes {
+		bk, ok := nextBooking[b.ID]
+		if !ok || b.StationID == nil {
+			continue
+		}
+		home, ok := byID[*b.StationID]
+		from := at[b.ID]
+		if !ok || (from != nil && from.ID == home.ID) {
+			continue
+		}
+
+		if from != nil {
+			count[from.ID]--
+		}
+		count[home.ID]++
+		moves = append(moves, Move{
+			BikeID:    b.ID,
+			BikeLabel: b.Label,
+			From:      from,
+			Location:  b.Location,
+			To:        home,
+			By:        bk.StartTime,
+			Reason:    ReasonBooking,
+			Booking:   uuid.NullUUID{UUID: bk.ID, Valid: true},
+			Distance:  geo.Distance(b.Location, home.Location),
+		})
+	}
+
+	// Spare bikes at stations over their target, or not at a station at all
+	var spare []bike.Bike
+	for _, b := range bikes {
+		if _, booked := nextBooking[b.ID]; booked {
+			continue
+		}
+		s := at[b.ID]
+		if s == nil {
+			spare = append(spare, b)
+		} else if s.TargetBikes > 0 && count[s.ID] > s.TargetBikes {
+			spare = append(spare, b)
+		}
+	}
+
+	var short []station.Station
+	for _, s := range stations {
+		if s.TargetBikes > 0 && count[s.ID] < s.TargetBikes {
+			short = append(short, s)
+		}
+	}
+	// The stations furthest below their target are filled first
+	sort.SliceStable(short, func(i, j int) bool {
+		return short[i].TargetBikes-count[short[i].ID] > short[j].TargetBikes-count[short[j].ID]
+	})
+
+	// Fill the stations a bike at a time so the spare bikes are shared between them
+	used := make(map[uuid.UUID]bool, len(spare))
+	for filled := true; filled; {
+		filled = false
+		for _, s := range short {
+			if count[s.ID] >= s.TargetBikes {
+				continue
+			}
+
+			best := -1
+			bestDistance := math.Inf(1)
+			for i, b := range spare {
+				if used[b.ID] {
+					continue
+				}
+				// A station can only give up bikes while it is over its target
+				if from := at[b.ID]; from != nil && count[from.ID] <= from.TargetBikes {
+					continue
+				}
+				if d := geo.Distance(b.Location, s.Location); d < bestDistance {
+					best, bestDistance = i, d
+				}
+			}
+			if best < 0 {
+				return moves
+			}
+
+			b := spare[best]
+			used[b.ID] = true
+			from := at[b.ID]
+			if from != nil {
+				count[from.ID]--
+			}
+			count[s.ID]++
+			filled = true
+			moves = append(moves, Move{
+				BikeID:    b.ID,
+				BikeLabel: b.Label,
+				From:      from,
+				Location:  b.Location,
+				To:        s,
+				By:        now.Add(horizon),
+				Reason:    ReasonBelowTarget,
+				Distance:  bestDistance,
+			})
+		}
+	}
+	return moves
+}
+
+// nearestStation returns the nearest station whose return radius p is within, or nil if there isn't
+// one.
+func nearestStation(p geo.Point, stations []station.Station) *station.Station {
+	var nearest *station.Station
+	nearestDistance := math.Inf(1)
+	for i := range stations {
+		radius := PresenceRadius
+		if stations[i].ReturnRadius > 0 {
+			radius = float64(stations[i].ReturnRadius)
+		}
+		d := geo.Distance(p, stations[i].Location)
+		if d <= radius && d < nearestDistance {
+			nearest, nearestDistance = &stations[i], d
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,161 @@
+package rebalance
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/booking"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+	"github.com/semanticallynull/bookingengine-backend/station"
+)
+
+func TestRecommend(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+	// The stations are about 1.1km apart, a long way outside PresenceRadius
+	a := station.Station{ID: uuid.New(), Name: "A", Location: geo.Point{Lat: 53.00, Lng: -6.0}}
+	b := station.Station{ID: uuid.New(), Name: "B", Location: geo.Point{Lat: 53.01, Lng: -6.0}}
+	c := station.Station{ID: uuid.New(), Name: "C", Location: geo.Point{Lat: 53.02, Lng: -6.0}}
+	withTarget := func(s station.Station, target int) station.Station {
+		s.TargetBikes = target
+		return s
+	}
+
+	bikeAt := func(label string, p geo.Point, home *station.Station) bike.Bike {
+		bk := bike.Bike{ID: uuid.New(), Label: label, Location: p, State: bike.StateActive}
+		if home != nil {
+			bk.StationID = &home.ID
+		}
+		return bk
+	}
+	// Loose bikes are about 110m from B, too far to count as being at it
+	nearB := geo.Point{Lat: 53.011, Lng: -6.0}
+	farAway := geo.Point{Lat: 53.05, Lng: -6.0}
+
+	atA1 := bikeAt("A1", a.Location, &a)
+	atA2 := bikeAt("A2", a.Location, &a)
+	atA3 := bikeAt("A3", a.Location, &a)
+	homeAAtC := bikeAt("A-at-C", c.Location, &a)
+	looseNearB := bikeAt("near-B", nearB, nil)
+	looseFar := bikeAt("far", farAway, nil)
+
+	bookingFor := func(bk bike.Bike, start time.Duration) booking.Booking {
+		return booking.Booking{ID: uuid.New(), BikeID: bk.ID, UserID: uuid.New(),
+			StartTime: now.Add(start), EndTime: now.Add(start + 2*time.Hour)}
+	}
+
+	type move struct {
+		bike   string
+		to     string
+		reason Reason
+	}
+
+	tests := []struct {
+		name     string
+		stations []station.Station
+		bikes    []bike.Bike
+		bookings []booking.Booking
+		want     []move
+	}{
+		{
+			name:     "nothing to do",
+			stations: []station.Station{a, b},
+			bikes:    []bike.Bike{atA1, looseNearB},
+		},
+		{
+			name:     "booked bike away from its station is taken back",
+			stations: []station.Station{a, c},
+			bikes:    []bike.Bike{homeAAtC},
+			bookings: []booking.Booking{bookingFor(homeAAtC, 2*time.Hour)},
+			want:     []move{{"A-at-C", "A", ReasonBooking}},
+		},
+		{
+			name:     "booked bike at its station stays",
+			stations: []station.Station{a},
+			bikes:    []bike.Bike{atA1},
+			bookings: []booking.Booking{bookingFor(atA1, 2*time.Hour)},
+		},
+		{
+			name:     "bookings after the horizon are left for later",
+			stations: []station.Station{a, c},
+			bikes:    []bike.Bike{homeAAtC},
+			bookings: []booking.Booking{bookingFor(homeAAtC, DefaultHorizon+time.Hour)},
+		},
+		{
+			name:     "nearest loose bike fills a station below its target",
+			stations: []station.Station{a, withTarget(b, 1)},
+			bikes:    []bike.Bike{looseFar, looseNearB},
+			want:     []move{{"near-B", "B", ReasonBelowTarget}},
+		},
+		{
+			name:     "stations over their target give up bikes down to their target",
+			stations: []station.Station{withTarget(a, 1), withTarget(b, 3)},
+			bikes:    []bike.Bike{atA1, atA2, atA3},
+			want: []move{
+				{"A1", "B", ReasonBelowTarget},
+				{"A2", "B", ReasonBelowTarget},
+			},
+		},
+		{
+			name:     "stations without a target don't give up bikes",
+			stations: []station.Station{a, withTarget(b, 1)},
+			bikes:    []bike.Bike{atA1, atA2},
+		},
+		{
+			name:     "booked bikes aren't spare",
+			stations: []station.Station{a, withTarget(b, 1)},
+			bikes:    []bike.Bike{looseNearB},
+			bookings: []booking.Booking{bookingFor(looseNearB, time.Hour)},
+		},
+		{
+			name:     "spare bikes are shared between stations below their target",
+			stations: []station.Station{a, withTarget(b, 2), withTarget(c, 1)},
+			bikes:    []bike.Bike{looseNearB, looseFar},
+			want: []move{
+				{"near-B", "B", ReasonBelowTarget},
+				{"far", "C", ReasonBelowTarget},
+			},
+		},
+		{
+			name:     "a bike taken back for a booking counts towards the target",
+			stations: []station.Station{withTarget(a, 1), c},
+			bikes:    []bike.Bike{homeAAtC, looseFar},
+			bookings: []booking.Booking{bookingFor(homeAAtC, time.Hour)},
+			want:     []move{{"A-at-C", "A", ReasonBooking}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moves := Recommend(tt.stations, tt.bikes, tt.bookings, now, DefaultHorizon)
+
+			got := make([]move, 0, len(moves))
+			for _, m := range moves {
+				got = append(got, move{m.BikeLabel, m.To.Name, m.Reason})
+			}
+			if !slices.Equal(got, tt.want) && (len(got) != 0 || len(tt.want) != 0) {
+				t.Errorf("Recommend() moves = %v, want %v", got, tt.want)
+			}
+
+			for _, m := range moves {
+				switch m.Reason {
+				case ReasonBooking:
+					if !m.Booking.Valid {
+						t.Errorf("booking move of %s has no booking", m.BikeLabel)
+					}
+				case ReasonBelowTarget:
+					if !m.By.Equal(now.Add(DefaultHorizon)) {
+						t.Errorf("move of %s is due by %v, want %v", m.BikeLabel, m.By, now.Add(DefaultHorizon))
+					}
+				}
+				if m.Distance <= 0 {
+					t.Errorf("move of %s has distance %v", m.BikeLabel, m.Distance)
+				}
+			}
+		})
+	}
+}
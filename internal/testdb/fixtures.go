@@ -0,0 +1,33 @@
+package testdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/customer"
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+)
+
+// CreateBike adds an in service bike with the label, away from any station.
+func CreateBike(t testing.TB, db *sqlx.DB, label string) bike.Bike {
+	t.Helper()
+	b := bike.Bike{ID: uuid.New(), Label: label, IMEI: "imei-" + label, Location: geo.Point{Lat: 53.35, Lng: -6.26}}
+	if err := bike.NewRepository(db).Create(context.Background(), &b); err != nil {
+		t.Fatalf("create bike: %v", err)
+	}
+	return b
+}
+
+// CreateCustomer adds a customer with the Auth0 ID.
+func CreateCustomer(t testing.TB, db *sqlx.DB, auth0ID string) *customer.Customer {
+	t.Helper()
+	c, err := customer.NewRepository(db).CreateCustomer(auth0ID)
+	if err != nil {
+		t.Fatalf("create customer: %v", err)
+	}
+	return c
+}
@@ -0,0 +1,86 @@
+// Package issue records problems riders report with bikes.
+package issue
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Category string
+
+const (
+	CategoryFlatTyre         Category = "flat_tyre"
+	CategoryBrokenLock       Category = "broken_lock"
+	CategoryBrakes           Category = "brakes"
+	CategoryDamagedChildSeat Category = "damaged_child_seat"
+	CategoryBattery          Category = "battery"
+	CategoryDirty            Category = "dirty"
+	CategoryOther            Category = "other"
+
+	// CategoryLowRatings is raised automatically when a bike is repeatedly rated badly. Riders can't
+	// report it themselves.
+	CategoryLowRatings Category = "low_ratings"
+)
+
+// Categories lists every category riders can report.
+var Categories = []Category{
+	CategoryFlatTyre,
+	CategoryBrokenLock,
+	CategoryBrakes,
+	CategoryDamagedChildSeat,
+	CategoryBattery,
+	CategoryDirty,
+	CategoryOther,
+}
+
+// Valid reports whether c is a known category.
+func (c Category) Valid() bool {
+	for _, known := range Categories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Severe reports whether issues in this category make the bike unsafe or unusable, so it should be
+// taken out of service until it has been looked at.
+func (c Category) Severe() bool {
+	switch c {
+	case CategoryFlatTyre, CategoryBrokenLock, CategoryBrakes, CategoryDamagedChildSeat:
+		return true
+	default:
+		return false
+	}
+}
+
+type Status string
+
+const (
+	StatusOpen     Status = "open"
+	StatusResolved Status = "resolved"
+)
+
+// Issue is a problem reported with a bike.
+type Issue struct {
+	ID          uuid.UUID     `db:"id"`
+	BikeID      uuid.UUID     `db:"bike_id"`
+	CustomerID  uuid.NullUUID `db:"customer_id"`
+	RideID      uuid.NullUUID `db:"ride_id"`
+	Category    Category      `db:"category"`
+	Description string        `db:"description"`
+	Status      Status        `db:"status"`
+	CreatedAt   time.Time     `db:"created_at"`
+	ResolvedAt  sql.NullTime  `db:"resolved_at"`
+}
+
+// Attachment is a photo attached to an issue. The photo itself is held in blob storage.
+type Attachment struct {
+	ID          uuid.UUID `db:"id"`
+	IssueID     uuid.UUID `db:"issue_id"`
+	BlobKey     string    `db:"blob_key"`
+	ContentType string    `db:"content_type"`
+	CreatedAt   time.Time `db:"created_at"`
+}
@@ -0,0 +1,84 @@
+package issue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrNotFound = errors.New("issue not found")
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create records a new open issue.
+func (r *Repository) Create(ctx context.Context, i *Issue) error {
+	return r.db.GetContext(ctx, i, createIssueQuery,
+		i.ID, i.BikeID, i.CustomerID, i.RideID, i.Category, i.Description, StatusOpen)
+}
+
+const createIssueQuery = `
+INSERT INTO issues (id, bike_id, customer_id, ride_id, category, description, status, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+RETURNING *
+`
+
+// GetByID fetches a single issue by its ID.
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (Issue, error) {
+	var i Issue
+	err := r.db.GetContext(ctx, &i, getByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Issue{}, ErrNotFound
+	}
+	return i, err
+}
+
+const getByIDQuery = `SELECT * FROM issues WHERE id = $1`
+
+// AddAttachment records a photo attached to an issue.
+func (r *Repository) AddAttachment(ctx context.Context, a *Attachment) error {
+	return r.db.GetContext(ctx, a, addAttachmentQuery, a.ID, a.IssueID, a.BlobKey, a.ContentType)
+}
+
+const addAttachmentQuery = `
+INSERT INTO issue_attachments (id, issue_id, blob_key, content_type, created_at)
+VALUES ($1, $2, $3, $4, now())
+RETURNING *
+`
+
+// CountAttachments returns how many photos are attached to an issue.
+func (r *Repository) CountAttachments(ctx context.Context, issueID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, countAttachmentsQuery, issueID)
+	return n, err
+}
+
+const countAttachmentsQuery = `SELECT count(*) FROM issue_attachments WHERE issue_id = $1`
+
+// HasOpen reports whether the bike has an open issue in the category.
+func (r *Repository) HasOpen(ctx context.Context, bikeID uuid.UUID, category Category) (bool, error) {
+	var open bool
+	err := r.db.GetContext(ctx, &open, hasOpenQuery, bikeID, category, StatusOpen)
+	return open, err
+}
+
+const hasOpenQuery = `
+SELECT EXISTS (SELECT 1 FROM issues WHERE bike_id = $1 AND category = $2 AND status = $3)
+`
+
+// GetByCustomer fetches the issues the customer has reported, newest first.
+func (r *Repository) GetByCustomer(ctx context.Context, customerID uuid.UUID) ([]Issue, error) {
+	var issues []Issue
+	err := r.db.SelectContext(ctx, &issues, getByCustomerQuery, customerID)
+	return issues, err
+}
+
+const getByCustomerQuery = `SELECT * FROM issues WHERE customer_id = $1 ORDER BY created_at DESC`
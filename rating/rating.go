@@ -0,0 +1,128 @@
+// Package rating collects riders' ratings of their rides.
+package rating
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MinScore and MaxScore bound the score a rider can give.
+	MinScore = 1
+	MaxScore = 5
+
+	// Window is how long after a ride ends it can be rated.
+	Window = 24 * time.Hour
+
+	// LowScore is the highest score which counts as a low rating.
+	LowScore = 2
+	// A bike given LowRatingThreshold low ratings within LowRatingPeriod is put in the maintenance queue.
+	LowRatingThreshold = 3
+	LowRatingPeriod    = 7 * 24 * time.Hour
+)
+
+type Tag string
+
+const (
+	TagDirty         Tag = "dirty"
+	TagHardToUnlock  Tag = "hard_to_unlock"
+	TagUncomfortable Tag = "uncomfortable"
+	TagLowBattery    Tag = "low_battery"
+	TagGreat         Tag = "great"
+)
+
+// Tags lists every tag riders can add to a rating.
+var Tags = []Tag{
+	TagDirty,
+	TagHardToUnlock,
+	TagUncomfortable,
+	TagLowBattery,
+	TagGreat,
+}
+
+// Valid reports whether t is a known tag.
+func (t Tag) Valid() bool {
+	for _, known := range Tags {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// TagList is a set of tags, stored as a JSON array.
+type TagList []Tag
+
+func (l TagList) Value() (driver.Value, error) {
+	if l == nil {
+		l = TagList{}
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+func (l *TagList) Scan(src any) error {
+	return scanJSON(src, l)
+}
+
+// TagCounts counts how often each tag was given, stored as a JSON object.
+type TagCounts map[Tag]int
+
+func (c *TagCounts) Scan(src any) error {
+	return scanJSON(src, c)
+}
+
+func scanJSON(src any, dst any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	default:
+		return errors.New("unsupported type for JSON column")
+	}
+}
+
+// Rating is a rider's rating of a completed ride. The bike's station is recorded when the rating is
+// given so ratings can be aggregated per station.
+type Rating struct {
+	ID         uuid.UUID     `db:"id"`
+	RideID     uuid.UUID     `db:"ride_id"`
+	CustomerID uuid.UUID     `db:"customer_id"`
+	BikeID     uuid.UUID     `db:"bike_id"`
+	StationID  uuid.NullUUID `db:"station_id"`
+	Score      int           `db:"score"`
+	Tags       TagList       `db:"tags"`
+	Comment    string        `db:"comment"`
+	CreatedAt  time.Time     `db:"created_at"`
+}
+
+// Aggregate summarises the ratings given to a bike or station.
+type Aggregate struct {
+	Ratings      int          `db:"ratings"`
+	AverageScore float64      `db:"average_score"`
+	LowRatings   int          `db:"low_ratings"`
+	LastRatedAt  sql.NullTime `db:"last_rated_at"`
+	TagCounts    TagCounts    `db:"tag_counts"`
+}
+
+// BikeAggregate is the aggregate of the ratings of rides on a bike.
+type BikeAggregate struct {
+	BikeID    uuid.UUID `db:"bike_id"`
+	BikeLabel string    `db:"bike_label"`
+	Aggregate
+}
+
+// StationAggregate is the aggregate of the ratings of rides on bikes from a station.
+type StationAggregate struct {
+	StationID   uuid.UUID `db:"station_id"`
+	StationName string    `db:"station_name"`
+	Aggregate
+}
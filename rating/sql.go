@@ -0,0 +1,123 @@
+package rating
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/dberr"
+)
+
+var ErrAlreadyRated = errors.New("ride already rated")
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create records a rating. Each ride can only be rated once.
+func (r *Repository) Create(ctx context.Context, rt *Rating) error {
+	err := r.db.GetContext(ctx, rt, createRatingQuery,
+		rt.ID, rt.RideID, rt.CustomerID, rt.BikeID, rt.Score, rt.Tags, rt.Comment)
+	if _, ok := dberr.UniqueViolation(err); ok {
+		return ErrAlreadyRated
+	}
+	return err
+}
+
+const createRatingQuery = `
+INSERT INTO ratings (id, ride_id, customer_id, bike_id, station_id, score, tags, comment, created_at)
+SELECT $1, $2, $3, $4, b.station_id, $5, $6, $7, now()
+FROM bikes b
+WHERE b.id = $4
+RETURNING *
+`
+
+// CountLowSince counts the low ratings given to a bike since the given time.
+func (r *Repository) CountLowSince(ctx context.Context, bikeID uuid.UUID, since time.Time) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, countLowSinceQuery, bikeID, since, LowScore)
+	return n, err
+}
+
+const countLowSinceQuery = `
+SELECT count(*) FROM ratings WHERE bike_id = $1 AND created_at >= $2 AND score <= $3
+`
+
+// GetBikeAggregates aggregates the ratings given between from and to, either of which may be nil,
+// for every rated bike. The lowest rated bikes come first.
+func (r *Repository) GetBikeAggregates(ctx context.Context, from, to *time.Time) ([]BikeAggregate, error) {
+	var aggs []BikeAggregate
+	err := r.db.SelectContext(ctx, &aggs, getBikeAggregatesQuery, from, to, LowScore)
+	return aggs, err
+}
+
+const getBikeAggregatesQuery = `
+WITH rated AS (
+    SELECT * FROM ratings
+    WHERE ($1::timestamptz IS NULL OR created_at >= $1)
+      AND ($2::timestamptz IS NULL OR created_at < $2)
+)
+SELECT b.id AS bike_id,
+       b.label AS bike_label,
+       count(*) AS ratings,
+       avg(rt.score)::float8 AS average_score,
+       count(*) FILTER (WHERE rt.score <= $3) AS low_ratings,
+       max(rt.created_at) AS last_rated_at,
+       COALESCE((SELECT jsonb_object_agg(tag, n)
+                 FROM (SELECT tag, count(*) AS n
+                       FROM rated t, jsonb_array_elements_text(t.tags) AS tag
+                       WHERE t.bike_id = b.id
+                       GROUP BY tag) tc), '{}') AS tag_counts
+FROM rated rt
+JOIN bikes b ON b.id = rt.bike_id
+GROUP BY b.id, b.label
+ORDER BY average_score, ratings DESC
+`
+
+// GetStationAggregates aggregates the ratings given between from and to, either of which may be nil,
+// for every station with rated rides. The lowest rated stations come first.
+func (r *Repository) GetStationAggregates(ctx context.Context, from, to *time.Time) ([]StationAggregate, error) {
+	var aggs []StationAggregate
+	err := r.db.SelectContext(ctx, &aggs, getStationAggregatesQuery, from, to, LowScore)
+	return aggs, err
+}
+
+const getStationAggregatesQuery = `
+WITH rated AS (
+    SELECT * FROM ratings
+    WHERE station_id IS NOT NULL
+      AND ($1::timestamptz IS NULL OR created_at >= $1)
+      AND ($2::timestamptz IS NULL OR created_at < $2)
+)
+SELECT s.id AS station_id,
+       s.name AS station_name,
+       count(*) AS ratings,
+       avg(rt.score)::float8 AS average_score,
+       count(*) FILTER (WHERE rt.score <= $3) AS low_ratings,
+       max(rt.created_at) AS last_rated_at,
+       COALESCE((SELECT jsonb_object_agg(tag, n)
+                 FROM (SELECT tag, count(*) AS n
+                       FROM rated t, jsonb_array_elements_text(t.tags) AS tag
+                       WHERE t.station_id = s.id
+                       GROUP BY tag) tc), '{}') AS tag_counts
+FROM rated rt
+JOIN stations s ON s.id = rt.station_id
+GROUP BY s.id, s.name
+ORDER BY average_score, ratings DESC
+`
+
+// GetByCustomer fetches the ratings the customer has given, newest first.
+func (r *Repository) GetByCustomer(ctx context.Context, customerID uuid.UUID) ([]Rating, error) {
+	var ratings []Rating
+	err := r.db.SelectContext(ctx, &ratings, getByCustomerQuery, customerID)
+	return ratings, err
+}
+
+const getByCustomerQuery = `SELECT * FROM ratings WHERE customer_id = $1 ORDER BY created_at DESC`
@@ -0,0 +1,94 @@
+// Package report computes fleet utilization and revenue reports from rides and bookings.
+package report
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxRange is the longest period a single report can cover.
+const MaxRange = 366 * 24 * time.Hour
+
+var ErrInvalidRange = errors.New("invalid report range")
+
+// Bucket is the period report lines are grouped by. Buckets start at midnight UTC and weeks start
+// on Monday.
+type Bucket string
+
+const (
+	BucketDay   Bucket = "day"
+	BucketWeek  Bucket = "week"
+	BucketMonth Bucket = "month"
+)
+
+// Valid reports whether b is a known bucket.
+func (b Bucket) Valid() bool {
+	switch b {
+	case BucketDay, BucketWeek, BucketMonth:
+		return true
+	}
+	return false
+}
+
+// interval is the bucket's length as a Postgres interval.
+func (b Bucket) interval() string {
+	return "1 " + string(b)
+}
+
+// Metrics are the usage figures for a bike or station over a bucket. Rides and bookings count
+// towards the bucket they start in. Revenue is in cents.
+type Metrics struct {
+	Rides         int     `db:"rides"`
+	RiddenHours   float64 `db:"ridden_hours"`
+	Bookings      int     `db:"bookings"`
+	BookedHours   float64 `db:"booked_hours"`
+	Cancellations int     `db:"cancellations"`
+	// NoShows are bookings which ended without a ride being taken under them
+	NoShows int `db:"no_shows"`
+	Revenue int `db:"revenue"`
+	// AvailableHours is the number of bike hours in the bucket, and IdleHours those which were
+	// neither booked nor ridden.
+	AvailableHours float64 `db:"available_hours"`
+	IdleHours      float64 `db:"idle_hours"`
+}
+
+// Utilization is the fraction of available hours which were booked or ridden.
+func (m Metrics) Utilization() float64 {
+	if m.AvailableHours <= 0 {
+		return 0
+	}
+	return (m.AvailableHours - m.IdleHours) / m.AvailableHours
+}
+
+// Add sums two sets of metrics.
+func (m Metrics) Add(o Metrics) Metrics {
+	return Metrics{
+		Rides:          m.Rides + o.Rides,
+		RiddenHours:    m.RiddenHours + o.RiddenHours,
+		Bookings:       m.Bookings + o.Bookings,
+		BookedHours:    m.BookedHours + o.BookedHours,
+		Cancellations:  m.Cancellations + o.Cancellations,
+		NoShows:        m.NoShows + o.NoShows,
+		Revenue:        m.Revenue + o.Revenue,
+		AvailableHours: m.AvailableHours + o.AvailableHours,
+		IdleHours:      m.IdleHours + o.IdleHours,
+	}
+}
+
+// Line is the usage of one bike or station over one bucket.
+type Line struct {
+	ID     uuid.UUID `db:"id"`
+	Name   string    `db:"name"`
+	Bucket time.Time `db:"bucket"`
+	Metrics
+}
+
+// ValidateRange checks a report's range is not empty and no longer than MaxRange.
+func ValidateRange(from, to time.Time) error {
+	if !from.Before(to) || to.Sub(from) > MaxRange {
+		return ErrInvalidRange
+	}
+	return nil
+}
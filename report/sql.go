@@ -0,0 +1,117 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// GetBikeUtilization reports the usage of every bike between from and to, with a line for each bike
+// and bucket. Retired bikes are only included if they were used in the period.
+func (r *Repository) GetBikeUtilization(ctx context.Context, from, to time.Time, bucket Bucket) ([]Line, error) {
+	var lines []Line
+	err := r.db.SelectContext(ctx, &lines, getBikeUtilizationQuery, from, to, string(bucket), bucket.interval())
+	return lines, err
+}
+
+// bikeUtilizationQuery attributes rides and bookings to the bucket they start in, so each is read
+// once using the started_at and start_time indexes.
+const bikeUtilizationQuery = `
+WITH buckets AS (
+    SELECT bucket,
+           extract(epoch FROM least(bucket + $4::interval, $2) - greatest(bucket, $1)) / 3600 AS hours
+    FROM generate_series(date_trunc($3, $1::timestamptz, 'UTC'), $2::timestamptz, $4::interval) AS bucket
+    WHERE bucket < $2
+),
+ride_usage AS (
+    SELECT bike_id,
+           date_trunc($3, started_at, 'UTC') AS bucket,
+           count(*) AS rides,
+           sum(extract(epoch FROM ended_at - started_at)) / 3600 AS ridden_hours,
+           COALESCE(sum(extract(epoch FROM ended_at - started_at)) FILTER (WHERE booking_id IS NULL), 0) / 3600
+               AS unbooked_hours,
+           sum(COALESCE(unlock_fee, 0) + COALESCE(time_charge, 0)) AS revenue
+    FROM rides
+    WHERE started_at >= $1 AND started_at < $2 AND ended_at IS NOT NULL
+    GROUP BY 1, 2
+),
+booking_usage AS (
+    SELECT bk.bike_id,
+           date_trunc($3, bk.start_time, 'UTC') AS bucket,
+           count(*) AS bookings,
+           COALESCE(sum(extract(epoch FROM bk.end_time - bk.start_time)) FILTER (WHERE bk.cancelled_at IS NULL), 0)
+               / 3600 AS booked_hours,
+           count(*) FILTER (WHERE bk.cancelled_at IS NOT NULL) AS cancellations,
+           count(*) FILTER (WHERE bk.cancelled_at IS NULL AND bk.end_time < now()
+                              AND NOT EXISTS (SELECT 1 FROM rides r WHERE r.booking_id = bk.id)) AS no_shows
+    FROM bookings bk
+    WHERE bk.start_time >= $1 AND bk.start_time < $2
+    GROUP BY 1, 2
+),
+fleet AS (
+    SELECT id, label, station_id
+    FROM bikes
+    WHERE state != 'retired'
+       OR id IN (SELECT bike_id FROM ride_usage UNION SELECT bike_id FROM booking_usage)
+)
+SELECT f.id,
+       f.label AS name,
+       f.station_id,
+       bu.bucket,
+       COALESCE(ru.rides, 0) AS rides,
+       COALESCE(ru.ridden_hours, 0)::float8 AS ridden_hours,
+       COALESCE(bo.bookings, 0) AS bookings,
+       COALESCE(bo.booked_hours, 0)::float8 AS booked_hours,
+       COALESCE(bo.cancellations, 0) AS cancellations,
+       COALESCE(bo.no_shows, 0) AS no_shows,
+       COALESCE(ru.revenue, 0) AS revenue,
+       bu.hours::float8 AS available_hours,
+       greatest(bu.hours - COALESCE(bo.booked_hours, 0) - COALESCE(ru.unbooked_hours, 0), 0)::float8 AS idle_hours
+FROM fleet f
+CROSS JOIN buckets bu
+LEFT JOIN ride_usage ru ON ru.bike_id = f.id AND ru.bucket = bu.bucket
+LEFT JOIN booking_usage bo ON bo.bike_id = f.id AND bo.bucket = bu.bucket
+`
+
+const getBikeUtilizationQuery = `
+SELECT id, name, bucket, rides, ridden_hours, bookings, booked_hours, cancellations, no_shows, revenue,
+       available_hours, idle_hours
+FROM (` + bikeUtilizationQuery + `) l
+ORDER BY name, bucket
+`
+
+// GetStationUtilization reports the usage of the bikes at each station between from and to, with a
+// line for each station and bucket. Bikes count towards the station they are currently assigned to.
+func (r *Repository) GetStationUtilization(ctx context.Context, from, to time.Time, bucket Bucket) ([]Line, error) {
+	var lines []Line
+	err := r.db.SelectContext(ctx, &lines, getStationUtilizationQuery, from, to, string(bucket), bucket.interval())
+	return lines, err
+}
+
+const getStationUtilizationQuery = `
+SELECT s.id,
+       s.name,
+       l.bucket,
+       sum(l.rides)::bigint AS rides,
+       sum(l.ridden_hours) AS ridden_hours,
+       sum(l.bookings)::bigint AS bookings,
+       sum(l.booked_hours) AS booked_hours,
+       sum(l.cancellations)::bigint AS cancellations,
+       sum(l.no_shows)::bigint AS no_shows,
+       sum(l.revenue)::bigint AS revenue,
+       sum(l.available_hours) AS available_hours,
+       sum(l.idle_hours) AS idle_hours
+FROM (` + bikeUtilizationQuery + `) l
+JOIN stations s ON s.id = l.station_id
+GROUP BY s.id, s.name, l.bucket
+ORDER BY s.name, l.bucket
+`
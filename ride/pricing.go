@@ -0,0 +1,58 @@
+package ride
+
+import (
+	"math"
+	"time"
+)
+
+// Pricing is the tariff for pay-as-you-go rides. Amounts are in cents.
+type Pricing struct {
+	UnlockFee int
+	PerMinute int
+}
+
+// DefaultPricing is the standard tariff.
+var DefaultPricing = Pricing{
+	UnlockFee: 100,
+	PerMinute: 15,
+}
+
+// For returns the tariff for a ride, which is p unless the bike's model has its own.
+func (p Pricing) For(e HistoryEntry) Pricing {
+	if e.ModelUnlockFee.Valid {
+		p.UnlockFee = int(e.ModelUnlockFee.Int32)
+	}
+	if e.ModelPerMinute.Valid {
+		p.PerMinute = int(e.ModelPerMinute.Int32)
+	}
+	return p
+}
+
+// BilledMinutes rounds a ride duration up to whole minutes.
+func BilledMinutes(d time.Duration) int {
+	return int(math.Ceil(d.Minutes()))
+}
+
+// Charge returns the unlock fee and time charge for a ride of the given number of minutes.
+func (p Pricing) Charge(minutes int) (unlockFee, timeCharge int) {
+	return p.UnlockFee, p.PerMinute * minutes
+}
+
+// Price works out the billed minutes and charges for a ride ending at endAt. A ride taken under a
+// booking is covered by the booking, so only time ridden after the booking ended is charged.
+func (p Pricing) Price(e HistoryEntry, endAt time.Time) (minutes, unlockFee, timeCharge int) {
+	minutes = BilledMinutes(endAt.Sub(e.StartedAt))
+	if !e.BookingID.Valid {
+		unlockFee, timeCharge = p.Charge(minutes)
+		return minutes, unlockFee, timeCharge
+	}
+
+	if e.BookingEndTime.Valid && endAt.After(e.BookingEndTime.Time) {
+		overtimeFrom := e.BookingEndTime.Time
+		if e.StartedAt.After(overtimeFrom) {
+			overtimeFrom = e.StartedAt
+		}
+		timeCharge = p.PerMinute * BilledMinutes(endAt.Sub(overtimeFrom))
+	}
+	return minutes, 0, timeCharge
+}
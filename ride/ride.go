@@ -7,6 +7,18 @@ import (
 	"github.com/google/uuid"
 )
 
+// EndReason records why a ride ended.
+type EndReason string
+
+const (
+	// EndedByCustomer is a ride the customer ended themselves.
+	EndedByCustomer EndReason = "customer"
+	// EndedMaxDuration is a ride closed because it was open longer than the maximum ride duration.
+	EndedMaxDuration EndReason = "max_duration"
+	// EndedLockedStationary is a ride closed because the lock reported the bike locked and not moving.
+	EndedLockedStationary EndReason = "locked_stationary"
+)
+
 type Ride struct {
 	ID              uuid.UUID     `db:"id"`
 	BikeID          uuid.UUID     `db:"bike_id"`
@@ -15,4 +27,85 @@ type Ride struct {
 	EndedAt         sql.NullTime  `db:"ended_at"`
 	ChargeCreatedAt sql.NullTime  `db:"charge_created_at"`
 	LockUserID      sql.NullInt64 `db:"lock_user_id"`
+	// BilledMinutes, UnlockFee and TimeCharge are set when the ride ends. Amounts are in cents.
+	BilledMinutes   sql.NullInt32  `db:"billed_minutes"`
+	UnlockFee       sql.NullInt32  `db:"unlock_fee"`
+	TimeCharge      sql.NullInt32  `db:"time_charge"`
+	StripeInvoiceID sql.NullString `db:"stripe_invoice_id"`
+	EndReason       *EndReason     `db:"end_reason"`
+	// BookingID is the booking the ride was taken under, if any
+	BookingID uuid.NullUUID `db:"booking_id"`
+}
+
+// HistoryEntry is a ride as shown in a customer's ride history.
+type HistoryEntry struct {
+	Ride
+	BikeLabel string `db:"bike_label"`
+	// BookingEndTime is the end of the booking the ride was taken under, if any
+	BookingEndTime sql.NullTime `db:"booking_end_time"`
+	// ModelUnlockFee and ModelPerMinute are the tariff of the bike's model, if it has its own. They
+	// are only fetched when a ride is priced.
+	ModelUnlockFee sql.NullInt32 `db:"model_unlock_fee"`
+	ModelPerMinute sql.NullInt32 `db:"model_per_minute"`
+}
+
+// Summary describes a ride which has ended and what it costs. Amounts are in cents.
+type Summary struct {
+	RideID        uuid.UUID
+	BikeID        uuid.UUID
+	BikeLabel     string
+	CustomerID    uuid.UUID
+	StartedAt     time.Time
+	EndedAt       time.Time
+	BilledMinutes int
+	UnlockFee     int
+	TimeCharge    int
+	EndReason     EndReason
+}
+
+// Price is the total price of the ride in cents.
+func (s Summary) Price() int {
+	return s.UnlockFee + s.TimeCharge
+}
+
+func (e HistoryEntry) summary() Summary {
+	return Summary{
+		RideID:        e.ID,
+		BikeID:        e.BikeID,
+		BikeLabel:     e.BikeLabel,
+		CustomerID:    e.CustomerID,
+		StartedAt:     e.StartedAt,
+		EndedAt:       e.EndedAt.Time,
+		BilledMinutes: int(e.BilledMinutes.Int32),
+		UnlockFee:     int(e.UnlockFee.Int32),
+		TimeCharge:    int(e.TimeCharge.Int32),
+		EndReason:     reason(e.EndReason),
+	}
+}
+
+func reason(r *EndReason) EndReason {
+	if r == nil {
+		return EndedByCustomer
+	}
+	return *r
+}
+
+// AbandonPolicy decides when a ride left open is closed automatically and how it is charged.
+type AbandonPolicy struct {
+	// MaxDuration is the longest a ride may stay open.
+	MaxDuration time.Duration
+	// LockedIdle is how long the lock must report the bike locked and stationary before the ride is closed.
+	LockedIdle time.Duration
+	// StationaryRadius is how far, in metres, the bike may drift while locked and still count as stationary.
+	StationaryRadius float64
+	// MaxBilledMinutes caps the minutes charged for a ride which is closed automatically.
+	MaxBilledMinutes int
+}
+
+// Abandoned is a ride in progress which should be closed automatically.
+type Abandoned struct {
+	HistoryEntry
+	// EndAt is when the ride is considered to have ended.
+	EndAt  time.Time `db:"end_at"`
+	Reason EndReason `db:"reason"`
 }
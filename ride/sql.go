@@ -4,42 +4,71 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/dberr"
 )
 
 type Repository struct {
-	db *sqlx.DB
+	db      *sqlx.DB
+	pricing Pricing
 }
 
 func NewRepository(db *sqlx.DB) *Repository {
 	return &Repository{
-		db: db,
+		db:      db,
+		pricing: DefaultPricing,
 	}
 }
 
-var ErrRideInProgress = errors.New("ride in progress")
+var (
+	ErrRideInProgress = errors.New("ride in progress")
+	ErrNotFound       = errors.New("ride not found")
+	ErrNoActiveRide   = errors.New("no ride in progress")
+	ErrAlreadyEnded   = errors.New("ride already ended")
+	// ErrBikeInUse is returned when the bike already has a ride in progress.
+	ErrBikeInUse = errors.New("bike already has a ride in progress")
+	// ErrCustomerHasActiveRide is returned when the customer is already riding another bike.
+	ErrCustomerHasActiveRide = errors.New("customer already has a ride in progress")
+)
+
+// Names of the partial unique indexes which allow only one active ride per bike and per customer.
+const (
+	activeBikeIndex     = "rides_active_bike_idx"
+	activeCustomerIndex = "rides_active_customer_idx"
+)
 
-func (r *Repository) StartRide(ctx context.Context, bikeID, customerID uuid.UUID) (Ride, error) {
+// StartRide starts a ride for the customer on the bike. If the bike already has a ride in progress
+// the error can be inspected with CustomerFromRideInProgressError to find out who is riding it.
+//
+// bookingID links the ride to the customer's booking of the bike, so the ride is priced under it.
+func (r *Repository) StartRide(ctx context.Context, bikeID, customerID uuid.UUID, bookingID *uuid.UUID) (Ride, error) {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return Ride{}, err
 	}
 	defer tx.Rollback()
 
-	var rides uuid.UUID
-	err = tx.Get(&rides, verifyNoRides, bikeID)
+	var rider uuid.UUID
+	err = tx.GetContext(ctx, &rider, verifyNoRides, bikeID)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return Ride{}, err
 	}
 
-	if rides != uuid.Nil {
-		return Ride{}, &rideInProgressError{customerID: customerID}
+	if rider != uuid.Nil {
+		return Ride{}, &rideInProgressError{customerID: rider}
 	}
 
+	// The unique indexes on active rides guard against a concurrent start slipping past the check above
 	var ride Ride
-	err = r.db.Get(&ride, startRideQuery, uuid.New(), bikeID, customerID)
+	err = tx.GetContext(ctx, &ride, startRideQuery, uuid.New(), bikeID, customerID, bookingID)
+	if index, ok := dberr.UniqueViolation(err); ok {
+		tx.Rollback()
+		return Ride{}, r.conflictError(ctx, bikeID, index, err)
+	}
 	if err != nil {
 		return Ride{}, err
 	}
@@ -48,21 +77,404 @@ func (r *Repository) StartRide(ctx context.Context, bikeID, customerID uuid.UUID
 	return ride, err
 }
 
+// conflictError works out why starting a ride violated a unique index.
+func (r *Repository) conflictError(ctx context.Context, bikeID uuid.UUID, index string, cause error) error {
+	switch index {
+	case activeBikeIndex, activeCustomerIndex:
+	default:
+		return cause
+	}
+
+	var rider uuid.UUID
+	err := r.db.GetContext(ctx, &rider, verifyNoRides, bikeID)
+	if err == nil {
+		return &rideInProgressError{customerID: rider}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if index == activeCustomerIndex {
+		return ErrCustomerHasActiveRide
+	}
+	return ErrBikeInUse
+}
+
 const verifyNoRides = `SELECT customer_id FROM rides WHERE bike_id = $1 AND ended_at IS NULL`
 
 const startRideQuery = `
-INSERT INTO rides (id, bike_id, customer_id, started_at)
-VALUES ($1, $2, $3, now())
+INSERT INTO rides (id, bike_id, customer_id, started_at, booking_id)
+VALUES ($1, $2, $3, now(), $4)
 RETURNING *
 `
 
-func (r *Repository) EndRide(ctx context.Context, userID uuid.UUID) (int, error) {
-	var i int
-	err := r.db.GetContext(ctx, &i, endRideQuery, userID)
-	return i, err
+// CancelRide removes a ride which never got under way, e.g. because the lock did not unlock.
+func (r *Repository) CancelRide(ctx context.Context, rideID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, cancelRideQuery, rideID)
+	return err
+}
+
+const cancelRideQuery = `
+WITH detached AS (
+    UPDATE bike_positions SET ride_id = NULL WHERE ride_id = $1
+)
+DELETE FROM rides WHERE id = $1 AND ended_at IS NULL
+`
+
+// EndRide ends the customer's ride in progress and prices it.
+//
+// Ending a ride is idempotent so the app can safely retry. If rideID is given and that ride has
+// already ended, or no rideID is given and the customer's last ride ended within
+// endRideRetryWindow, the existing summary is returned along with ErrAlreadyEnded.
+func (r *Repository) EndRide(ctx context.Context, customerID uuid.UUID, rideID *uuid.UUID) (Summary, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer tx.Rollback()
+
+	var active HistoryEntry
+	err = tx.GetContext(ctx, &active, getActiveRideForUpdateQuery, customerID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && rideID != nil && *rideID != active.ID) {
+		return r.endedRide(ctx, customerID, rideID)
+	}
+	if err != nil {
+		return Summary{}, err
+	}
+
+	now := time.Now()
+	minutes, unlockFee, timeCharge := r.pricing.For(active).Price(active, now)
+
+	err = tx.GetContext(ctx, &active.Ride, endRideQuery, active.ID, now, minutes, unlockFee, timeCharge,
+		EndedByCustomer)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return active.summary(), tx.Commit()
+}
+
+// endRideRetryWindow is how long after a ride ends a retried end request without a ride ID is
+// treated as a retry rather than an error.
+const endRideRetryWindow = 10 * time.Minute
+
+// endedRide finds the ride a retried end request refers to.
+func (r *Repository) endedRide(ctx context.Context, customerID uuid.UUID, rideID *uuid.UUID) (Summary, error) {
+	var ended HistoryEntry
+	var err error
+	if rideID != nil {
+		err = r.db.GetContext(ctx, &ended, getCustomerRideQuery, customerID, *rideID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return Summary{}, ErrNotFound
+		}
+	} else {
+		err = r.db.GetContext(ctx, &ended, getRecentlyEndedRideQuery, customerID, time.Now().Add(-endRideRetryWindow))
+		if errors.Is(err, sql.ErrNoRows) {
+			return Summary{}, ErrNoActiveRide
+		}
+	}
+	if err != nil {
+		return Summary{}, err
+	}
+	if !ended.EndedAt.Valid {
+		// The requested ride is still in progress but isn't the customer's active ride, which can't happen
+		return Summary{}, ErrNoActiveRide
+	}
+
+	return ended.summary(), ErrAlreadyEnded
+}
+
+const getActiveRideForUpdateQuery = `
+SELECT r.*, b.label AS bike_label, bk.end_time AS booking_end_time,
+       m.unlock_fee AS model_unlock_fee, m.per_minute AS model_per_minute
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+LEFT JOIN bike_models m ON m.id = b.model_id
+LEFT JOIN bookings bk ON bk.id = r.booking_id
+WHERE r.customer_id = $1 AND r.ended_at IS NULL
+FOR UPDATE OF r
+`
+
+const endRideQuery = `
+UPDATE rides SET ended_at = $2, billed_minutes = $3, unlock_fee = $4, time_charge = $5, end_reason = $6
+WHERE id = $1
+RETURNING *
+`
+
+const getCustomerRideQuery = `
+SELECT r.*, b.label AS bike_label
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+WHERE r.customer_id = $1 AND r.id = $2
+`
+
+const getRecentlyEndedRideQuery = `
+SELECT r.*, b.label AS bike_label
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+WHERE r.customer_id = $1 AND r.ended_at >= $2
+ORDER BY r.ended_at DESC
+LIMIT 1
+`
+
+// ClaimCharge marks the ride as being charged. It returns false if the ride is being charged or has
+// been charged already, so a ride is only ever invoiced once. If an earlier attempt to charge the
+// ride got as far as creating an invoice, the invoice's ID is returned so it can be finished.
+func (r *Repository) ClaimCharge(ctx context.Context, rideID uuid.UUID) (string, bool, error) {
+	var invoiceID sql.NullString
+	err := r.db.GetContext(ctx, &invoiceID, claimChargeQuery, rideID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return invoiceID.String, true, nil
+}
+
+const claimChargeQuery = `
+UPDATE rides SET charge_created_at = now()
+WHERE id = $1 AND charge_created_at IS NULL
+RETURNING stripe_invoice_id
+`
+
+// ReleaseCharge gives up the claim on charging a ride which couldn't be charged, so the next attempt
+// can claim it again.
+func (r *Repository) ReleaseCharge(ctx context.Context, rideID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, releaseChargeQuery, rideID)
+	return err
+}
+
+const releaseChargeQuery = `UPDATE rides SET charge_created_at = NULL WHERE id = $1`
+
+// SetInvoice records the Stripe invoice a ride was charged on.
+func (r *Repository) SetInvoice(ctx context.Context, rideID uuid.UUID, invoiceID string) error {
+	_, err := r.db.ExecContext(ctx, setInvoiceQuery, rideID, invoiceID)
+	return err
+}
+
+const setInvoiceQuery = `UPDATE rides SET stripe_invoice_id = $2 WHERE id = $1`
+
+// GetUncharged fetches the rides which ended before t with something to pay but haven't been charged.
+func (r *Repository) GetUncharged(ctx context.Context, t time.Time) ([]Summary, error) {
+	var rides []HistoryEntry
+	if err := r.db.SelectContext(ctx, &rides, getUnchargedQuery, t); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]Summary, 0, len(rides))
+	for _, e := range rides {
+		summaries = append(summaries, e.summary())
+	}
+	return summaries, nil
+}
+
+const getUnchargedQuery = `
+SELECT r.*, b.label AS bike_label
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+WHERE r.ended_at < $1
+  AND r.charge_created_at IS NULL
+  AND coalesce(r.unlock_fee, 0) + coalesce(r.time_charge, 0) > 0
+ORDER BY r.ended_at
+`
+
+// FindAbandoned finds rides in progress which have been open longer than the policy allows, or whose
+// lock has reported the bike locked and stationary for longer than the policy's idle time.
+func (r *Repository) FindAbandoned(ctx context.Context, policy AbandonPolicy) ([]Abandoned, error) {
+	now := time.Now()
+	var rides []Abandoned
+	err := r.db.SelectContext(ctx, &rides, findAbandonedQuery,
+		now.Add(-policy.MaxDuration), now.Add(-policy.LockedIdle), policy.StationaryRadius, now)
+	return rides, err
+}
+
+// findAbandonedQuery finds rides over the maximum duration, and rides whose lock has reported locked
+// continuously since before the idle cutoff. locked_at is the first locked reading after the lock
+// was last seen unlocked.
+const findAbandonedQuery = `
+WITH locked AS (
+    SELECT r.id, r.bike_id,
+        (SELECT min(t.recorded_at) FROM bike_telemetry t
+         WHERE t.bike_id = r.bike_id
+           AND t.lock_state = 'locked'
+           AND t.recorded_at > GREATEST(r.started_at, COALESCE((
+               SELECT max(u.recorded_at) FROM bike_telemetry u
+               WHERE u.bike_id = r.bike_id AND u.lock_state <> 'locked'
+           ), r.started_at))
+        ) AS locked_at
+    FROM rides r
+    WHERE r.ended_at IS NULL
+), idle AS (
+    SELECT l.id, l.locked_at, l.locked_at <= $2 AND NOT EXISTS (
+        SELECT 1 FROM bike_positions p
+        JOIN bikes b ON b.id = p.bike_id
+        WHERE p.bike_id = l.bike_id
+          AND p.recorded_at > l.locked_at
+          AND NOT ST_DWithin(p.location, b.location, $3)
+    ) AS stationary
+    FROM locked l
+)
+SELECT r.*, b.label AS bike_label,
+    CASE WHEN i.stationary THEN i.locked_at ELSE $4 END AS end_at,
+    CASE WHEN i.stationary THEN 'locked_stationary' ELSE 'max_duration' END AS reason
+FROM rides r
+JOIN idle i ON i.id = r.id
+JOIN bikes b ON b.id = r.bike_id
+WHERE i.stationary OR r.started_at < $1
+`
+
+// CloseAbandoned ends a ride which was abandoned, charging it with the minutes capped by the policy.
+// It returns ErrAlreadyEnded if the ride ended in the meantime.
+func (r *Repository) CloseAbandoned(ctx context.Context, a Abandoned, policy AbandonPolicy) (Summary, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer tx.Rollback()
+
+	var ride HistoryEntry
+	err = tx.GetContext(ctx, &ride, getRideForUpdateQuery, a.ID)
+	if err != nil {
+		return Summary{}, err
+	}
+	if ride.EndedAt.Valid {
+		return ride.summary(), ErrAlreadyEnded
+	}
+
+	pricing := r.pricing.For(ride)
+	minutes, unlockFee, timeCharge := pricing.Price(ride, a.EndAt)
+	minutes = min(minutes, policy.MaxBilledMinutes)
+	timeCharge = min(timeCharge, policy.MaxBilledMinutes*pricing.PerMinute)
+
+	err = tx.GetContext(ctx, &ride.Ride, endRideQuery, ride.ID, a.EndAt, minutes, unlockFee, timeCharge, a.Reason)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return ride.summary(), tx.Commit()
+}
+
+const getRideForUpdateQuery = `
+SELECT r.*, b.label AS bike_label, bk.end_time AS booking_end_time,
+       m.unlock_fee AS model_unlock_fee, m.per_minute AS model_per_minute
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+LEFT JOIN bike_models m ON m.id = b.model_id
+LEFT JOIN bookings bk ON bk.id = r.booking_id
+WHERE r.id = $1
+FOR UPDATE OF r
+`
+
+// GetRide fetches a single ride by its ID.
+func (r *Repository) GetRide(ctx context.Context, id uuid.UUID) (HistoryEntry, error) {
+	var ride HistoryEntry
+	err := r.db.GetContext(ctx, &ride, getRideQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return HistoryEntry{}, ErrNotFound
+	}
+	return ride, err
 }
 
-const endRideQuery = `UPDATE rides SET ended_at = now() WHERE customer_id = $1 AND ended_at IS NULL RETURNING ceil(extract(epoch FROM (ended_at - started_at))/60)::int as diff`
+const getRideQuery = `
+SELECT r.*, b.label AS bike_label
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+WHERE r.id = $1
+`
+
+// GetRecentRideOnBike fetches the customer's ride on the bike which is in progress or ended at or
+// after since. It returns ErrNotFound if there isn't one.
+func (r *Repository) GetRecentRideOnBike(ctx context.Context, customerID, bikeID uuid.UUID,
+	since time.Time) (Ride, error) {
+	var ride Ride
+	err := r.db.GetContext(ctx, &ride, getRecentRideOnBikeQuery, customerID, bikeID, since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Ride{}, ErrNotFound
+	}
+	return ride, err
+}
+
+const getRecentRideOnBikeQuery = `
+SELECT * FROM rides
+WHERE customer_id = $1 AND bike_id = $2 AND (ended_at IS NULL OR ended_at >= $3)
+ORDER BY started_at DESC
+LIMIT 1
+`
+
+// GetActiveRidesForBikes fetches the rides in progress on any of the bikes.
+func (r *Repository) GetActiveRidesForBikes(ctx context.Context, bikeIDs []uuid.UUID) ([]Ride, error) {
+	var rides []Ride
+	err := r.db.SelectContext(ctx, &rides, getActiveRidesForBikesQuery, bikeIDs)
+	return rides, err
+}
+
+const getActiveRidesForBikesQuery = `SELECT * FROM rides WHERE bike_id = ANY($1) AND ended_at IS NULL`
+
+// GetLastRides fetches the most recent ride on each bike which has been ridden, keyed by bike.
+func (r *Repository) GetLastRides(ctx context.Context) (map[uuid.UUID]Ride, error) {
+	var rides []Ride
+	if err := r.db.SelectContext(ctx, &rides, getLastRidesQuery); err != nil {
+		return nil, err
+	}
+
+	last := make(map[uuid.UUID]Ride, len(rides))
+	for _, ride := range rides {
+		last[ride.BikeID] = ride
+	}
+	return last, nil
+}
+
+const getLastRidesQuery = `
+SELECT DISTINCT ON (bike_id) *
+FROM rides
+ORDER BY bike_id, started_at DESC
+`
+
+// GetStartedBetween fetches the rides which started in [from, to), in the order they started.
+func (r *Repository) GetStartedBetween(ctx context.Context, from, to time.Time) ([]HistoryEntry, error) {
+	var rides []HistoryEntry
+	err := r.db.SelectContext(ctx, &rides, getStartedBetweenQuery, from, to)
+	return rides, err
+}
+
+const getStartedBetweenQuery = `
+SELECT r.*, b.label AS bike_label
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+WHERE r.started_at >= $1 AND r.started_at < $2
+ORDER BY r.started_at, r.id
+`
+
+// GetEndedBetween fetches the rides which ended in [from, to), in the order they ended.
+func (r *Repository) GetEndedBetween(ctx context.Context, from, to time.Time) ([]HistoryEntry, error) {
+	var rides []HistoryEntry
+	err := r.db.SelectContext(ctx, &rides, getEndedBetweenQuery, from, to)
+	return rides, err
+}
+
+const getEndedBetweenQuery = `
+SELECT r.*, b.label AS bike_label
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+WHERE r.ended_at >= $1 AND r.ended_at < $2
+ORDER BY r.ended_at, r.id
+`
+
+// GetHistory fetches the rides taken by a customer, most recent first.
+func (r *Repository) GetHistory(ctx context.Context, customerID uuid.UUID) ([]HistoryEntry, error) {
+	var rides []HistoryEntry
+	err := r.db.SelectContext(ctx, &rides, getHistoryQuery, customerID)
+	return rides, err
+}
+
+const getHistoryQuery = `
+SELECT r.*, b.label AS bike_label
+FROM rides r
+JOIN bikes b ON b.id = r.bike_id
+WHERE r.customer_id = $1
+ORDER BY r.started_at DESC
+`
 
 type rideInProgressError struct {
 	customerID uuid.UUID
@@ -72,10 +484,16 @@ func (e *rideInProgressError) Error() string {
 	return "ride in progress for customer " + e.customerID.String()
 }
 
+func (e *rideInProgressError) Unwrap() error {
+	return ErrBikeInUse
+}
+
+// CustomerFromRideInProgressError returns the customer riding the bike if err reports that the
+// bike already has a ride in progress.
 func CustomerFromRideInProgressError(err error) (uuid.UUID, bool) {
-	riperr, ok := err.(*rideInProgressError)
-	if ok {
-		return riperr.customerID, ok
+	var riperr *rideInProgressError
+	if errors.As(err, &riperr) {
+		return riperr.customerID, true
 	}
 	return uuid.UUID{}, false
 }
@@ -0,0 +1,124 @@
+package ride
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/bike"
+	"github.com/semanticallynull/bookingengine-backend/internal/testdb"
+)
+
+// starters is how many rides are started at once in the concurrency tests.
+const starters = 8
+
+func TestStartRideConcurrentSameBike(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	rr := NewRepository(db)
+
+	b := testdb.CreateBike(t, db, "CARGO-1")
+	customers := make([]uuid.UUID, starters)
+	for i := range customers {
+		customers[i] = testdb.CreateCustomer(t, db, fmt.Sprintf("auth0|rider%d", i)).ID
+	}
+
+	errs := startConcurrently(func(i int) error {
+		_, err := rr.StartRide(ctx, b.ID, customers[i], nil)
+		return err
+	})
+
+	winner := checkOneStarted(t, errs, ErrBikeInUse)
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		rider, ok := CustomerFromRideInProgressError(err)
+		if !ok || rider != customers[winner] {
+			t.Errorf("start %d: rider = %v, %v, want %v", i, rider, ok, customers[winner])
+		}
+	}
+
+	rides, err := rr.GetActiveRidesForBikes(ctx, []uuid.UUID{b.ID})
+	if err != nil {
+		t.Fatalf("GetActiveRidesForBikes() error = %v", err)
+	}
+	if len(rides) != 1 || rides[0].CustomerID != customers[winner] {
+		t.Errorf("active rides = %v, want one for %v", rides, customers[winner])
+	}
+}
+
+func TestStartRideConcurrentSameCustomer(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	rr := NewRepository(db)
+
+	bikes := make([]bike.Bike, starters)
+	for i := range bikes {
+		bikes[i] = testdb.CreateBike(t, db, fmt.Sprintf("CARGO-%d", i))
+	}
+	customerID := testdb.CreateCustomer(t, db, "auth0|rider").ID
+
+	errs := startConcurrently(func(i int) error {
+		_, err := rr.StartRide(ctx, bikes[i].ID, customerID, nil)
+		return err
+	})
+
+	winner := checkOneStarted(t, errs, ErrCustomerHasActiveRide)
+
+	ids := make([]uuid.UUID, 0, len(bikes))
+	for _, b := range bikes {
+		ids = append(ids, b.ID)
+	}
+	rides, err := rr.GetActiveRidesForBikes(ctx, ids)
+	if err != nil {
+		t.Fatalf("GetActiveRidesForBikes() error = %v", err)
+	}
+	if len(rides) != 1 || rides[0].BikeID != bikes[winner].ID {
+		t.Errorf("active rides = %v, want one on %v", rides, bikes[winner].ID)
+	}
+}
+
+// startConcurrently calls start from starters goroutines at once and returns their errors.
+func startConcurrently(start func(i int) error) []error {
+	errs := make([]error, starters)
+	ready := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range starters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ready
+			errs[i] = start(i)
+		}()
+	}
+	close(ready)
+	wg.Wait()
+	return errs
+}
+
+// checkOneStarted checks that exactly one start succeeded and the others failed with want. It
+// returns the index of the start which succeeded.
+func checkOneStarted(t *testing.T, errs []error, want error) int {
+	t.Helper()
+
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil && winner >= 0:
+			t.Errorf("starts %d and %d both succeeded", winner, i)
+		case err == nil:
+			winner = i
+		case !errors.Is(err, want):
+			t.Errorf("start %d: error = %v, want %v", i, err, want)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("no start succeeded")
+	}
+	return winner
+}
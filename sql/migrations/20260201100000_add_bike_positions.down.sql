@@ -0,0 +1,2 @@
+ALTER TABLE bikes DROP COLUMN IF EXISTS location_updated_at;
+DROP TABLE IF EXISTS bike_positions;
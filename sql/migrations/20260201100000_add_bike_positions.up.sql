@@ -0,0 +1,13 @@
+CREATE TABLE bike_positions (
+    id          uuid                     NOT NULL PRIMARY KEY,
+    bike_id     uuid                     NOT NULL REFERENCES bikes(id),
+    ride_id     uuid                     REFERENCES rides(id),
+    recorded_at timestamp with time zone NOT NULL,
+    location    point                    NOT NULL,
+    accuracy    double precision
+);
+
+CREATE INDEX bike_positions_bike_id_recorded_at_idx ON bike_positions (bike_id, recorded_at);
+CREATE INDEX bike_positions_ride_id_idx ON bike_positions (ride_id, recorded_at);
+
+ALTER TABLE bikes ADD COLUMN location_updated_at timestamp with time zone;
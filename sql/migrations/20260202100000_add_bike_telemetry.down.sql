@@ -0,0 +1,7 @@
+ALTER TABLE bikes
+DROP COLUMN IF EXISTS telemetry_updated_at,
+DROP COLUMN IF EXISTS signal_strength,
+DROP COLUMN IF EXISTS lock_state,
+DROP COLUMN IF EXISTS battery_voltage,
+DROP COLUMN IF EXISTS battery_model;
+DROP TABLE IF EXISTS bike_telemetry;
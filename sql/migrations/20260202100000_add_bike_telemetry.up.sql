@@ -0,0 +1,17 @@
+CREATE TABLE bike_telemetry (
+    id              uuid                     NOT NULL PRIMARY KEY,
+    bike_id         uuid                     NOT NULL REFERENCES bikes(id),
+    recorded_at     timestamp with time zone NOT NULL,
+    battery_voltage integer,
+    lock_state      text                     NOT NULL,
+    signal_strength integer
+);
+
+CREATE INDEX bike_telemetry_bike_id_recorded_at_idx ON bike_telemetry (bike_id, recorded_at);
+
+ALTER TABLE bikes
+ADD COLUMN battery_model text,
+ADD COLUMN battery_voltage integer,
+ADD COLUMN lock_state text,
+ADD COLUMN signal_strength integer,
+ADD COLUMN telemetry_updated_at timestamp with time zone;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS rides_active_customer_idx;
+DROP INDEX IF EXISTS rides_active_bike_idx;
@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX rides_active_bike_idx ON rides (bike_id) WHERE ended_at IS NULL;
+CREATE UNIQUE INDEX rides_active_customer_idx ON rides (customer_id) WHERE ended_at IS NULL;
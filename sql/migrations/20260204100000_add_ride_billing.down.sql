@@ -0,0 +1,5 @@
+ALTER TABLE rides
+DROP COLUMN IF EXISTS stripe_invoice_id,
+DROP COLUMN IF EXISTS time_charge,
+DROP COLUMN IF EXISTS unlock_fee,
+DROP COLUMN IF EXISTS billed_minutes;
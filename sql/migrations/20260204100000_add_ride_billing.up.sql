@@ -0,0 +1,5 @@
+ALTER TABLE rides
+ADD COLUMN billed_minutes integer,
+ADD COLUMN unlock_fee integer,
+ADD COLUMN time_charge integer,
+ADD COLUMN stripe_invoice_id text;
@@ -0,0 +1 @@
+ALTER TABLE rides DROP COLUMN IF EXISTS end_reason;
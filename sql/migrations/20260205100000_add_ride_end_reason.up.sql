@@ -0,0 +1,2 @@
+ALTER TABLE rides ADD COLUMN end_reason text;
+UPDATE rides SET end_reason = 'customer' WHERE ended_at IS NOT NULL;
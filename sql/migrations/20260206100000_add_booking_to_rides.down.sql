@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS rides_booking_id_idx;
+ALTER TABLE rides DROP COLUMN IF EXISTS booking_id;
@@ -0,0 +1,2 @@
+ALTER TABLE rides ADD COLUMN booking_id uuid REFERENCES bookings(id);
+CREATE INDEX rides_booking_id_idx ON rides (booking_id);
@@ -0,0 +1,3 @@
+ALTER TABLE bikes DROP COLUMN IF EXISTS in_service;
+DROP TABLE IF EXISTS issue_attachments;
+DROP TABLE IF EXISTS issues;
@@ -0,0 +1,26 @@
+CREATE TABLE issues (
+    id          uuid                     NOT NULL PRIMARY KEY,
+    bike_id     uuid                     NOT NULL REFERENCES bikes(id),
+    customer_id uuid                     REFERENCES customers(id),
+    ride_id     uuid                     REFERENCES rides(id),
+    category    text                     NOT NULL,
+    description text                     NOT NULL DEFAULT '',
+    status      text                     NOT NULL,
+    created_at  timestamp with time zone NOT NULL DEFAULT now(),
+    resolved_at timestamp with time zone
+);
+
+CREATE INDEX issues_bike_id_idx ON issues (bike_id);
+CREATE INDEX issues_open_idx ON issues (created_at) WHERE status = 'open';
+
+CREATE TABLE issue_attachments (
+    id           uuid                     NOT NULL PRIMARY KEY,
+    issue_id     uuid                     NOT NULL REFERENCES issues(id),
+    blob_key     text                     NOT NULL,
+    content_type text                     NOT NULL,
+    created_at   timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX issue_attachments_issue_id_idx ON issue_attachments (issue_id);
+
+ALTER TABLE bikes ADD COLUMN in_service boolean NOT NULL DEFAULT true;
@@ -0,0 +1,14 @@
+CREATE TABLE ratings (
+    id          uuid                     NOT NULL PRIMARY KEY,
+    ride_id     uuid                     NOT NULL UNIQUE REFERENCES rides(id),
+    customer_id uuid                     NOT NULL REFERENCES customers(id),
+    bike_id     uuid                     NOT NULL REFERENCES bikes(id),
+    station_id  uuid                     REFERENCES stations(id),
+    score       smallint                 NOT NULL CHECK (score BETWEEN 1 AND 5),
+    tags        jsonb                    NOT NULL DEFAULT '[]',
+    comment     text                     NOT NULL DEFAULT '',
+    created_at  timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ratings_bike_id_created_at_idx ON ratings (bike_id, created_at);
+CREATE INDEX ratings_station_id_created_at_idx ON ratings (station_id, created_at);
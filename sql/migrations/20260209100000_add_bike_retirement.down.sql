@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS bikes_imei;
+
+ALTER TABLE bikes DROP COLUMN IF EXISTS retired_at;
@@ -0,0 +1,3 @@
+ALTER TABLE bikes ADD COLUMN retired_at timestamp with time zone;
+
+CREATE UNIQUE INDEX bikes_imei ON bikes (imei);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS bike_state_changes;
+
+ALTER TABLE bikes ADD COLUMN in_service boolean NOT NULL DEFAULT true;
+ALTER TABLE bikes ADD COLUMN retired_at timestamp with time zone;
+
+UPDATE bikes SET in_service = false WHERE state IN ('maintenance', 'out_of_service', 'lost');
+UPDATE bikes SET retired_at = COALESCE(state_changed_at, now()) WHERE state = 'retired';
+
+DROP INDEX IF EXISTS bikes_state_idx;
+ALTER TABLE bikes DROP COLUMN state_changed_at;
+ALTER TABLE bikes DROP COLUMN state_reason;
+ALTER TABLE bikes DROP COLUMN state;
@@ -0,0 +1,23 @@
+ALTER TABLE bikes ADD COLUMN state text NOT NULL DEFAULT 'active';
+ALTER TABLE bikes ADD COLUMN state_reason text;
+ALTER TABLE bikes ADD COLUMN state_changed_at timestamp with time zone;
+
+UPDATE bikes SET state = 'out_of_service', state_changed_at = now() WHERE NOT in_service;
+UPDATE bikes SET state = 'retired', state_changed_at = retired_at WHERE retired_at IS NOT NULL;
+
+ALTER TABLE bikes DROP COLUMN in_service;
+ALTER TABLE bikes DROP COLUMN retired_at;
+
+CREATE INDEX bikes_state_idx ON bikes (state);
+
+CREATE TABLE bike_state_changes (
+    id         uuid                     NOT NULL PRIMARY KEY,
+    bike_id    uuid                     NOT NULL REFERENCES bikes(id),
+    from_state text                     NOT NULL,
+    to_state   text                     NOT NULL,
+    reason     text,
+    changed_by text                     NOT NULL,
+    changed_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX bike_state_changes_bike_id_idx ON bike_state_changes (bike_id, changed_at);
@@ -0,0 +1,19 @@
+DROP INDEX IF EXISTS bike_positions_location_idx;
+DROP INDEX IF EXISTS stations_location_idx;
+DROP INDEX IF EXISTS bikes_location_idx;
+
+ALTER TABLE bike_positions
+    ALTER COLUMN location TYPE point
+    USING point(ST_Y(location::geometry), ST_X(location::geometry));
+
+ALTER TABLE stations
+    ALTER COLUMN location TYPE point
+    USING point(ST_Y(location::geometry), ST_X(location::geometry));
+
+ALTER TABLE bikes
+    ALTER COLUMN location TYPE point
+    USING point(ST_Y(location::geometry), ST_X(location::geometry));
+
+COMMENT ON COLUMN bikes.location IS NULL;
+COMMENT ON COLUMN stations.location IS NULL;
+COMMENT ON COLUMN bike_positions.location IS NULL;
@@ -0,0 +1,24 @@
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+-- Point columns stored the latitude in [0] and the longitude in [1]. Geography points are
+-- (longitude, latitude) in WGS84.
+
+ALTER TABLE bikes
+    ALTER COLUMN location TYPE geography(Point, 4326)
+    USING ST_SetSRID(ST_MakePoint(location[1], location[0]), 4326)::geography;
+
+ALTER TABLE stations
+    ALTER COLUMN location TYPE geography(Point, 4326)
+    USING ST_SetSRID(ST_MakePoint(location[1], location[0]), 4326)::geography;
+
+ALTER TABLE bike_positions
+    ALTER COLUMN location TYPE geography(Point, 4326)
+    USING ST_SetSRID(ST_MakePoint(location[1], location[0]), 4326)::geography;
+
+COMMENT ON COLUMN bikes.location IS 'Last known position of the bike (WGS84)';
+COMMENT ON COLUMN stations.location IS 'Position of the station (WGS84)';
+COMMENT ON COLUMN bike_positions.location IS 'Position reported by the lock (WGS84)';
+
+CREATE INDEX bikes_location_idx ON bikes USING gist (location);
+CREATE INDEX stations_location_idx ON stations USING gist (location);
+CREATE INDEX bike_positions_location_idx ON bike_positions USING gist (location);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS bookings_start_time_idx;
+DROP INDEX IF EXISTS rides_started_at_idx;
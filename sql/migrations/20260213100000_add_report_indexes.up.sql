@@ -0,0 +1,2 @@
+CREATE INDEX rides_started_at_idx ON rides (started_at);
+CREATE INDEX bookings_start_time_idx ON bookings (start_time);
@@ -0,0 +1,2 @@
+ALTER TABLE stations
+    DROP COLUMN target_bikes;
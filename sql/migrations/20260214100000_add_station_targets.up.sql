@@ -0,0 +1,2 @@
+ALTER TABLE stations
+    ADD COLUMN target_bikes integer NOT NULL DEFAULT 0 CHECK (target_bikes >= 0);
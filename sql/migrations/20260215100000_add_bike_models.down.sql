@@ -0,0 +1,15 @@
+ALTER TABLE bikes ADD COLUMN display_name text;
+ALTER TABLE bikes ADD COLUMN image_url text;
+ALTER TABLE bikes ADD COLUMN battery_model text;
+
+UPDATE bikes b
+SET display_name = m.name,
+    image_url = m.image_url,
+    battery_model = m.battery_model
+FROM bike_models m
+WHERE m.id = b.model_id;
+
+DROP INDEX IF EXISTS bikes_model_id_idx;
+ALTER TABLE bikes DROP COLUMN model_id;
+
+DROP TABLE IF EXISTS bike_models;
@@ -0,0 +1,35 @@
+CREATE TABLE bike_models
+(
+    id            uuid PRIMARY KEY,
+    name          text                     NOT NULL,
+    image_url     text,
+    capacity      integer CHECK (capacity > 0),
+    cargo_type    text                     NOT NULL DEFAULT 'box' CHECK (cargo_type IN ('box', 'longtail', 'trike')),
+    description   text                     NOT NULL DEFAULT '',
+    battery_model text,
+    unlock_fee    integer CHECK (unlock_fee >= 0),
+    per_minute    integer CHECK (per_minute >= 0),
+    created_at    timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX bike_models_name ON bike_models (name);
+
+-- Every distinct display name becomes a model. Bikes with only a battery model get a model named after it.
+INSERT INTO bike_models (id, name, image_url, battery_model)
+SELECT gen_random_uuid(), COALESCE(display_name, battery_model), min(image_url), min(battery_model)
+FROM bikes
+WHERE display_name IS NOT NULL OR battery_model IS NOT NULL
+GROUP BY COALESCE(display_name, battery_model);
+
+ALTER TABLE bikes ADD COLUMN model_id uuid CONSTRAINT bikes_model REFERENCES bike_models (id);
+
+UPDATE bikes b
+SET model_id = m.id
+FROM bike_models m
+WHERE m.name = COALESCE(b.display_name, b.battery_model);
+
+CREATE INDEX bikes_model_id_idx ON bikes (model_id);
+
+ALTER TABLE bikes DROP COLUMN display_name;
+ALTER TABLE bikes DROP COLUMN image_url;
+ALTER TABLE bikes DROP COLUMN battery_model;
@@ -0,0 +1,6 @@
+ALTER TABLE stations
+    DROP COLUMN created_at,
+    DROP COLUMN deactivated_at,
+    DROP COLUMN return_radius,
+    DROP COLUMN capacity,
+    DROP COLUMN opening_schedule;
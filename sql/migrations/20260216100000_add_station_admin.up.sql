@@ -0,0 +1,6 @@
+ALTER TABLE stations
+    ADD COLUMN opening_schedule jsonb                    NOT NULL DEFAULT '[]',
+    ADD COLUMN capacity         integer CHECK (capacity > 0),
+    ADD COLUMN return_radius    integer                  NOT NULL DEFAULT 50 CHECK (return_radius > 0),
+    ADD COLUMN deactivated_at   timestamp with time zone,
+    ADD COLUMN created_at       timestamp with time zone NOT NULL DEFAULT now();
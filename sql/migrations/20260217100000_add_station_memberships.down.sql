@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS station_email_domains;
+DROP TABLE IF EXISTS station_invites;
+DROP TABLE IF EXISTS station_members;
@@ -0,0 +1,31 @@
+CREATE TABLE station_members
+(
+    station_id  uuid                     NOT NULL REFERENCES stations (id),
+    customer_id uuid                     NOT NULL REFERENCES customers (id),
+    invite_code text,
+    created_at  timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (station_id, customer_id)
+);
+
+CREATE INDEX station_members_customer_id_idx ON station_members (customer_id);
+
+CREATE TABLE station_invites
+(
+    code       text PRIMARY KEY,
+    station_id uuid                     NOT NULL REFERENCES stations (id),
+    expires_at timestamp with time zone,
+    max_uses   integer CHECK (max_uses > 0),
+    uses       integer                  NOT NULL DEFAULT 0,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX station_invites_station_id_idx ON station_invites (station_id);
+
+-- Customers whose email address is at one of a station's domains are members without an invite
+CREATE TABLE station_email_domains
+(
+    station_id uuid                     NOT NULL REFERENCES stations (id),
+    domain     text                     NOT NULL CHECK (domain = lower(domain)),
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (station_id, domain)
+);
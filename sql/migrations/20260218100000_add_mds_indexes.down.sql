@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS bike_state_changes_changed_at_idx;
+DROP INDEX IF EXISTS rides_ended_at_idx;
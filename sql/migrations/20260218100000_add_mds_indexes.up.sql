@@ -0,0 +1,2 @@
+CREATE INDEX rides_ended_at_idx ON rides (ended_at);
+CREATE INDEX bike_state_changes_changed_at_idx ON bike_state_changes (changed_at);
@@ -0,0 +1,13 @@
+CREATE TABLE customer_exports (
+    id           uuid                     NOT NULL PRIMARY KEY,
+    customer_id  uuid                     NOT NULL REFERENCES customers(id),
+    status       text                     NOT NULL DEFAULT 'pending',
+    blob_key     text,
+    error        text,
+    created_at   timestamp with time zone NOT NULL DEFAULT now(),
+    completed_at timestamp with time zone,
+    expires_at   timestamp with time zone
+);
+
+CREATE INDEX customer_exports_customer_id_idx ON customer_exports (customer_id, created_at);
+CREATE INDEX customer_exports_status_idx ON customer_exports (status);
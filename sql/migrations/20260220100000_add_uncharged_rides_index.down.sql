@@ -0,0 +1 @@
+DROP INDEX IF EXISTS rides_uncharged_idx;
@@ -0,0 +1 @@
+CREATE INDEX rides_uncharged_idx ON rides (ended_at) WHERE charge_created_at IS NULL AND ended_at IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE customers ADD COLUMN verified_email text;
+
+COMMENT ON COLUMN customers.verified_email IS 'Email address Auth0 has verified, which admits the customer to private stations by domain';
@@ -0,0 +1,113 @@
+package station
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Day is a day of the week in an opening schedule, e.g. "monday".
+type Day string
+
+var days = map[Day]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Valid reports whether d is a day of the week.
+func (d Day) Valid() bool {
+	_, ok := days[d]
+	return ok
+}
+
+// OpeningPeriod is a time a station is open on one day of the week. Opens and Closes are local times
+// such as "08:00"; Closes may be "24:00" for a station which is open until midnight.
+type OpeningPeriod struct {
+	Day    Day    `json:"day"`
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+}
+
+// OpeningSchedule is a station's weekly opening hours, stored as a JSON array. A station with an
+// empty schedule is always open.
+type OpeningSchedule []OpeningPeriod
+
+// Validate checks each period names a day and opens before it closes.
+func (s OpeningSchedule) Validate() error {
+	for _, p := range s {
+		if !p.Day.Valid() {
+			return fmt.Errorf("unknown day %q", p.Day)
+		}
+		opens, err := minuteOfDay(p.Opens)
+		if err != nil {
+			return err
+		}
+		closes, err := minuteOfDay(p.Closes)
+		if err != nil {
+			return err
+		}
+		if closes <= opens {
+			return fmt.Errorf("%s closes at %s before it opens at %s", p.Day, p.Closes, p.Opens)
+		}
+	}
+	return nil
+}
+
+// OpenAt reports whether the schedule is open at t, in t's location.
+func (s OpeningSchedule) OpenAt(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, p := range s {
+		if days[p.Day] != t.Weekday() {
+			continue
+		}
+		opens, err1 := minuteOfDay(p.Opens)
+		closes, err2 := minuteOfDay(p.Closes)
+		if err1 == nil && err2 == nil && minute >= opens && minute < closes {
+			return true
+		}
+	}
+	return false
+}
+
+func minuteOfDay(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (s OpeningSchedule) Value() (driver.Value, error) {
+	if s == nil {
+		s = OpeningSchedule{}
+	}
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *OpeningSchedule) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return errors.New("unsupported type for opening schedule")
+	}
+}
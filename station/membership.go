@@ -0,0 +1,290 @@
+package station
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/dberr"
+)
+
+var (
+	ErrInviteNotFound = errors.New("invite not found")
+	// ErrInviteExpired is returned when redeeming an invite after it expired or was used up.
+	ErrInviteExpired = errors.New("invite expired")
+	// ErrDomainTaken is returned when the station already admits the email domain.
+	ErrDomainTaken = errors.New("email domain already added")
+)
+
+// Member is a customer who has joined a private station.
+type Member struct {
+	StationID  uuid.UUID `db:"station_id"`
+	CustomerID uuid.UUID `db:"customer_id"`
+	// InviteCode is the invite the customer joined with, if any
+	InviteCode sql.NullString `db:"invite_code"`
+	CreatedAt  time.Time      `db:"created_at"`
+	Email      sql.NullString `db:"email"`
+	Name       sql.NullString `db:"name"`
+	// StationName is only fetched with a customer's memberships
+	StationName sql.NullString `db:"station_name"`
+}
+
+// Invite is a code customers can redeem to become members of a private station.
+type Invite struct {
+	Code      string        `db:"code"`
+	StationID uuid.UUID     `db:"station_id"`
+	ExpiresAt sql.NullTime  `db:"expires_at"`
+	MaxUses   sql.NullInt32 `db:"max_uses"`
+	Uses      int           `db:"uses"`
+	CreatedAt time.Time     `db:"created_at"`
+}
+
+// Usable reports whether the invite can still be redeemed at t.
+func (i Invite) Usable(t time.Time) bool {
+	if i.ExpiresAt.Valid && !t.Before(i.ExpiresAt.Time) {
+		return false
+	}
+	return !i.MaxUses.Valid || i.Uses < int(i.MaxUses.Int32)
+}
+
+// EmailDomain admits customers whose verified email address is at the domain to a private station.
+type EmailDomain struct {
+	StationID uuid.UUID `db:"station_id"`
+	Domain    string    `db:"domain"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// NormalizeDomain lower cases a domain and strips any leading "@".
+func NormalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+}
+
+// newInviteCode generates a random invite code which is easy to type, e.g. "K3QZ-7MPA".
+func newInviteCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.EncodeToString(b)
+	return code[:4] + "-" + code[4:], nil
+}
+
+// GetHiddenStations fetches the IDs of the private stations the customer isn't a member of, which
+// they can't see or book bikes at. customerID may be uuid.Nil, in which case every private station is
+// hidden.
+func (r *Repository) GetHiddenStations(ctx context.Context, customerID uuid.UUID) (map[uuid.UUID]bool, error) {
+	var ids []uuid.UUID
+	if err := r.db.SelectContext(ctx, &ids, getHiddenStationsQuery, customerID); err != nil {
+		return nil, err
+	}
+
+	hidden := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	return hidden, nil
+}
+
+const getHiddenStationsQuery = `
+SELECT s.id
+FROM stations s
+WHERE s.type = 'private'
+  AND NOT EXISTS (SELECT 1 FROM station_members m WHERE m.station_id = s.id AND m.customer_id = $1)
+  AND NOT EXISTS (SELECT 1
+                  FROM station_email_domains d
+                  JOIN customers c ON c.id = $1
+                  WHERE d.station_id = s.id
+                    AND d.domain = lower(split_part(c.verified_email, '@', 2)))
+`
+
+// CreateInvite creates an invite to the station with a new code.
+func (r *Repository) CreateInvite(ctx context.Context, inv *Invite) error {
+	code, err := newInviteCode()
+	if err != nil {
+		return err
+	}
+	inv.Code = code
+	return r.db.GetContext(ctx, inv, createInviteQuery, inv.Code, inv.StationID, inv.ExpiresAt, inv.MaxUses)
+}
+
+const createInviteQuery = `
+INSERT INTO station_invites (code, station_id, expires_at, max_uses, created_at)
+VALUES ($1, $2, $3, $4, now())
+RETURNING *
+`
+
+// GetInvites fetches the station's invites, newest first.
+func (r *Repository) GetInvites(ctx context.Context, stationID uuid.UUID) ([]Invite, error) {
+	var invites []Invite
+	err := r.db.SelectContext(ctx, &invites, getInvitesQuery, stationID)
+	return invites, err
+}
+
+const getInvitesQuery = `SELECT * FROM station_invites WHERE station_id = $1 ORDER BY created_at DESC`
+
+// DeleteInvite withdraws an invite. Customers who already redeemed it stay members.
+func (r *Repository) DeleteInvite(ctx context.Context, stationID uuid.UUID, code string) error {
+	res, err := r.db.ExecContext(ctx, deleteInviteQuery, stationID, code)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+const deleteInviteQuery = `DELETE FROM station_invites WHERE station_id = $1 AND code = $2`
+
+// RedeemInvite makes the customer a member of the invite's station. Redeeming an invite to a station
+// the customer is already a member of doesn't use it up.
+func (r *Repository) RedeemInvite(ctx context.Context, code string, customerID uuid.UUID) (Station, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Station{}, err
+	}
+	defer tx.Rollback()
+
+	var inv Invite
+	err = tx.GetContext(ctx, &inv, getInviteForUpdateQuery, strings.ToUpper(strings.TrimSpace(code)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Station{}, ErrInviteNotFound
+	}
+	if err != nil {
+		return Station{}, err
+	}
+
+	var s Station
+	if err := tx.GetContext(ctx, &s, getStation, inv.StationID); err != nil {
+		return Station{}, err
+	}
+	if !s.Active() {
+		return Station{}, ErrInviteNotFound
+	}
+
+	res, err := tx.ExecContext(ctx, addMemberQuery, inv.StationID, customerID, inv.Code)
+	if err != nil {
+		return Station{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return Station{}, err
+	} else if n == 0 {
+		// Already a member
+		return s, nil
+	}
+
+	if !inv.Usable(time.Now()) {
+		return Station{}, ErrInviteExpired
+	}
+	if _, err := tx.ExecContext(ctx, useInviteQuery, inv.Code); err != nil {
+		return Station{}, err
+	}
+	return s, tx.Commit()
+}
+
+const getInviteForUpdateQuery = `SELECT * FROM station_invites WHERE code = $1 FOR UPDATE`
+
+const addMemberQuery = `
+INSERT INTO station_members (station_id, customer_id, invite_code, created_at)
+VALUES ($1, $2, $3, now())
+ON CONFLICT DO NOTHING
+`
+
+const useInviteQuery = `UPDATE station_invites SET uses = uses + 1 WHERE code = $1`
+
+// GetMembers fetches the customers who have joined the station, newest first. Customers admitted by
+// an email domain aren't included.
+func (r *Repository) GetMembers(ctx context.Context, stationID uuid.UUID) ([]Member, error) {
+	var members []Member
+	err := r.db.SelectContext(ctx, &members, getMembersQuery, stationID)
+	return members, err
+}
+
+const getMembersQuery = `
+SELECT m.*, c.email, c.name
+FROM station_members m
+JOIN customers c ON c.id = m.customer_id
+WHERE m.station_id = $1
+ORDER BY m.created_at DESC
+`
+
+// GetMemberships fetches the private stations the customer has joined, newest first.
+func (r *Repository) GetMemberships(ctx context.Context, customerID uuid.UUID) ([]Member, error) {
+	var members []Member
+	err := r.db.SelectContext(ctx, &members, getMembershipsQuery, customerID)
+	return members, err
+}
+
+const getMembershipsQuery = `
+SELECT m.*, s.name AS station_name
+FROM station_members m
+JOIN stations s ON s.id = m.station_id
+WHERE m.customer_id = $1
+ORDER BY m.created_at DESC
+`
+
+// RemoveMember takes a customer's membership of a station away. A customer admitted by an email
+// domain keeps access until the domain is removed.
+func (r *Repository) RemoveMember(ctx context.Context, stationID, customerID uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx, removeMemberQuery, stationID, customerID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const removeMemberQuery = `DELETE FROM station_members WHERE station_id = $1 AND customer_id = $2`
+
+// GetEmailDomains fetches the email domains the station admits.
+func (r *Repository) GetEmailDomains(ctx context.Context, stationID uuid.UUID) ([]EmailDomain, error) {
+	var domains []EmailDomain
+	err := r.db.SelectContext(ctx, &domains, getEmailDomainsQuery, stationID)
+	return domains, err
+}
+
+const getEmailDomainsQuery = `SELECT * FROM station_email_domains WHERE station_id = $1 ORDER BY domain`
+
+// AddEmailDomain admits customers whose email address is at the domain to the station.
+func (r *Repository) AddEmailDomain(ctx context.Context, stationID uuid.UUID, domain string) (EmailDomain, error) {
+	var d EmailDomain
+	err := r.db.GetContext(ctx, &d, addEmailDomainQuery, stationID, NormalizeDomain(domain))
+	if _, ok := dberr.UniqueViolation(err); ok {
+		return d, ErrDomainTaken
+	}
+	return d, err
+}
+
+const addEmailDomainQuery = `
+INSERT INTO station_email_domains (station_id, domain, created_at)
+VALUES ($1, $2, now())
+RETURNING *
+`
+
+// RemoveEmailDomain stops admitting customers by the email domain.
+func (r *Repository) RemoveEmailDomain(ctx context.Context, stationID uuid.UUID, domain string) error {
+	res, err := r.db.ExecContext(ctx, removeEmailDomainQuery, stationID, NormalizeDomain(domain))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const removeEmailDomainQuery = `DELETE FROM station_email_domains WHERE station_id = $1 AND domain = $2`
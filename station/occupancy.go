@@ -0,0 +1,90 @@
+package station
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Occupancy is a live count of the bikes at a station.
+type Occupancy struct {
+	StationID uuid.UUID `db:"station_id"`
+	// AvailableBikes are the active bikes assigned to the station which aren't being ridden or booked
+	// by someone else now or before the booking buffer runs out
+	AvailableBikes int `db:"available_bikes"`
+	// RidingBikes are the bikes assigned to the station which are out on a ride
+	RidingBikes int `db:"riding_bikes"`
+	// BookingsSoon are the bookings of bikes assigned to the station which start before the soon time
+	BookingsSoon int `db:"bookings_soon"`
+	// FreeSpaces is how many more bikes can be returned to the station. It is null for stations
+	// without a capacity.
+	FreeSpaces sql.NullInt32 `db:"free_spaces"`
+}
+
+// GetOccupancy counts the bikes at each of the stations in one query. Bookings by customerID, which
+// may be uuid.Nil, don't make bikes unavailable. The result is keyed by station ID.
+func (r *Repository) GetOccupancy(ctx context.Context, stationIDs []uuid.UUID, customerID uuid.UUID, now,
+	soon time.Time) (map[uuid.UUID]Occupancy, error) {
+	var rows []Occupancy
+	if err := r.db.SelectContext(ctx, &rows, getOccupancyQuery, stationIDs, customerID, now, soon); err != nil {
+		return nil, err
+	}
+
+	occupancy := make(map[uuid.UUID]Occupancy, len(rows))
+	for _, o := range rows {
+		occupancy[o.StationID] = o
+	}
+	return occupancy, nil
+}
+
+// getOccupancyQuery counts bikes by the station they are assigned to, except for free spaces which
+// count the bikes parked within the station's return radius, whichever station they belong to.
+const getOccupancyQuery = `
+WITH assigned AS (
+    SELECT b.id,
+           b.station_id,
+           b.state,
+           EXISTS (SELECT 1 FROM rides r WHERE r.bike_id = b.id AND r.ended_at IS NULL) AS riding,
+           EXISTS (SELECT 1 FROM bookings bk
+                   WHERE bk.bike_id = b.id
+                     AND bk.cancelled_at IS NULL
+                     AND bk.user_id != $2
+                     AND bk.start_time < $4
+                     AND bk.end_time > $3) AS booked
+    FROM bikes b
+    WHERE b.station_id = ANY($1)
+      AND b.state != 'retired'
+),
+parked AS (
+    SELECT s.id AS station_id, count(*) AS parked
+    FROM stations s
+    JOIN bikes b ON ST_DWithin(b.location, s.location, s.return_radius)
+    WHERE s.id = ANY($1)
+      AND b.state != 'retired'
+      AND NOT EXISTS (SELECT 1 FROM rides r WHERE r.bike_id = b.id AND r.ended_at IS NULL)
+    GROUP BY s.id
+),
+soon AS (
+    SELECT b.station_id, count(*) AS bookings
+    FROM bookings bk
+    JOIN bikes b ON b.id = bk.bike_id
+    WHERE b.station_id = ANY($1)
+      AND bk.cancelled_at IS NULL
+      AND bk.start_time >= $3
+      AND bk.start_time < $4
+    GROUP BY b.station_id
+)
+SELECT s.id AS station_id,
+       count(a.id) FILTER (WHERE a.state = 'active' AND NOT a.riding AND NOT a.booked) AS available_bikes,
+       count(a.id) FILTER (WHERE a.riding) AS riding_bikes,
+       COALESCE(so.bookings, 0) AS bookings_soon,
+       CASE WHEN s.capacity IS NOT NULL THEN greatest(s.capacity - COALESCE(p.parked, 0), 0) END AS free_spaces
+FROM stations s
+LEFT JOIN assigned a ON a.station_id = s.id
+LEFT JOIN parked p ON p.station_id = s.id
+LEFT JOIN soon so ON so.station_id = s.id
+WHERE s.id = ANY($1)
+GROUP BY s.id, s.capacity, p.parked, so.bookings
+`
@@ -1,7 +1,22 @@
 package station
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+)
+
+var (
+	ErrNotFound = errors.New("station not found")
+	// ErrHasBikes is returned when deactivating a station which bikes are still assigned to.
+	ErrHasBikes = errors.New("station has bikes assigned")
+	// ErrDeactivated is returned when changing a deactivated station.
+	ErrDeactivated = errors.New("station deactivated")
 )
 
 type Repository struct {
@@ -14,18 +29,179 @@ func NewRepository(db *sqlx.DB) *Repository {
 	}
 }
 
+// GetStations fetches the active stations.
 func (r *Repository) GetStations() ([]Station, error) {
 	var stations []Station
 	err := r.db.Select(&stations, getStations)
 	return stations, err
 }
 
-const getStations = `SELECT * FROM stations`
+const getStations = `SELECT * FROM stations WHERE deactivated_at IS NULL`
 
+// GetStation fetches a station, including deactivated stations. ErrNotFound is returned if the ID
+// isn't a station's.
 func (r *Repository) GetStation(id string) (Station, error) {
 	var station Station
+	if _, err := uuid.Parse(id); err != nil {
+		return station, ErrNotFound
+	}
 	err := r.db.Get(&station, getStation, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return station, ErrNotFound
+	}
 	return station, err
 }
 
 const getStation = `SELECT * FROM stations WHERE id = $1`
+
+// Nearby is a station and its distance in metres from the point searched around.
+type Nearby struct {
+	Station
+	Distance float64 `db:"distance"`
+}
+
+// GetNearby fetches the active stations within radius metres of center, nearest first, leaving out the
+// hidden ones. stationType optionally restricts the search to public or private stations.
+func (r *Repository) GetNearby(ctx context.Context, center geo.Point, radius float64, stationType *Type,
+	hidden []uuid.UUID, limit int) ([]Nearby, error) {
+	var typ *string
+	if stationType != nil {
+		t := stationType.String()
+		typ = &t
+	}
+
+	var stations []Nearby
+	err := r.db.SelectContext(ctx, &stations, getNearbyQuery, center, radius, typ, hidden, limit)
+	return stations, err
+}
+
+// getNearbyQuery finds the stations using the location index, and measures distances on the spheroid.
+const getNearbyQuery = `
+SELECT s.*, ST_Distance(s.location, $1::geography) AS distance
+FROM stations s
+WHERE ST_DWithin(s.location, $1::geography, $2)
+  AND s.deactivated_at IS NULL
+  AND ($3::text IS NULL OR s.type = $3)
+  AND s.id <> ALL($4::uuid[])
+ORDER BY distance
+LIMIT $5
+`
+
+// ListStations fetches every station for administration, optionally including deactivated ones.
+func (r *Repository) ListStations(ctx context.Context, includeDeactivated bool) ([]Station, error) {
+	var stations []Station
+	err := r.db.SelectContext(ctx, &stations, listStationsQuery, includeDeactivated)
+	return stations, err
+}
+
+const listStationsQuery = `
+SELECT * FROM stations
+WHERE $1 OR deactivated_at IS NULL
+ORDER BY name
+`
+
+// Create adds a station.
+func (r *Repository) Create(ctx context.Context, s *Station) error {
+	rows, err := r.db.NamedQueryContext(ctx, createStationQuery, s)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return rows.Err()
+	}
+	return rows.StructScan(s)
+}
+
+const createStationQuery = `
+INSERT INTO stations (id, name, address, opening_hours, opening_schedule, location, type, capacity,
+                      return_radius, target_bikes, created_at)
+VALUES (:id, :name, :address, :opening_hours, :opening_schedule, :location, :type, :capacity,
+        :return_radius, :target_bikes, now())
+RETURNING *
+`
+
+// Update saves changes to a station. Deactivated stations can't be changed until they are reactivated.
+func (r *Repository) Update(ctx context.Context, s *Station) error {
+	rows, err := r.db.NamedQueryContext(ctx, updateStationQuery, s)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrDeactivated
+	}
+	return rows.StructScan(s)
+}
+
+const updateStationQuery = `
+UPDATE stations
+SET name = :name,
+    address = :address,
+    opening_hours = :opening_hours,
+    opening_schedule = :opening_schedule,
+    location = :location,
+    type = :type,
+    capacity = :capacity,
+    return_radius = :return_radius,
+    target_bikes = :target_bikes
+WHERE id = :id
+  AND deactivated_at IS NULL
+RETURNING *
+`
+
+// Deactivate takes a station out of use. ErrHasBikes is returned while any bike which hasn't been
+// retired is assigned to it.
+func (r *Repository) Deactivate(ctx context.Context, id uuid.UUID) (Station, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Station{}, err
+	}
+	defer tx.Rollback()
+
+	var s Station
+	err = tx.GetContext(ctx, &s, getStationForUpdateQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Station{}, ErrNotFound
+	}
+	if err != nil {
+		return Station{}, err
+	}
+	if !s.Active() {
+		return s, nil
+	}
+
+	var assigned int
+	if err := tx.GetContext(ctx, &assigned, countAssignedBikesQuery, id); err != nil {
+		return Station{}, err
+	}
+	if assigned > 0 {
+		return Station{}, ErrHasBikes
+	}
+
+	if err := tx.GetContext(ctx, &s, deactivateStationQuery, id); err != nil {
+		return Station{}, err
+	}
+	return s, tx.Commit()
+}
+
+const getStationForUpdateQuery = `SELECT * FROM stations WHERE id = $1 FOR UPDATE`
+
+const countAssignedBikesQuery = `SELECT count(*) FROM bikes WHERE station_id = $1 AND state != 'retired'`
+
+const deactivateStationQuery = `UPDATE stations SET deactivated_at = now() WHERE id = $1 RETURNING *`
+
+// Reactivate puts a deactivated station back into use.
+func (r *Repository) Reactivate(ctx context.Context, id uuid.UUID) (Station, error) {
+	var s Station
+	err := r.db.GetContext(ctx, &s, reactivateStationQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+const reactivateStationQuery = `UPDATE stations SET deactivated_at = NULL WHERE id = $1 RETURNING *`
@@ -1,11 +1,21 @@
 package station
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
 )
 
+// DefaultReturnRadius is how close, in metres, a bike has to be to a station to be returned there
+// unless the station says otherwise.
+const DefaultReturnRadius = 50
+
 type Type int
 
 const (
@@ -18,8 +28,25 @@ type Station struct {
 	Name         string
 	Address      string
 	OpeningHours string `db:"opening_hours"`
-	Location     pgtype.Point
+	Location     geo.Point
 	Type         Type
+	// OpeningSchedule is the structured form of OpeningHours
+	OpeningSchedule OpeningSchedule `db:"opening_schedule"`
+	// Capacity is how many bikes the station has room for, if it is limited
+	Capacity sql.NullInt32 `db:"capacity"`
+	// ReturnRadius is how close, in metres, a bike has to be to the station to be returned there
+	ReturnRadius int `db:"return_radius"`
+	// TargetBikes is how many bikes the station should have for rebalancing. 0 means it has no target.
+	TargetBikes int `db:"target_bikes"`
+	// DeactivatedAt is when the station was taken out of use. Deactivated stations are kept because
+	// rides and ratings refer to them, but aren't shown to riders.
+	DeactivatedAt sql.NullTime `db:"deactivated_at"`
+	CreatedAt     time.Time    `db:"created_at"`
+}
+
+// Active reports whether the station is in use.
+func (s Station) Active() bool {
+	return !s.DeactivatedAt.Valid
 }
 
 func (t Type) String() string {
@@ -30,6 +57,21 @@ func (t Type) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.String())
 }
 
+// ParseType parses "public" or "private".
+func ParseType(s string) (Type, error) {
+	switch s {
+	case "public":
+		return Public, nil
+	case "private":
+		return Private, nil
+	}
+	return 0, errors.New("invalid station type")
+}
+
+func (t Type) Value() (driver.Value, error) {
+	return t.String(), nil
+}
+
 func (t *Type) Scan(i any) error {
 	switch v := i.(type) {
 	case string:
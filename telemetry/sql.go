@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrUnknownDevice = errors.New("unknown device")
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Record stores readings reported by the bike with the given IMEI and updates the bike's latest
+// status from the most recent reading.
+func (r *Repository) Record(ctx context.Context, imei string, readings []Reading) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var bikeID uuid.UUID
+	err = tx.GetContext(ctx, &bikeID, getBikeIDByIMEIQuery, imei)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUnknownDevice
+	}
+	if err != nil {
+		return err
+	}
+
+	var latest *Reading
+	for i := range readings {
+		rd := &readings[i]
+		rd.ID = uuid.New()
+		rd.BikeID = bikeID
+		_, err = tx.ExecContext(ctx, insertReadingQuery,
+			rd.ID, rd.BikeID, rd.RecordedAt, rd.BatteryVoltage, rd.LockState, rd.SignalStrength)
+		if err != nil {
+			return err
+		}
+		if latest == nil || rd.RecordedAt.After(latest.RecordedAt) {
+			latest = rd
+		}
+	}
+
+	if latest != nil {
+		_, err = tx.ExecContext(ctx, updateBikeStatusQuery,
+			bikeID, latest.BatteryVoltage, latest.LockState, latest.SignalStrength, latest.RecordedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const getBikeIDByIMEIQuery = `SELECT id FROM bikes WHERE imei = $1`
+
+const insertReadingQuery = `
+INSERT INTO bike_telemetry (id, bike_id, recorded_at, battery_voltage, lock_state, signal_strength)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+// updateBikeStatusQuery keeps the last known battery voltage if the reading didn't include one.
+const updateBikeStatusQuery = `
+UPDATE bikes SET
+    battery_voltage = COALESCE($2, battery_voltage),
+    lock_state = $3,
+    signal_strength = $4,
+    telemetry_updated_at = $5
+WHERE id = $1
+  AND (telemetry_updated_at IS NULL OR telemetry_updated_at < $5)
+`
+
+// GetBatteryHistory fetches the battery voltage readings for a bike within a time range, oldest first.
+func (r *Repository) GetBatteryHistory(ctx context.Context, bikeID uuid.UUID,
+	from, to time.Time) ([]BatteryPoint, error) {
+	var points []BatteryPoint
+	err := r.db.SelectContext(ctx, &points, getBatteryHistoryQuery, bikeID, from, to)
+	return points, err
+}
+
+const getBatteryHistoryQuery = `
+SELECT recorded_at, battery_voltage FROM bike_telemetry
+WHERE bike_id = $1
+  AND battery_voltage IS NOT NULL
+  AND recorded_at >= $2
+  AND recorded_at < $3
+ORDER BY recorded_at ASC
+`
@@ -0,0 +1,35 @@
+// Package telemetry stores the status readings reported by bike locks.
+package telemetry
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LockState string
+
+const (
+	LockStateLocked   LockState = "locked"
+	LockStateUnlocked LockState = "unlocked"
+	LockStateUnknown  LockState = "unknown"
+)
+
+// Reading is a single status report from a bike's lock.
+type Reading struct {
+	ID         uuid.UUID `db:"id"`
+	BikeID     uuid.UUID `db:"bike_id"`
+	RecordedAt time.Time `db:"recorded_at"`
+	// BatteryVoltage is the pack voltage in tenths of a volt
+	BatteryVoltage sql.NullInt32 `db:"battery_voltage"`
+	LockState      LockState     `db:"lock_state"`
+	// SignalStrength is the cellular signal strength in dBm
+	SignalStrength sql.NullInt32 `db:"signal_strength"`
+}
+
+// BatteryPoint is a single point in a bike's battery history.
+type BatteryPoint struct {
+	RecordedAt     time.Time `db:"recorded_at"`
+	BatteryVoltage int       `db:"battery_voltage"`
+}
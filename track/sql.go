@@ -0,0 +1,127 @@
+package track
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrUnknownDevice = errors.New("unknown device")
+	ErrNoFix         = errors.New("no fix")
+)
+
+type Repository struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Record stores fixes reported by the bike with the given IMEI. Each fix is attached to the ride
+// that was in progress on the bike when it was recorded, and the bike's location is moved to the
+// most recent accurate fix.
+func (r *Repository) Record(ctx context.Context, imei string, fixes []Fix) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var bikeID uuid.UUID
+	err = tx.GetContext(ctx, &bikeID, getBikeIDByIMEIQuery, imei)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUnknownDevice
+	}
+	if err != nil {
+		return err
+	}
+
+	var latest *Fix
+	for i := range fixes {
+		f := &fixes[i]
+		f.ID = uuid.New()
+		f.BikeID = bikeID
+		err = tx.GetContext(ctx, &f.RideID, insertFixQuery, f.ID, f.BikeID, f.RecordedAt, f.Location, f.Accuracy)
+		if err != nil {
+			return err
+		}
+		if f.accurate() && (latest == nil || f.RecordedAt.After(latest.RecordedAt)) {
+			latest = f
+		}
+	}
+
+	if latest != nil {
+		_, err = tx.ExecContext(ctx, updateBikeLocationQuery, bikeID, latest.Location, latest.RecordedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const getBikeIDByIMEIQuery = `SELECT id FROM bikes WHERE imei = $1`
+
+const insertFixQuery = `
+INSERT INTO bike_positions (id, bike_id, ride_id, recorded_at, location, accuracy)
+VALUES ($1, $2, (
+    SELECT id FROM rides
+    WHERE bike_id = $2
+      AND started_at <= $3
+      AND (ended_at IS NULL OR ended_at >= $3)
+    ORDER BY started_at DESC
+    LIMIT 1
+), $3, $4, $5)
+RETURNING ride_id
+`
+
+const updateBikeLocationQuery = `
+UPDATE bikes SET location = $2, location_updated_at = $3
+WHERE id = $1
+  AND (location_updated_at IS NULL OR location_updated_at < $3)
+`
+
+// GetFixesForRides fetches the fixes attached to each of the given rides, keyed by ride ID.
+func (r *Repository) GetFixesForRides(ctx context.Context, rideIDs []uuid.UUID) (map[uuid.UUID][]Fix, error) {
+	var fixes []Fix
+	err := r.db.SelectContext(ctx, &fixes, getFixesForRidesQuery, rideIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byRide := make(map[uuid.UUID][]Fix, len(rideIDs))
+	for _, f := range fixes {
+		byRide[f.RideID.UUID] = append(byRide[f.RideID.UUID], f)
+	}
+	return byRide, nil
+}
+
+const getFixesForRidesQuery = `
+SELECT * FROM bike_positions
+WHERE ride_id = ANY($1)
+ORDER BY recorded_at ASC
+`
+
+// GetFixBefore fetches the bike's last fix recorded at or before t. ErrNoFix is returned if there
+// isn't one.
+func (r *Repository) GetFixBefore(ctx context.Context, bikeID uuid.UUID, t time.Time) (Fix, error) {
+	var f Fix
+	err := r.db.GetContext(ctx, &f, getFixBeforeQuery, bikeID, t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Fix{}, ErrNoFix
+	}
+	return f, err
+}
+
+const getFixBeforeQuery = `
+SELECT * FROM bike_positions
+WHERE bike_id = $1 AND recorded_at <= $2
+ORDER BY recorded_at DESC
+LIMIT 1
+`
@@ -0,0 +1,129 @@
+// Package track stores the position fixes reported by bikes and derives ride statistics from them.
+package track
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+)
+
+const (
+	// maxAccuracy is the worst horizontal accuracy in metres we accept for a fix.
+	maxAccuracy = 50
+	// maxSpeed is the fastest plausible speed of a cargo bike in metres per second (~45km/h).
+	// Fixes implying a faster jump from the previous fix are treated as GPS noise.
+	maxSpeed = 12.5
+	// reseedAfter is how many fixes in a row have to be unreachable from the last fix kept for that
+	// fix to be dropped as the outlier instead.
+	reseedAfter = 3
+	// simplifyTolerance is the tolerance in metres used when simplifying a ride's path.
+	simplifyTolerance = 10
+)
+
+// Fix is a single timestamped position reported by a bike.
+type Fix struct {
+	ID         uuid.UUID       `db:"id"`
+	BikeID     uuid.UUID       `db:"bike_id"`
+	RideID     uuid.NullUUID   `db:"ride_id"`
+	RecordedAt time.Time       `db:"recorded_at"`
+	Location   geo.Point       `db:"location"`
+	Accuracy   sql.NullFloat64 `db:"accuracy"`
+}
+
+// Point returns the location of the fix.
+func (f Fix) Point() geo.Point {
+	return f.Location
+}
+
+// Stats summarises the path taken during a ride.
+type Stats struct {
+	// DistanceMeters is the distance travelled after outlier filtering.
+	DistanceMeters int
+	// Polyline is the simplified path in encoded polyline format.
+	Polyline string
+}
+
+// Summarise computes the ride statistics for a set of fixes. Fixes with poor accuracy, or
+// which would require an implausible speed to reach from the previous fix, are ignored.
+func Summarise(fixes []Fix) Stats {
+	path := Filter(fixes)
+
+	var distance float64
+	for i := 1; i < len(path); i++ {
+		distance += geo.Distance(path[i-1], path[i])
+	}
+
+	return Stats{
+		DistanceMeters: int(distance),
+		Polyline:       geo.EncodePolyline(geo.Simplify(path, simplifyTolerance)),
+	}
+}
+
+// Filter orders fixes by time and drops outliers, returning the remaining path.
+func Filter(fixes []Fix) []geo.Point {
+	clean := Clean(fixes)
+	path := make([]geo.Point, 0, len(clean))
+	for _, f := range clean {
+		path = append(path, f.Point())
+	}
+	return path
+}
+
+// Clean orders fixes by time and drops outliers, returning the remaining fixes.
+//
+// A fix is an outlier if it is inaccurate, or would need an implausible speed to reach from the last
+// fix kept. When several fixes in a row can't be reached from the last fix kept, it is that fix
+// which is the outlier, e.g. a glitch at the start of a ride, so it is dropped and the fixes after it
+// are checked again.
+func Clean(fixes []Fix) []Fix {
+	queue := make([]Fix, 0, len(fixes))
+	for _, f := range fixes {
+		if f.accurate() {
+			queue = append(queue, f)
+		}
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].RecordedAt.Before(queue[j].RecordedAt)
+	})
+
+	clean := make([]Fix, 0, len(queue))
+	var rejected []Fix
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+
+		if len(clean) == 0 {
+			clean = append(clean, f)
+			continue
+		}
+		last := clean[len(clean)-1]
+		elapsed := f.RecordedAt.Sub(last.RecordedAt).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		if geo.Distance(last.Point(), f.Point())/elapsed <= maxSpeed {
+			clean = append(clean, f)
+			rejected = rejected[:0]
+			continue
+		}
+
+		rejected = append(rejected, f)
+		// At the end of the track the last fix kept is also suspect if it is the only one
+		if len(rejected) >= reseedAfter || (len(queue) == 0 && len(clean) == 1) {
+			clean = clean[:len(clean)-1]
+			queue = append(rejected, queue...)
+			rejected = nil
+		}
+	}
+	return clean
+}
+
+// accurate reports whether the fix is accurate enough to use. Fixes without an accuracy are
+// assumed to be.
+func (f Fix) accurate() bool {
+	return !f.Accuracy.Valid || f.Accuracy.Float64 <= maxAccuracy
+}
@@ -0,0 +1,154 @@
+package track
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/semanticallynull/bookingengine-backend/internal/geo"
+)
+
+var start = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// ridden is a fix n steps along a straight ride north at 5m/s, with a fix every 10 seconds.
+func ridden(n int) Fix {
+	return Fix{
+		RecordedAt: start.Add(time.Duration(n) * 10 * time.Second),
+		Location:   geo.Point{Lat: 53.35 + float64(n)*0.00045, Lng: -6.26},
+	}
+}
+
+// glitch is a fix n steps along the ride which is 5km away from it.
+func glitch(n int) Fix {
+	f := ridden(n)
+	f.Location.Lng += 0.075
+	return f
+}
+
+func withAccuracy(f Fix, metres float64) Fix {
+	f.Accuracy = sql.NullFloat64{Float64: metres, Valid: true}
+	return f
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name  string
+		fixes []Fix
+		want  []Fix
+	}{
+		{
+			name: "no fixes",
+		},
+		{
+			name:  "straight ride",
+			fixes: []Fix{ridden(0), ridden(1), ridden(2), ridden(3)},
+			want:  []Fix{ridden(0), ridden(1), ridden(2), ridden(3)},
+		},
+		{
+			name:  "out of order",
+			fixes: []Fix{ridden(2), ridden(0), ridden(3), ridden(1)},
+			want:  []Fix{ridden(0), ridden(1), ridden(2), ridden(3)},
+		},
+		{
+			name:  "inaccurate fix",
+			fixes: []Fix{ridden(0), withAccuracy(ridden(1), 80), withAccuracy(ridden(2), 20), ridden(3)},
+			want:  []Fix{ridden(0), withAccuracy(ridden(2), 20), ridden(3)},
+		},
+		{
+			name:  "duplicate time",
+			fixes: []Fix{ridden(0), ridden(1), glitch(1), ridden(2)},
+			want:  []Fix{ridden(0), ridden(1), ridden(2)},
+		},
+		{
+			name:  "glitch during the ride",
+			fixes: []Fix{ridden(0), ridden(1), glitch(2), ridden(3), ridden(4)},
+			want:  []Fix{ridden(0), ridden(1), ridden(3), ridden(4)},
+		},
+		{
+			name:  "glitches in a row during the ride",
+			fixes: []Fix{ridden(0), ridden(1), glitch(2), glitch(3), ridden(4), ridden(5)},
+			want:  []Fix{ridden(0), ridden(1), ridden(4), ridden(5)},
+		},
+		{
+			name:  "glitch at the start",
+			fixes: []Fix{glitch(0), ridden(1), ridden(2), ridden(3), ridden(4), ridden(5)},
+			want:  []Fix{ridden(1), ridden(2), ridden(3), ridden(4), ridden(5)},
+		},
+		{
+			name:  "glitch at the start of a short ride",
+			fixes: []Fix{glitch(0), ridden(1), ridden(2)},
+			want:  []Fix{ridden(1), ridden(2)},
+		},
+		{
+			name:  "glitch at the end",
+			fixes: []Fix{ridden(0), ridden(1), ridden(2), glitch(3)},
+			want:  []Fix{ridden(0), ridden(1), ridden(2)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Clean(tt.fixes)
+			if !slices.Equal(got, tt.want) && (len(got) != 0 || len(tt.want) != 0) {
+				t.Errorf("Clean() = %v, want %v", times(got), times(tt.want))
+			}
+		})
+	}
+}
+
+// times lists the fixes as seconds into the ride, marking glitches, for readable failures.
+func times(fixes []Fix) []string {
+	s := make([]string, 0, len(fixes))
+	for _, f := range fixes {
+		label := f.RecordedAt.Sub(start).String()
+		if f.Location.Lng != -6.26 {
+			label += " (glitch)"
+		}
+		s = append(s, label)
+	}
+	return s
+}
+
+func TestSummarise(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixes    []Fix
+		distance int
+		path     []geo.Point
+	}{
+		{
+			name: "no fixes",
+		},
+		{
+			name:  "one fix",
+			fixes: []Fix{ridden(0)},
+			path:  []geo.Point{ridden(0).Location},
+		},
+		{
+			name:     "straight ride",
+			fixes:    []Fix{ridden(0), ridden(1), ridden(2), ridden(3), ridden(4)},
+			distance: 200,
+			path:     []geo.Point{ridden(0).Location, ridden(4).Location},
+		},
+		{
+			name:     "glitch at the start",
+			fixes:    []Fix{glitch(0), ridden(1), ridden(2), ridden(3), ridden(4), ridden(5)},
+			distance: 200,
+			path:     []geo.Point{ridden(1).Location, ridden(5).Location},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := Summarise(tt.fixes)
+			// 0.00045 degrees of latitude is a little over 50m
+			if stats.DistanceMeters < tt.distance || stats.DistanceMeters > tt.distance*102/100 {
+				t.Errorf("DistanceMeters = %d, want about %d", stats.DistanceMeters, tt.distance)
+			}
+			if want := geo.EncodePolyline(tt.path); stats.Polyline != want {
+				t.Errorf("Polyline = %q, want %q", stats.Polyline, want)
+			}
+		})
+	}
+}